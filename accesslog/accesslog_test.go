@@ -0,0 +1,150 @@
+package accesslog_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken/accesslog"
+)
+
+func TestFormatLine(t *testing.T) {
+	entry := &accesslog.Entry{
+		Time:       time.Date(2015, 3, 14, 9, 26, 53, 0, time.FixedZone("", 3600)),
+		RemoteAddr: "10.0.0.1:51234",
+		Method:     "GET",
+		Host:       "example.com",
+		URI:        "/a b/\"c\".txt",
+		Proto:      "HTTP/1.1",
+		Status:     200,
+		Bytes:      1234,
+		UserAgent:  "curl/7.40",
+		Duration:   time.Millisecond,
+	}
+	for _, tt := range []struct {
+		Format accesslog.Format
+		Line   string
+	}{
+		{accesslog.FormatCommon, `10.0.0.1 - - [14/Mar/2015:09:26:53 +0100] "GET /a b/\"c\".txt HTTP/1.1" 200 1234` + "\n"},
+		{accesslog.FormatCombined, `10.0.0.1 - - [14/Mar/2015:09:26:53 +0100] "GET /a b/\"c\".txt HTTP/1.1" 200 1234 "" "curl/7.40"` + "\n"},
+	} {
+		if line := string(tt.Format.Line(entry)); line != tt.Line {
+			t.Errorf("%s: expected %q, got %q", tt.Format, tt.Line, line)
+		}
+	}
+
+	line := accesslog.FormatJSON.Line(entry)
+	if !strings.HasSuffix(string(line), "}\n") {
+		t.Errorf("expected a JSON line, got %q", line)
+	}
+	var decoded accesslog.Entry
+	if err := json.Unmarshal(line, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Time.Equal(entry.Time) || decoded.URI != entry.URI || decoded.Status != 200 || decoded.Bytes != 1234 || decoded.Duration != time.Millisecond {
+		t.Errorf("expected %+v, got %+v", entry, decoded)
+	}
+
+	if f, err := accesslog.ParseFormat(""); err != nil || f != accesslog.DefaultFormat {
+		t.Errorf("expected the default format, got %q, %v", f, err)
+	}
+	if _, err := accesslog.ParseFormat("apache"); err == nil {
+		t.Error("expected an error parsing an unknown format")
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kraken-accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "logs", "access.log")
+
+	lf, err := accesslog.OpenFile(name, accesslog.Options{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.Close()
+	// Each line fills a file; the 4 first are rotated, and only the 2 most recent rotated files are kept.
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n", "line 5\n"} {
+		if _, err := lf.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b, err := ioutil.ReadFile(name); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "line 5\n" {
+		t.Errorf("expected the current file to hold the last line, got %q", b)
+	}
+	backups, err := lf.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 rotated files, got %v", backups)
+	}
+	for i, expected := range []string{"line 3\n", "line 4\n"} {
+		if b, err := ioutil.ReadFile(backups[i]); err != nil {
+			t.Fatal(err)
+		} else if string(b) != expected {
+			t.Errorf("expected rotated file %s to hold %q, got %q", backups[i], expected, b)
+		}
+	}
+
+	if err := lf.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(name); err != nil {
+		t.Fatal(err)
+	} else if fi.Size() != 0 {
+		t.Errorf("expected an empty file once rotated, got %d bytes", fi.Size())
+	}
+
+	for _, opts := range []accesslog.Options{
+		{Rotate: "weekly"},
+		{MaxSize: -1},
+	} {
+		if _, err := accesslog.OpenFile(name, opts); err == nil {
+			t.Errorf("expected an error opening a file with options %+v", opts)
+		}
+	}
+}
+
+func TestFileRotationFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kraken-accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "access.log")
+
+	lf, err := accesslog.OpenFile(name, accesslog.Options{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.Close()
+	if _, err := lf.Write([]byte("line 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	// The file can't be renamed once removed: the lines still go to the open file.
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"line 2\n", "line 3\n"} {
+		n, err := lf.Write([]byte(line))
+		if err == nil || err == os.ErrClosed {
+			t.Errorf("expected the error of the rotation, got %v", err)
+		}
+		if n != len(line) {
+			t.Errorf("expected %d bytes written, got %d", len(line), n)
+		}
+	}
+	if err := lf.Close(); err != nil {
+		t.Errorf("expected the file to be open, got %v", err)
+	}
+}
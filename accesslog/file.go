@@ -0,0 +1,239 @@
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Time-based rotations of a File.
+const (
+	// RotateHourly rotates a file at the start of every hour.
+	RotateHourly = "hourly"
+	// RotateDaily rotates a file at midnight, local time.
+	RotateDaily = "daily"
+)
+
+// backupTimeFormat is the format of the time suffixing the rotated files.
+// Its lexical order is the chronological one.
+const backupTimeFormat = "20060102-150405.000"
+
+// Options describe when a File is rotated, and how many rotated files are kept.
+type Options struct {
+	// MaxSize is the size in bytes a file grows to before being rotated. No limit if 0.
+	MaxSize int64
+	// Rotate is RotateHourly or RotateDaily to rotate the file periodically, or empty.
+	Rotate string
+	// MaxBackups is the number of rotated files kept. All are kept if 0.
+	MaxBackups int
+	// MaxAge is how long rotated files are kept. They are kept forever if 0.
+	MaxAge time.Duration
+}
+
+// File is a log file rotated according to its options.
+//
+// When rotated, the file is renamed with the time of the rotation as suffix,
+// e.g access.log.20060102-150405.000, and a new file is created.
+type File struct {
+	name string
+	opts Options
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	period time.Time
+}
+
+// OpenFile opens the log file name for appending, creating it and its directory if needed.
+func OpenFile(name string, opts Options) (*File, error) {
+	switch opts.Rotate {
+	case "", RotateHourly, RotateDaily:
+	default:
+		return nil, fmt.Errorf("unknown rotation %q", opts.Rotate)
+	}
+	if opts.MaxSize < 0 || opts.MaxBackups < 0 || opts.MaxAge < 0 {
+		return nil, fmt.Errorf("negative rotation limits %+v", opts)
+	}
+	lf := &File{name: name, opts: opts}
+	if err := lf.open(); err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+// Name returns the name of the file.
+func (lf *File) Name() string {
+	return lf.name
+}
+
+func (lf *File) open() error {
+	f, size, err := lf.openFile()
+	if err != nil {
+		return err
+	}
+	lf.f = f
+	lf.size = size
+	lf.period = lf.periodStart(time.Now())
+	return nil
+}
+
+// openFile opens the file for appending, and returns its size.
+func (lf *File) openFile() (*os.File, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(lf.name), 0755); err != nil {
+		return nil, 0, err
+	}
+	f, err := os.OpenFile(lf.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
+}
+
+// periodStart returns the start of the rotation period t is in.
+func (lf *File) periodStart(t time.Time) time.Time {
+	switch lf.opts.Rotate {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// Write writes b to the file, rotating it first if b would exceed its maximum size,
+// or if its rotation period is over.
+// If the rotation fails, b is still written to the file, and the error of the rotation
+// is returned; the rotation is tried again on the next write.
+func (lf *File) Write(b []byte) (int, error) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.f == nil {
+		return 0, os.ErrClosed
+	}
+	now := time.Now()
+	var rotateErr error
+	if (lf.opts.MaxSize > 0 && lf.size > 0 && lf.size+int64(len(b)) > lf.opts.MaxSize) ||
+		!lf.periodStart(now).Equal(lf.period) {
+		rotateErr = lf.rotate(now)
+	}
+	n, err := lf.f.Write(b)
+	lf.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// Rotate rotates the file now.
+func (lf *File) Rotate() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.f == nil {
+		return os.ErrClosed
+	}
+	return lf.rotate(time.Now())
+}
+
+// rotate renames the file and opens a new one in its place.
+// The file is kept open until the new one is, so that if the rotation fails,
+// the log goes on in it.
+func (lf *File) rotate(now time.Time) error {
+	// The name of the backup must sort after the existing ones.
+	backups, err := lf.Backups()
+	if err != nil {
+		return err
+	}
+	var last string
+	if len(backups) > 0 {
+		last = backups[len(backups)-1]
+	}
+	backup := lf.name + "." + now.Format(backupTimeFormat)
+	for i := 1; backup <= last; i++ {
+		backup = fmt.Sprintf("%s.%s-%d", lf.name, now.Format(backupTimeFormat), i)
+	}
+	if err := os.Rename(lf.name, backup); err != nil {
+		return err
+	}
+	f, size, err := lf.openFile()
+	if err != nil {
+		// Put the file back, since it is still the one written to.
+		os.Rename(backup, lf.name)
+		return err
+	}
+	if err := lf.f.Close(); err != nil {
+		f.Close()
+		os.Rename(backup, lf.name)
+		return err
+	}
+	lf.f = f
+	lf.size = size
+	lf.period = lf.periodStart(now)
+	return lf.removeBackups(now)
+}
+
+// Backups returns the rotated files, oldest first.
+func (lf *File) Backups() ([]string, error) {
+	matches, err := filepath.Glob(lf.name + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, m := range matches {
+		// The suffix is the time of the rotation, optionally followed by -N.
+		suffix := strings.TrimPrefix(m, lf.name+".")
+		if len(suffix) > len(backupTimeFormat) && suffix[len(backupTimeFormat)] == '-' {
+			suffix = suffix[:len(backupTimeFormat)]
+		}
+		if _, err := time.Parse(backupTimeFormat, suffix); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// removeBackups removes the rotated files exceeding the retention limits.
+func (lf *File) removeBackups(now time.Time) error {
+	if lf.opts.MaxBackups == 0 && lf.opts.MaxAge == 0 {
+		return nil
+	}
+	backups, err := lf.Backups()
+	if err != nil {
+		return err
+	}
+	for i, backup := range backups {
+		remove := lf.opts.MaxBackups > 0 && i < len(backups)-lf.opts.MaxBackups
+		if !remove && lf.opts.MaxAge > 0 {
+			if fi, err := os.Stat(backup); err == nil && now.Sub(fi.ModTime()) > lf.opts.MaxAge {
+				remove = true
+			}
+		}
+		if remove {
+			if err := os.Remove(backup); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the file.
+func (lf *File) Close() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.f == nil {
+		return nil
+	}
+	err := lf.f.Close()
+	lf.f = nil
+	return err
+}
@@ -0,0 +1,108 @@
+// Package accesslog writes the access logs of kraken servers, in the Common Log Format,
+// the Combined Log Format or as JSON Lines, to files rotated by size or time.
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is the format of the lines of an access log.
+type Format string
+
+const (
+	// FormatCommon is the Common Log Format of the NCSA httpd:
+	//   host ident authuser [date] "request" status bytes
+	FormatCommon Format = "common"
+	// FormatCombined is the Common Log Format followed by the referer and user agent of the request.
+	FormatCombined Format = "combined"
+	// FormatJSON logs each request as a JSON object on its own line.
+	FormatJSON Format = "json"
+)
+
+// DefaultFormat is the format of an access log whose format is not set.
+const DefaultFormat = FormatCombined
+
+// ParseFormat returns the format named s, DefaultFormat if s is empty.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case "":
+		return DefaultFormat, nil
+	case FormatCommon, FormatCombined, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown access log format %q", s)
+}
+
+// Entry is a request logged in an access log.
+type Entry struct {
+	Time       time.Time     `json:"time"`
+	RemoteAddr string        `json:"remote_addr"`
+	User       string        `json:"user,omitempty"`
+	Method     string        `json:"method"`
+	Host       string        `json:"host"`
+	URI        string        `json:"uri"`
+	Proto      string        `json:"proto"`
+	Status     int           `json:"status"`
+	Bytes      int64         `json:"bytes"`
+	Referer    string        `json:"referer,omitempty"`
+	UserAgent  string        `json:"user_agent,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// Line returns entry formatted as a line of an access log in format f,
+// terminated by a newline.
+func (f Format) Line(entry *Entry) []byte {
+	if f == FormatJSON {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			// An entry holds nothing json can't encode.
+			panic(err)
+		}
+		return append(b, '\n')
+	}
+	host, _, err := net.SplitHostPort(entry.RemoteAddr)
+	if err != nil {
+		host = entry.RemoteAddr
+	}
+	b := make([]byte, 0, 3*len(entry.URI)/2+128)
+	b = append(b, orDash(host)...)
+	b = append(b, " - "...)
+	b = append(b, orDash(entry.User)...)
+	b = append(b, " ["...)
+	b = append(b, entry.Time.Format(clfTimeFormat)...)
+	b = append(b, "] \""...)
+	b = appendQuoted(b, entry.Method+" "+entry.URI+" "+entry.Proto)
+	b = append(b, "\" "...)
+	b = strconv.AppendInt(b, int64(entry.Status), 10)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, entry.Bytes, 10)
+	if f == FormatCombined {
+		b = append(b, " \""...)
+		b = appendQuoted(b, entry.Referer)
+		b = append(b, "\" \""...)
+		b = appendQuoted(b, entry.UserAgent)
+		b = append(b, '"')
+	}
+	return append(b, '\n')
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// appendQuoted appends s to b, escaping the quotes, backslashes and control characters,
+// so that a client can't forge log lines.
+func appendQuoted(b []byte, s string) []byte {
+	q := strconv.Quote(s)
+	return append(b, strings.TrimSuffix(strings.TrimPrefix(q, `"`), `"`)...)
+}
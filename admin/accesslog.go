@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vincent-petithory/kraken"
+	"github.com/vincent-petithory/kraken/accesslog"
+)
+
+// accessLogger writes the access log of a server to a file,
+// or to the log of the server pool handler if it has none.
+type accessLogger struct {
+	mu     sync.Mutex
+	config AccessLog
+	format accesslog.Format
+	file   *accesslog.File
+}
+
+func newAccessLogger(config AccessLog) (*accessLogger, error) {
+	al := &accessLogger{}
+	if err := al.configure(config); err != nil {
+		return nil, err
+	}
+	return al, nil
+}
+
+// configure opens the file of config, and writes the access log to it from now on.
+// The previous file is closed.
+func (al *accessLogger) configure(config AccessLog) error {
+	format, err := accesslog.ParseFormat(config.Format)
+	if err != nil {
+		return err
+	}
+	config.Format = string(format)
+	var file *accesslog.File
+	if config.File != "" {
+		if config.File, err = filepath.Abs(config.File); err != nil {
+			return err
+		}
+		file, err = accesslog.OpenFile(config.File, accesslog.Options{
+			MaxSize:    int64(config.MaxSizeMb) << 20,
+			Rotate:     config.Rotate,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     time.Duration(config.MaxAgeDays) * 24 * time.Hour,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	al.mu.Lock()
+	prev := al.file
+	al.config, al.format, al.file = config, format, file
+	al.mu.Unlock()
+	if prev != nil {
+		return prev.Close()
+	}
+	return nil
+}
+
+func (al *accessLogger) settings() *AccessLog {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	config := al.config
+	return &config
+}
+
+func (al *accessLogger) close() error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	if al.file == nil {
+		return nil
+	}
+	err := al.file.Close()
+	al.file = nil
+	return err
+}
+
+// accessLogHandler logs the requests served by h for srv with the access logger of srv.
+// Without a file, they are logged by the logger of sph.
+func (sph *ServerPoolHandler) accessLogHandler(srv *kraken.Server, al *accessLogger, h http.Handler) http.Handler {
+	return logRequests(h, func(entry *accesslog.Entry, mountTarget string) {
+		al.mu.Lock()
+		defer al.mu.Unlock()
+		if al.file == nil {
+			logRequest(sph.srvLogger(srv), "request", entry, mountTarget)
+			return
+		}
+		if _, err := al.file.Write(al.format.Line(entry)); err != nil {
+			sph.srvLogger(srv).Error("unable to write access log", "file", al.config.File, "err", err)
+		}
+	})
+}
+
+// serverAccessLog returns the server listening on serverPort and its access logger.
+func (sph *ServerPoolHandler) serverAccessLog(serverPort string) (int, *kraken.Server, *accessLogger, error) {
+	port, err := strconv.Atoi(serverPort)
+	if err != nil {
+		return http.StatusBadRequest, nil, nil, err
+	}
+	srv := sph.ServerPool.Get(uint16(port))
+	sph.accessLogsMu.Lock()
+	al := sph.accessLogs[uint16(port)]
+	sph.accessLogsMu.Unlock()
+	if srv == nil || al == nil {
+		return http.StatusNotFound, nil, nil, fmt.Errorf("server %q not found", serverPort)
+	}
+	return http.StatusOK, srv, al, nil
+}
+
+func (sph *ServerPoolHandler) setAccessLog(port uint16, al *accessLogger) {
+	sph.accessLogsMu.Lock()
+	defer sph.accessLogsMu.Unlock()
+	if sph.accessLogs == nil {
+		sph.accessLogs = make(map[uint16]*accessLogger)
+	}
+	sph.accessLogs[port] = al
+}
+
+// closeAccessLog closes the access log of srv, once it is removed.
+func (sph *ServerPoolHandler) closeAccessLog(srv *kraken.Server) {
+	sph.accessLogsMu.Lock()
+	al := sph.accessLogs[srv.Port]
+	delete(sph.accessLogs, srv.Port)
+	sph.accessLogsMu.Unlock()
+	if al == nil {
+		return
+	}
+	if err := al.close(); err != nil {
+		sph.srvLogger(srv).Error("unable to close access log", "err", err)
+	}
+}
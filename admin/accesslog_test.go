@@ -0,0 +1,100 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vincent-petithory/kraken/accesslog"
+	"github.com/vincent-petithory/kraken/admin"
+)
+
+func TestAccessLog(t *testing.T) {
+	c, ts := newTestAdmin(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	dir, err := ioutil.TempDir("", "kraken-accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jsonLog := filepath.Join(dir, "logs", "access.jsonl")
+	commonLog := filepath.Join(dir, "logs", "access.log")
+
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{
+		BindAddress: "127.0.0.1",
+		AccessLog:   admin.AccessLog{File: jsonLog, Format: "json", MaxSizeMb: 10, MaxBackups: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(srv.Port)
+	if _, err := c.PostServersOneMounts(port, &admin.CreateMountIn{Source: dir, Target: "/files"}); err != nil {
+		t.Fatal(err)
+	}
+	get(t, fmt.Sprintf("http://127.0.0.1:%d/files/hello.txt?x=1", srv.Port))
+
+	b, err := ioutil.ReadFile(jsonLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry accesslog.Entry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Method != "GET" || entry.URI != "/files/hello.txt?x=1" || entry.Status != 200 || entry.Bytes != 5 {
+		t.Errorf("unexpected access log entry %+v", entry)
+	}
+
+	settings, err := c.PutServersOneAccesslog(port, &admin.UpdateAccessLogIn{File: commonLog, Format: "common", Rotate: "daily"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.File != commonLog || settings.Format != "common" || settings.Rotate != "daily" {
+		t.Errorf("unexpected access log settings %+v", settings)
+	}
+	get(t, fmt.Sprintf("http://127.0.0.1:%d/files/missing.txt", srv.Port))
+	if b, err := ioutil.ReadFile(commonLog); err != nil {
+		t.Fatal(err)
+	} else if line := string(b); !strings.HasPrefix(line, "127.0.0.1 - - [") || !strings.Contains(line, `"GET /files/missing.txt HTTP/1.1" 404 `) {
+		t.Errorf("unexpected access log line %q", line)
+	}
+	if b, err := ioutil.ReadFile(jsonLog); err != nil {
+		t.Fatal(err)
+	} else if n := strings.Count(string(b), "\n"); n != 1 {
+		t.Errorf("expected the previous access log to hold 1 line, got %d", n)
+	}
+
+	if settings, err := c.DeleteServersOneAccesslog(port); err != nil {
+		t.Fatal(err)
+	} else if settings.File != commonLog {
+		t.Errorf("expected the removed access log to be %s, got %s", commonLog, settings.File)
+	}
+	if settings, err := c.GetServersOneAccesslog(port); err != nil {
+		t.Fatal(err)
+	} else if settings.File != "" || settings.Format != "common" {
+		t.Errorf("expected no access log file, got %+v", settings)
+	}
+
+	for _, config := range []admin.UpdateAccessLogIn{
+		{Format: "apache"},
+		{File: commonLog, Rotate: "weekly"},
+		{File: commonLog, MaxBackups: -1},
+	} {
+		if _, err := c.PutServersOneAccesslog(port, &config); err == nil {
+			t.Errorf("expected an error setting access log %+v", config)
+		}
+	}
+	if _, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1", AccessLog: admin.AccessLog{Format: "apache"}}); err == nil {
+		t.Error("expected an error creating a server with an unknown access log format")
+	}
+}
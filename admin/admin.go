@@ -1,17 +1,22 @@
 package admin
 
 import (
-	"crypto/sha1"
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/vincent-petithory/kraken"
+	"github.com/vincent-petithory/kraken/accesslog"
 	"github.com/vincent-petithory/kraken/fileserver"
 )
 
@@ -25,13 +30,36 @@ func (r RouteEvents) Location(rr RouteReverser) *url.URL {
 }
 
 //go:generate dispel -t all -hrt *ServerPoolHandler -d all schema.json
+//go:generate go run genopenapi.go
 
 type ServerPoolHandler struct {
 	*kraken.ServerPool
-	Log    *log.Logger
-	h      http.Handler
-	router *GorillaRouter
-	events *serverPoolEventsHandler
+	// Logger logs the activity of the API and of the servers.
+	// If nil, the logger of the server pool is used.
+	Logger *slog.Logger
+	// HookCommands enables the command hooks. They run any command
+	// given through the API, so they are rejected unless it is set.
+	HookCommands bool
+
+	h       http.Handler
+	router  *GorillaRouter
+	started time.Time
+	events  *serverPoolEventsHandler
+	metrics *metrics
+
+	statsMu    sync.Mutex
+	savedStats map[uint16]*kraken.ServerStats
+
+	hooksMu     sync.Mutex
+	hooks       []*hook
+	hooksLastID int
+
+	accessLogsMu sync.Mutex
+	accessLogs   map[uint16]*accessLogger
+
+	transfersMu     sync.Mutex
+	transfers       map[string]*transfer
+	transfersLastID uint64
 }
 
 func NewServerPoolRoutes(baseURL *url.URL) RouteReverser {
@@ -40,12 +68,25 @@ func NewServerPoolRoutes(baseURL *url.URL) RouteReverser {
 		BaseURL: baseURL,
 	}
 	registerRoutes(router)
+	registerAdditionalRoutes(router)
 	return router
 }
 
+// registerAdditionalRoutes registers the routes not described in the schema.
+func registerAdditionalRoutes(rr RouteRegisterer) {
+	rr.RegisterRoute("/events", routeEvents)
+	rr.RegisterRoute("/events/history", routeEventsHistory)
+	rr.RegisterRoute("/metrics", routeMetrics)
+	rr.RegisterRoute("/healthz", routeHealthz)
+	rr.RegisterRoute("/readyz", routeReadyz)
+	rr.RegisterRoute("/version", routeVersion)
+	rr.RegisterRoute("/openapi.json", routeOpenAPI)
+}
+
 func NewServerPoolHandler(serverPool *kraken.ServerPool, baseURL *url.URL) *ServerPoolHandler {
 	sph := ServerPoolHandler{
 		ServerPool: serverPool,
+		started:    time.Now(),
 		router: &GorillaRouter{
 			Router:  mux.NewRouter(),
 			BaseURL: baseURL,
@@ -56,8 +97,11 @@ func NewServerPoolHandler(serverPool *kraken.ServerPool, baseURL *url.URL) *Serv
 			sub:     make(chan *conn),
 			unsub:   make(chan *conn),
 			eventCh: make(chan *Event),
+			history: newEventHistory(EventHistorySize),
 		},
 	}
+	sph.events.routes = sph.router
+	sph.metrics = newMetrics(sph.events)
 
 	resHandler := func(h http.Handler) http.Handler {
 		return handlers.CompressHandler(
@@ -81,21 +125,18 @@ func NewServerPoolHandler(serverPool *kraken.ServerPool, baseURL *url.URL) *Serv
 		sph.endpointHandler,
 	)
 
-	sph.router.RegisterRoute("/events", routeEvents)
+	registerAdditionalRoutes(sph.router)
 	sph.router.RegisterHandler(routeEvents, sph.events)
-
-	logger := func(h http.Handler) http.Handler {
-		dw := &dynamicWriter{func(b []byte) (int, error) {
-			if sph.Log != nil {
-				sph.Log.Printf("%s", b)
-			} else {
-				log.Printf("%s", b)
-			}
-			return len(b), nil
-		}}
-		return handlers.CombinedLoggingHandler(dw, h)
-	}
-	sph.h = logger(sph.router)
+	sph.router.RegisterHandler(routeEventsHistory, http.HandlerFunc(sph.events.serveHistory))
+	sph.router.RegisterHandler(routeMetrics, sph.metrics.Handler())
+	sph.router.RegisterHandler(routeHealthz, http.HandlerFunc(sph.serveHealthz))
+	sph.router.RegisterHandler(routeReadyz, http.HandlerFunc(sph.serveReadyz))
+	sph.router.RegisterHandler(routeVersion, http.HandlerFunc(sph.serveVersion))
+	sph.router.RegisterHandler(routeOpenAPI, http.HandlerFunc(serveOpenAPI))
+
+	sph.h = logRequests(sph.router, func(entry *accesslog.Entry, _ string) {
+		logRequest(sph.logger(), "api request", entry, "")
+	})
 
 	go sph.events.Broadcast()
 	return &sph
@@ -105,39 +146,16 @@ func (sph *ServerPoolHandler) endpointHandler(f errorHTTPHandlerFunc) http.Handl
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		status, err := f(w, r)
 		if err != nil {
-			log.Printf("HTTP Status %d: %v", status, err)
+			level := slog.LevelWarn
+			if status >= 500 {
+				level = slog.LevelError
+			}
+			sph.logger().Log(r.Context(), level, "api error", "method", r.Method, "uri", r.RequestURI, "status", status, "err", err)
 			http.Error(w, err.Error(), status)
 		}
 	})
 }
 
-func (sph *ServerPoolHandler) logf(format string, args ...interface{}) {
-	if sph.Log != nil {
-		sph.Log.Printf(format, args...)
-	} else {
-		log.Printf(format, args...)
-	}
-}
-
-func (sph *ServerPoolHandler) logErr(v interface{}) {
-	if sph.Log != nil {
-		sph.Log.Printf("[error] %v", v)
-	} else {
-		log.Printf("[error] %v", v)
-	}
-}
-
-func (sph *ServerPoolHandler) logfSrv(srv *kraken.Server, format string, args ...interface{}) {
-	sph.logf(
-		fmt.Sprintf("[srv %d] %s", srv.Port, format),
-		args...,
-	)
-}
-
-func (sph *ServerPoolHandler) logErrSrv(srv *kraken.Server, v interface{}) {
-	sph.logfSrv(srv, "ERR: %v", v)
-}
-
 func (sph *ServerPoolHandler) BaseURL() *url.URL {
 	return &(*(sph.router.BaseURL))
 }
@@ -161,75 +179,133 @@ func newServerDataFromServer(srv *kraken.Server) *Server {
 		BindAddress: host,
 		Port:        int(srv.Port),
 		Mounts:      mounts,
+		ErrorPages:  ErrorPages(srv.MountMap.ErrorPages.Files()),
+		RootIndex:   srv.MountMap.RootIndex,
 	}
 }
 
 func mountID(target string) string {
-	h := sha1.New()
-	h.Write([]byte(target))
-	b := h.Sum(nil)
-	return fmt.Sprintf("%x", b)[0:7]
+	return kraken.MountID(target)
 }
 
-func (sph *ServerPoolHandler) addAndStartSrv(bindAddress string, port string) (*kraken.Server, error) {
+// addAndStartSrv adds a server and starts it. It writes its access log with al,
+// which is closed if the server can't be started.
+func (sph *ServerPoolHandler) addAndStartSrv(bindAddress string, port string, errorPages *fileserver.ErrorPages, rootIndex bool, al *accessLogger) (*kraken.Server, error) {
 	addr := net.JoinHostPort(bindAddress, port)
 	srv, err := sph.ServerPool.Add(addr)
 	if err != nil {
+		if err := al.close(); err != nil {
+			sph.logger().Error("unable to close access log", "addr", addr, "err", err)
+		}
 		return nil, err
 	}
+	srv.MountMap.ErrorPages = errorPages
+	srv.MountMap.RootIndex = rootIndex
+	if p, err := strconv.Atoi(port); err == nil && p != 0 {
+		sph.restoreStats(srv, uint16(p))
+	}
 
-	// Add middlewares to the server
+	// Add middlewares to the server. They share the recording of the response, see recordResponse.
 	srv.HandlerWrapper = func(handler http.Handler) http.Handler {
-		logger := func(h http.Handler) http.Handler {
-			dw := &dynamicWriter{func(b []byte) (int, error) {
-				sph.logfSrv(srv, "%s", b)
-				return len(b), nil
-			}}
-			return handlers.CombinedLoggingHandler(dw, h)
-		}
-		eventsLogger := func(h http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				rsl := &responseStatusLogger{ResponseWriter: w}
-				h.ServeHTTP(rsl, r)
-				sph.events.Send(Event{EventTypeFileServe, FileServeEvent{*newServerDataFromServer(srv), r.URL.Path, rsl.Status}})
-			})
-		}
-		return logger(eventsLogger(handler))
+		h := sph.fileServeEvents(srv, sph.metrics.serverHandler(srv, statsHandler(srv, srv.Rewrites.Handler(handler))))
+		return sph.transfersHandler(srv, sph.accessLogHandler(srv, al, h))
 	}
+	srv.ConnState = sph.metrics.connState(srv)
 
+	// The caller reports the failures: the server is not kept as a failed one.
 	if ok := sph.ServerPool.StartSrv(srv); !ok {
-		sph.logErrSrv(srv, "unable to start server")
+		sph.ServerPool.Discard(srv)
+		sph.logger().Error("unable to start server", "addr", srv.Addr)
+		if err := al.close(); err != nil {
+			sph.logger().Error("unable to close access log", "addr", srv.Addr, "err", err)
+		}
 		return nil, fmt.Errorf("unable to start server on port %d", srv.Port)
 	}
 	// Wait for the server to be started
 	<-srv.Started
-	sph.logf("created server %q", srv.Addr)
-	sph.logfSrv(srv, "server available on http://%s", srv.Addr)
-	sph.events.Send(Event{EventTypeServerAdd, ServerEvent{*newServerDataFromServer(srv)}})
+	if err := srv.Err(); err != nil {
+		sph.ServerPool.Discard(srv)
+		if err := al.close(); err != nil {
+			sph.logger().Error("unable to close access log", "addr", srv.Addr, "err", err)
+		}
+		return nil, err
+	}
+	sph.setAccessLog(srv.Port, al)
+	sph.srvLogger(srv).Info("created server", "url", "http://"+srv.Addr)
+	sph.events.Send(Event{Type: EventTypeServerAdd, Resource: ServerEvent{*newServerDataFromServer(srv)}})
 	return srv, nil
 }
 
-type dynamicWriter struct {
-	wFn func([]byte) (int, error)
+// fileServeEvents sends a FileServeEvent for each request served by h for srv.
+func (sph *ServerPoolHandler) fileServeEvents(srv *kraken.Server, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		w, r, rsl := recordResponse(w, r)
+		r, target := kraken.WithMountTarget(r)
+		h.ServeHTTP(w, r)
+
+		fse := FileServeEvent{
+			Server:        *newServerDataFromServer(srv),
+			Path:          r.URL.Path,
+			Code:          rsl.Status,
+			Method:        r.Method,
+			RemoteAddr:    r.RemoteAddr,
+			UserAgent:     r.UserAgent(),
+			Bytes:         rsl.Size,
+			ContentLength: -1,
+			Duration:      time.Since(start),
+			Range:         r.Header.Get("Range") != "",
+		}
+		if fse.Code == 0 {
+			fse.Code = http.StatusOK
+		}
+		if *target != "" {
+			fse.Mount = mountID(*target)
+		}
+		if cl, err := strconv.ParseInt(rsl.Header().Get("Content-Length"), 10, 64); err == nil {
+			fse.ContentLength = cl
+		}
+		// The client went away if writing to it failed, its connection was closed
+		// while serving, or the body is shorter than announced.
+		fse.Aborted = rsl.WriteErr != nil || r.Context().Err() != nil ||
+			(r.Method != "HEAD" && fse.ContentLength >= 0 && fse.Bytes < fse.ContentLength)
+		sph.events.Send(Event{Type: EventTypeFileServe, Resource: fse})
+	})
 }
 
-func (dw *dynamicWriter) Write(b []byte) (int, error) {
-	if dw.wFn != nil {
-		return dw.wFn(b)
+type responseStatusLoggerKey struct{}
+
+// recordResponse returns w wrapped in a responseStatusLogger, and a shallow copy of r
+// which holds it, so that the middlewares serving r share it.
+// If r already holds one, w, r and it are returned as is: w is expected to write through it.
+func recordResponse(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, *responseStatusLogger) {
+	if rsl, ok := r.Context().Value(responseStatusLoggerKey{}).(*responseStatusLogger); ok {
+		return w, r, rsl
 	}
-	return ioutil.Discard.Write(b)
+	rsl := &responseStatusLogger{ResponseWriter: w}
+	return rsl, r.WithContext(context.WithValue(r.Context(), responseStatusLoggerKey{}, rsl)), rsl
 }
 
+// responseStatusLogger records the status and size of a response.
 type responseStatusLogger struct {
 	http.ResponseWriter
 	Status int
+	// Size is the number of bytes of the response body written.
+	Size int64
+	// WriteErr is the first error writing the response body, if any.
+	WriteErr error
 }
 
 func (rsl *responseStatusLogger) Write(b []byte) (int, error) {
 	if rsl.Status == 0 {
 		rsl.Status = http.StatusOK
 	}
-	return rsl.ResponseWriter.Write(b)
+	n, err := rsl.ResponseWriter.Write(b)
+	rsl.Size += int64(n)
+	if err != nil && rsl.WriteErr == nil {
+		rsl.WriteErr = err
+	}
+	return n, err
 }
 
 func (rsl *responseStatusLogger) WriteHeader(s int) {
@@ -237,6 +313,38 @@ func (rsl *responseStatusLogger) WriteHeader(s int) {
 	rsl.Status = s
 }
 
+// Flush lets streamed responses, e.g proxied ones, reach the client.
+func (rsl *responseStatusLogger) Flush() {
+	if f, ok := rsl.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets connections be taken over, e.g proxied websockets.
+// The status of a hijacked connection is logged as 101 Switching Protocols.
+func (rsl *responseStatusLogger) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := rsl.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil && rsl.Status == 0 {
+		rsl.Status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for use by http.ResponseController.
+func (rsl *responseStatusLogger) Unwrap() http.ResponseWriter {
+	return rsl.ResponseWriter
+}
+
 // Override this type from dispel
 
 type FsParams fileserver.Params
+
+type ErrorPages map[string]string
+
+type ParamSchema fileserver.ParamSchema
+
+type StatusCounts map[string]int
@@ -0,0 +1,222 @@
+package admin_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken"
+	"github.com/vincent-petithory/kraken/admin"
+	"github.com/vincent-petithory/kraken/admin/client"
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+// handlerServer is a fileserver.Server serving with a handler.
+type handlerServer struct {
+	http.HandlerFunc
+}
+
+func (hs handlerServer) Root() string {
+	return os.TempDir()
+}
+
+// newTestAdminWithType is like newTestAdmin, with a fileserver type typ serving with h.
+func newTestAdminWithType(t *testing.T, typ string, h http.HandlerFunc) (*client.Client, *httptest.Server) {
+	fsf := make(fileserver.Factory)
+	if err := fsf.Register(typ, func(root string, params fileserver.Params) (fileserver.Server, error) {
+		return handlerServer{h}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	serverPool := kraken.NewServerPool(fsf)
+	go serverPool.Listen()
+	ts := httptest.NewUnstartedServer(nil)
+	u, err := url.Parse("http://" + ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Config.Handler = admin.NewServerPoolHandler(serverPool, u)
+	ts.Start()
+	return client.New(u), ts
+}
+
+func TestResponseController(t *testing.T) {
+	// The middlewares of the servers let the mounts control the response.
+	c, ts := newTestAdminWithType(t, "controlled", func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Now().Add(time.Minute)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, "controlled")
+		if err := rc.Flush(); err != nil {
+			t.Error(err)
+		}
+	})
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(srv.Port)
+	if _, err := c.PostServersOneMounts(port, &admin.CreateMountIn{Source: os.TempDir(), Target: "/c", FsType: "controlled"}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/c/", srv.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, resp.StatusCode)
+	}
+	// The response is recorded once, and shared by the middlewares.
+	stats, err := c.GetServersOneStats(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Requests != 1 || stats.Bytes != len("controlled") || stats.Statuses["202"] != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestFileServeEvents(t *testing.T) {
+	c, ts := newTestAdmin(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	dir, err := ioutil.TempDir("", "kraken-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mount, err := c.PostServersOneMounts(strconv.Itoa(srv.Port), &admin.CreateMountIn{Source: dir, Target: "/files"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := fmt.Sprintf("http://127.0.0.1:%d/files/hello.txt", srv.Port)
+	for _, method := range []string{"GET", "HEAD"} {
+		req, err := http.NewRequest(method, u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", "kraken-test")
+		req.Header.Set("Range", "bytes=1-3")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	evts := waitEvents(t, c, 2, "file.serve")
+	if len(evts) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(evts))
+	}
+	for i, method := range []string{"GET", "HEAD"} {
+		fse, ok := evts[i].Resource.(*admin.FileServeEvent)
+		if !ok {
+			t.Fatalf("%s: expected a file serve event, got %T", method, evts[i].Resource)
+		}
+		if fse.Mount != mount.Id || fse.Path != "/hello.txt" || fse.Method != method || fse.Code != http.StatusPartialContent {
+			t.Errorf("%s: unexpected file serve event %+v", method, fse)
+		}
+		if host, _, err := net.SplitHostPort(fse.RemoteAddr); err != nil || host != "127.0.0.1" {
+			t.Errorf("%s: expected a remote address on 127.0.0.1, got %q", method, fse.RemoteAddr)
+		}
+		if fse.UserAgent != "kraken-test" || !fse.Range || fse.Duration <= 0 {
+			t.Errorf("%s: unexpected file serve event %+v", method, fse)
+		}
+		// HEAD requests announce a length, but write no body.
+		bytes := int64(3)
+		if method == "HEAD" {
+			bytes = 0
+		}
+		if fse.Bytes != bytes || fse.ContentLength != 3 || fse.Aborted {
+			t.Errorf("%s: expected a complete transfer of %d bytes, got %+v", method, bytes, fse)
+		}
+	}
+}
+
+func TestFileServeEventsAborted(t *testing.T) {
+	c, ts := newTestAdminWithType(t, "aborted", func(w http.ResponseWriter, r *http.Request) {
+		switch path.Base(r.URL.Path) {
+		case "short":
+			// The body is shorter than announced.
+			w.Header().Set("Content-Length", "10")
+			fmt.Fprint(w, "abc")
+		case "gone":
+			// The client goes away while the body is written.
+			buf := make([]byte, 32<<10)
+			for i := 0; i < 1<<12; i++ {
+				if _, err := w.Write(buf); err != nil {
+					return
+				}
+				http.NewResponseController(w).Flush()
+			}
+			t.Error("expected a write to fail")
+		}
+	})
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PostServersOneMounts(strconv.Itoa(srv.Port), &admin.CreateMountIn{Source: os.TempDir(), Target: "/a", FsType: "aborted"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"short", "gone"} {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/a/%s", srv.Port, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "gone" {
+			io.CopyN(ioutil.Discard, resp.Body, 1)
+		} else {
+			ioutil.ReadAll(resp.Body)
+		}
+		resp.Body.Close()
+	}
+
+	evts := waitEvents(t, c, 2, "file.serve")
+	if len(evts) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(evts))
+	}
+	for i, name := range []string{"short", "gone"} {
+		fse, ok := evts[i].Resource.(*admin.FileServeEvent)
+		if !ok {
+			t.Fatalf("%s: expected a file serve event, got %T", name, evts[i].Resource)
+		}
+		if !fse.Aborted {
+			t.Errorf("%s: expected an aborted transfer, got %+v", name, fse)
+		}
+	}
+	if fse := evts[0].Resource.(*admin.FileServeEvent); fse.Bytes != 3 || fse.ContentLength != 10 {
+		t.Errorf("short: expected 3 bytes of 10, got %+v", fse)
+	}
+}
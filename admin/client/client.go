@@ -264,34 +264,121 @@ func (c *Client) doRequestAndDecodeResponse(method string, route admin.RouteLoca
 //	return nil
 //}
 
-func (c *Client) ListenEvents(recvEvents chan *admin.Event, events ...string) error {
-	u := admin.RouteEvents{}.Location(c.routeReverser)
-	u.Scheme = "ws"
-	if len(events) > 0 {
-		var eventCodes []string
-		for _, evt := range events {
-			switch evt {
-			case "server":
-				eventCodes = append(eventCodes, []string{
-					strconv.Itoa(int(admin.EventTypeServerAdd)),
-					strconv.Itoa(int(admin.EventTypeServerRemove)),
-				}...)
-			case "mount":
-				eventCodes = append(eventCodes, []string{
-					strconv.Itoa(int(admin.EventTypeMountAdd)),
-					strconv.Itoa(int(admin.EventTypeMountRemove)),
-					strconv.Itoa(int(admin.EventTypeMountUpdate)),
-				}...)
-			case "fileserve":
-				eventCodes = append(eventCodes, strconv.Itoa(int(admin.EventTypeFileServe)))
-			default:
-				return fmt.Errorf("unknown event %q", evt)
-			}
+// routeQuery is a route location with query params.
+type routeQuery struct {
+	admin.RouteLocation
+	query url.Values
+}
+
+func (rq routeQuery) Location(rr admin.RouteReverser) *url.URL {
+	u := rq.RouteLocation.Location(rr)
+	u.RawQuery = rq.query.Encode()
+	return u
+}
+
+// eventsQuery returns the query params selecting the events named events.
+// See admin.MatchEventTypes.
+func eventsQuery(events []string) (url.Values, error) {
+	v := url.Values{}
+	if len(events) == 0 {
+		return v, nil
+	}
+	for _, evt := range events {
+		if _, err := admin.MatchEventTypes(evt); err != nil {
+			return nil, err
+		}
+	}
+	v.Set(admin.EventsQueryKey, strings.Join(events, ","))
+	return v, nil
+}
+
+// GetEventsHistory returns the recent events following the event with ID since, the most recent
+// limit ones if limit is positive. If events are given, only events of those kinds are returned.
+func (c *Client) GetEventsHistory(since uint64, limit int, events ...string) ([]admin.Event, error) {
+	v, err := eventsQuery(events)
+	if err != nil {
+		return nil, err
+	}
+	v.Set(admin.SinceQueryKey, strconv.FormatUint(since, 10))
+	if limit > 0 {
+		v.Set(admin.LimitQueryKey, strconv.Itoa(limit))
+	}
+	var dataOut []admin.Event
+	if err := c.doRequestAndDecodeResponse("GET", routeQuery{admin.RouteEventsHistory{}, v}, nil, http.StatusOK, &dataOut); err != nil {
+		return nil, err
+	}
+	return dataOut, nil
+}
+
+// GetHealthz returns the health of krakend.
+func (c *Client) GetHealthz() (*admin.Health, error) {
+	var dataOut admin.Health
+	if err := c.doRequestAndDecodeResponse("GET", admin.RouteHealthz{}, nil, http.StatusOK, &dataOut); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+// GetReadyz returns the readiness of krakend and of its servers.
+// If a server is not ready, the readiness is returned along with an error.
+func (c *Client) GetReadyz() (*admin.Health, error) {
+	resp, err := c.doRequest("GET", admin.RouteReadyz{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		if err := c.checkCode(resp, http.StatusOK); err != nil {
+			return nil, err
 		}
-		v := url.Values{}
-		v.Set(admin.EventsQueryKey, strings.Join(eventCodes, ","))
-		u.RawQuery = v.Encode()
 	}
+	var dataOut admin.Health
+	if err := json.NewDecoder(resp.Body).Decode(&dataOut); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return &dataOut, fmt.Errorf("krakend is %s", dataOut.Status)
+	}
+	return &dataOut, nil
+}
+
+// GetVersion returns the build info and uptime of krakend.
+func (c *Client) GetVersion() (*admin.Health, error) {
+	var dataOut admin.Health
+	if err := c.doRequestAndDecodeResponse("GET", admin.RouteVersion{}, nil, http.StatusOK, &dataOut); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+// ListenEvents sends the events received from now on recvEvents.
+// If events are given, only events of those kinds are received.
+func (c *Client) ListenEvents(recvEvents chan *admin.Event, events ...string) error {
+	v, err := eventsQuery(events)
+	if err != nil {
+		return err
+	}
+	return c.listenEvents(recvEvents, v)
+}
+
+// ListenEventsSince is like ListenEvents, but first replays the recent events
+// following the event with ID since. If epoch is not empty and is not the epoch
+// of the events anymore, e.g since krakend restarted, all the recent events are replayed.
+func (c *Client) ListenEventsSince(recvEvents chan *admin.Event, epoch string, since uint64, events ...string) error {
+	v, err := eventsQuery(events)
+	if err != nil {
+		return err
+	}
+	v.Set(admin.SinceQueryKey, strconv.FormatUint(since, 10))
+	if epoch != "" {
+		v.Set(admin.EpochQueryKey, epoch)
+	}
+	return c.listenEvents(recvEvents, v)
+}
+
+func (c *Client) listenEvents(recvEvents chan *admin.Event, query url.Values) error {
+	u := routeQuery{admin.RouteEvents{}, query}.Location(c.routeReverser)
+	u.Scheme = "ws"
 
 	conn, _, err := c.WSC.Dial(u.String(), nil)
 	if err != nil {
@@ -8,8 +8,8 @@ import (
 	"github.com/vincent-petithory/kraken/admin"
 )
 
-func (c *Client) GetFileservers() ([]string, error) {
-	var dataOut []string
+func (c *Client) GetFileservers() ([]admin.FileServerType, error) {
+	var dataOut []admin.FileServerType
 	if err := c.doRequestAndDecodeResponse(
 		"GET",
 		admin.RouteFileservers{},
@@ -22,6 +22,76 @@ func (c *Client) GetFileservers() ([]string, error) {
 	return dataOut, nil
 }
 
+func (c *Client) GetHooks() ([]admin.Hook, error) {
+	var dataOut []admin.Hook
+	if err := c.doRequestAndDecodeResponse(
+		"GET",
+		admin.RouteHooks{},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return dataOut, nil
+}
+
+func (c *Client) PostHooks(dataIn *admin.CreateHookIn) (*admin.Hook, error) {
+	var dataOut admin.Hook
+	if err := c.doRequestAndDecodeResponse(
+		"POST",
+		admin.RouteHooks{},
+		dataIn,
+		http.StatusCreated,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) DeleteHooks() ([]admin.Hook, error) {
+	var dataOut []admin.Hook
+	if err := c.doRequestAndDecodeResponse(
+		"DELETE",
+		admin.RouteHooks{},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return dataOut, nil
+}
+
+func (c *Client) GetHooksOne(hookId string) (*admin.Hook, error) {
+	var dataOut admin.Hook
+	if err := c.doRequestAndDecodeResponse(
+		"GET",
+		admin.RouteHooksOne{HookId: hookId},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) DeleteHooksOne(hookId string) (*admin.Hook, error) {
+	var dataOut admin.Hook
+	if err := c.doRequestAndDecodeResponse(
+		"DELETE",
+		admin.RouteHooksOne{HookId: hookId},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
 func (c *Client) GetServers() ([]admin.Server, error) {
 	var dataOut []admin.Server
 	if err := c.doRequestAndDecodeResponse(
@@ -106,6 +176,48 @@ func (c *Client) DeleteServersOne(serverPort string) (*admin.Server, error) {
 	return &dataOut, nil
 }
 
+func (c *Client) GetServersOneAccesslog(serverPort string) (*admin.AccessLog, error) {
+	var dataOut admin.AccessLog
+	if err := c.doRequestAndDecodeResponse(
+		"GET",
+		admin.RouteServersOneAccesslog{ServerPort: serverPort},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) PutServersOneAccesslog(serverPort string, dataIn *admin.UpdateAccessLogIn) (*admin.AccessLog, error) {
+	var dataOut admin.AccessLog
+	if err := c.doRequestAndDecodeResponse(
+		"PUT",
+		admin.RouteServersOneAccesslog{ServerPort: serverPort},
+		dataIn,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) DeleteServersOneAccesslog(serverPort string) (*admin.AccessLog, error) {
+	var dataOut admin.AccessLog
+	if err := c.doRequestAndDecodeResponse(
+		"DELETE",
+		admin.RouteServersOneAccesslog{ServerPort: serverPort},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
 func (c *Client) GetServersOneMounts(serverPort string) ([]admin.Mount, error) {
 	var dataOut []admin.Mount
 	if err := c.doRequestAndDecodeResponse(
@@ -175,3 +287,171 @@ func (c *Client) DeleteServersOneMountsOne(serverPort string, mountId string) (*
 	}
 	return &dataOut, nil
 }
+
+func (c *Client) GetServersOneMountsOneStats(serverPort string, mountId string) (*admin.Stats, error) {
+	var dataOut admin.Stats
+	if err := c.doRequestAndDecodeResponse(
+		"GET",
+		admin.RouteServersOneMountsOneStats{ServerPort: serverPort, MountId: mountId},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) DeleteServersOneMountsOneStats(serverPort string, mountId string) (*admin.Stats, error) {
+	var dataOut admin.Stats
+	if err := c.doRequestAndDecodeResponse(
+		"DELETE",
+		admin.RouteServersOneMountsOneStats{ServerPort: serverPort, MountId: mountId},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) GetServersOneRewrites(serverPort string) ([]admin.Rewrite, error) {
+	var dataOut []admin.Rewrite
+	if err := c.doRequestAndDecodeResponse(
+		"GET",
+		admin.RouteServersOneRewrites{ServerPort: serverPort},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return dataOut, nil
+}
+
+func (c *Client) PostServersOneRewrites(serverPort string, dataIn *admin.CreateRewriteIn) (*admin.Rewrite, error) {
+	var dataOut admin.Rewrite
+	if err := c.doRequestAndDecodeResponse(
+		"POST",
+		admin.RouteServersOneRewrites{ServerPort: serverPort},
+		dataIn,
+		http.StatusCreated,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) DeleteServersOneRewrites(serverPort string) ([]admin.Rewrite, error) {
+	var dataOut []admin.Rewrite
+	if err := c.doRequestAndDecodeResponse(
+		"DELETE",
+		admin.RouteServersOneRewrites{ServerPort: serverPort},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return dataOut, nil
+}
+
+func (c *Client) GetServersOneRewritesOne(serverPort string, rewriteId string) (*admin.Rewrite, error) {
+	var dataOut admin.Rewrite
+	if err := c.doRequestAndDecodeResponse(
+		"GET",
+		admin.RouteServersOneRewritesOne{ServerPort: serverPort, RewriteId: rewriteId},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) DeleteServersOneRewritesOne(serverPort string, rewriteId string) (*admin.Rewrite, error) {
+	var dataOut admin.Rewrite
+	if err := c.doRequestAndDecodeResponse(
+		"DELETE",
+		admin.RouteServersOneRewritesOne{ServerPort: serverPort, RewriteId: rewriteId},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) GetServersOneStats(serverPort string) (*admin.Stats, error) {
+	var dataOut admin.Stats
+	if err := c.doRequestAndDecodeResponse(
+		"GET",
+		admin.RouteServersOneStats{ServerPort: serverPort},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) DeleteServersOneStats(serverPort string) (*admin.Stats, error) {
+	var dataOut admin.Stats
+	if err := c.doRequestAndDecodeResponse(
+		"DELETE",
+		admin.RouteServersOneStats{ServerPort: serverPort},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) GetTransfers() ([]admin.Transfer, error) {
+	var dataOut []admin.Transfer
+	if err := c.doRequestAndDecodeResponse(
+		"GET",
+		admin.RouteTransfers{},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return dataOut, nil
+}
+
+func (c *Client) GetTransfersOne(transferId string) (*admin.Transfer, error) {
+	var dataOut admin.Transfer
+	if err := c.doRequestAndDecodeResponse(
+		"GET",
+		admin.RouteTransfersOne{TransferId: transferId},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
+
+func (c *Client) DeleteTransfersOne(transferId string) (*admin.Transfer, error) {
+	var dataOut admin.Transfer
+	if err := c.doRequestAndDecodeResponse(
+		"DELETE",
+		admin.RouteTransfersOne{TransferId: transferId},
+		nil,
+		http.StatusOK,
+		&dataOut,
+	); err != nil {
+		return nil, err
+	}
+	return &dataOut, nil
+}
@@ -61,6 +61,57 @@ func registerHandlers(hr HandlerRegisterer, rpg RouteParamGetter, sph *ServerPoo
 			return status, he.Encode(w, r, vresp, status)
 		}),
 	})
+	hr.RegisterHandler(routeHooks, &MethodHandler{
+		Get: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			status, vresp, err := sph.getHooks(w, r)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+		Post: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			var vreq CreateHookIn
+			if err := hd.Decode(w, r, &vreq); err != nil {
+				return http.StatusBadRequest, err
+			}
+			status, vresp, err := sph.postHooks(w, r, &vreq)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+		Delete: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			status, vresp, err := sph.deleteHooks(w, r)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+	})
+	hr.RegisterHandler(routeHooksOne, &MethodHandler{
+		Get: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			hookId := rpg.GetRouteParam(r, "hook-id")
+			if hookId == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"hook-id\"")
+			}
+			status, vresp, err := sph.getHooksOne(w, r, hookId)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+		Delete: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			hookId := rpg.GetRouteParam(r, "hook-id")
+			if hookId == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"hook-id\"")
+			}
+			status, vresp, err := sph.deleteHooksOne(w, r, hookId)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+	})
 	hr.RegisterHandler(routeServers, &MethodHandler{
 		Get: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
 			status, vresp, err := sph.getServers(w, r)
@@ -127,6 +178,45 @@ func registerHandlers(hr HandlerRegisterer, rpg RouteParamGetter, sph *ServerPoo
 			return status, he.Encode(w, r, vresp, status)
 		}),
 	})
+	hr.RegisterHandler(routeServersOneAccesslog, &MethodHandler{
+		Get: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			status, vresp, err := sph.getServersOneAccesslog(w, r, serverPort)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+		Put: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			var vreq UpdateAccessLogIn
+			if err := hd.Decode(w, r, &vreq); err != nil {
+				return http.StatusBadRequest, err
+			}
+			status, vresp, err := sph.putServersOneAccesslog(w, r, serverPort, &vreq)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+		Delete: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			status, vresp, err := sph.deleteServersOneAccesslog(w, r, serverPort)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+	})
 	hr.RegisterHandler(routeServersOneMounts, &MethodHandler{
 		Get: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
 			serverPort := rpg.GetRouteParam(r, "server-port")
@@ -198,4 +288,164 @@ func registerHandlers(hr HandlerRegisterer, rpg RouteParamGetter, sph *ServerPoo
 			return status, he.Encode(w, r, vresp, status)
 		}),
 	})
+	hr.RegisterHandler(routeServersOneMountsOneStats, &MethodHandler{
+		Get: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			mountId := rpg.GetRouteParam(r, "mount-id")
+			if mountId == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"mount-id\"")
+			}
+			status, vresp, err := sph.getServersOneMountsOneStats(w, r, serverPort, mountId)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+		Delete: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			mountId := rpg.GetRouteParam(r, "mount-id")
+			if mountId == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"mount-id\"")
+			}
+			status, vresp, err := sph.deleteServersOneMountsOneStats(w, r, serverPort, mountId)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+	})
+	hr.RegisterHandler(routeServersOneRewrites, &MethodHandler{
+		Get: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			status, vresp, err := sph.getServersOneRewrites(w, r, serverPort)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+		Post: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			var vreq CreateRewriteIn
+			if err := hd.Decode(w, r, &vreq); err != nil {
+				return http.StatusBadRequest, err
+			}
+			status, vresp, err := sph.postServersOneRewrites(w, r, serverPort, &vreq)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+		Delete: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			status, vresp, err := sph.deleteServersOneRewrites(w, r, serverPort)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+	})
+	hr.RegisterHandler(routeServersOneRewritesOne, &MethodHandler{
+		Get: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			rewriteId := rpg.GetRouteParam(r, "rewrite-id")
+			if rewriteId == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"rewrite-id\"")
+			}
+			status, vresp, err := sph.getServersOneRewritesOne(w, r, serverPort, rewriteId)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+		Delete: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			rewriteId := rpg.GetRouteParam(r, "rewrite-id")
+			if rewriteId == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"rewrite-id\"")
+			}
+			status, vresp, err := sph.deleteServersOneRewritesOne(w, r, serverPort, rewriteId)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+	})
+	hr.RegisterHandler(routeServersOneStats, &MethodHandler{
+		Get: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			status, vresp, err := sph.getServersOneStats(w, r, serverPort)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+		Delete: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			serverPort := rpg.GetRouteParam(r, "server-port")
+			if serverPort == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"server-port\"")
+			}
+			status, vresp, err := sph.deleteServersOneStats(w, r, serverPort)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+	})
+	hr.RegisterHandler(routeTransfers, &MethodHandler{
+		Get: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			status, vresp, err := sph.getTransfers(w, r)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+	})
+	hr.RegisterHandler(routeTransfersOne, &MethodHandler{
+		Get: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			transferId := rpg.GetRouteParam(r, "transfer-id")
+			if transferId == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"transfer-id\"")
+			}
+			status, vresp, err := sph.getTransfersOne(w, r, transferId)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+		Delete: ehhf(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			transferId := rpg.GetRouteParam(r, "transfer-id")
+			if transferId == "" {
+				return http.StatusBadRequest, errors.New("empty route parameter \"transfer-id\"")
+			}
+			status, vresp, err := sph.deleteTransfersOne(w, r, transferId)
+			if err != nil {
+				return status, err
+			}
+			return status, he.Encode(w, r, vresp, status)
+		}),
+	})
 }
@@ -26,24 +26,49 @@ type RouteLocation interface {
 // registerRoutes uses rr to register the routes by path and name.
 func registerRoutes(rr RouteRegisterer) {
 	rr.RegisterRoute("/fileservers", routeFileservers)
+	rr.RegisterRoute("/hooks", routeHooks)
+	rr.RegisterRoute("/hooks/{hook-id}", routeHooksOne)
 	rr.RegisterRoute("/servers", routeServers)
 	rr.RegisterRoute("/servers/{server-port}", routeServersOne)
+	rr.RegisterRoute("/servers/{server-port}/accesslog", routeServersOneAccesslog)
 	rr.RegisterRoute("/servers/{server-port}/mounts", routeServersOneMounts)
 	rr.RegisterRoute("/servers/{server-port}/mounts/{mount-id}", routeServersOneMountsOne)
+	rr.RegisterRoute("/servers/{server-port}/mounts/{mount-id}/stats", routeServersOneMountsOneStats)
+	rr.RegisterRoute("/servers/{server-port}/rewrites", routeServersOneRewrites)
+	rr.RegisterRoute("/servers/{server-port}/rewrites/{rewrite-id}", routeServersOneRewritesOne)
+	rr.RegisterRoute("/servers/{server-port}/stats", routeServersOneStats)
+	rr.RegisterRoute("/transfers", routeTransfers)
+	rr.RegisterRoute("/transfers/{transfer-id}", routeTransfersOne)
 }
 
 const (
-	routeFileservers         = "fileservers"
-	routeServers             = "servers"
-	routeServersOne          = "servers.one"
-	routeServersOneMounts    = "servers.one.mounts"
-	routeServersOneMountsOne = "servers.one.mounts.one"
+	routeFileservers              = "fileservers"
+	routeHooks                    = "hooks"
+	routeHooksOne                 = "hooks.one"
+	routeServers                  = "servers"
+	routeServersOne               = "servers.one"
+	routeServersOneAccesslog      = "servers.one.accesslog"
+	routeServersOneMounts         = "servers.one.mounts"
+	routeServersOneMountsOne      = "servers.one.mounts.one"
+	routeServersOneMountsOneStats = "servers.one.mounts.one.stats"
+	routeServersOneRewrites       = "servers.one.rewrites"
+	routeServersOneRewritesOne    = "servers.one.rewrites.one"
+	routeServersOneStats          = "servers.one.stats"
+	routeTransfers                = "transfers"
+	routeTransfersOne             = "transfers.one"
 )
 
 type (
 	RouteFileservers struct{}
-	RouteServers     struct{}
-	RouteServersOne  struct {
+	RouteHooks       struct{}
+	RouteHooksOne    struct {
+		HookId string
+	}
+	RouteServers    struct{}
+	RouteServersOne struct {
+		ServerPort string
+	}
+	RouteServersOneAccesslog struct {
 		ServerPort string
 	}
 	RouteServersOneMounts struct {
@@ -53,20 +78,65 @@ type (
 		ServerPort string
 		MountId    string
 	}
+	RouteServersOneMountsOneStats struct {
+		ServerPort string
+		MountId    string
+	}
+	RouteServersOneRewrites struct {
+		ServerPort string
+	}
+	RouteServersOneRewritesOne struct {
+		ServerPort string
+		RewriteId  string
+	}
+	RouteServersOneStats struct {
+		ServerPort string
+	}
+	RouteTransfers    struct{}
+	RouteTransfersOne struct {
+		TransferId string
+	}
 )
 
 func (r RouteFileservers) Location(rr RouteReverser) *url.URL {
 	return rr.ReverseRoute(routeFileservers)
 }
+func (r RouteHooks) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeHooks)
+}
+func (r RouteHooksOne) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeHooksOne, "hook-id", r.HookId)
+}
 func (r RouteServers) Location(rr RouteReverser) *url.URL {
 	return rr.ReverseRoute(routeServers)
 }
 func (r RouteServersOne) Location(rr RouteReverser) *url.URL {
 	return rr.ReverseRoute(routeServersOne, "server-port", r.ServerPort)
 }
+func (r RouteServersOneAccesslog) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeServersOneAccesslog, "server-port", r.ServerPort)
+}
 func (r RouteServersOneMounts) Location(rr RouteReverser) *url.URL {
 	return rr.ReverseRoute(routeServersOneMounts, "server-port", r.ServerPort)
 }
 func (r RouteServersOneMountsOne) Location(rr RouteReverser) *url.URL {
 	return rr.ReverseRoute(routeServersOneMountsOne, "server-port", r.ServerPort, "mount-id", r.MountId)
 }
+func (r RouteServersOneMountsOneStats) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeServersOneMountsOneStats, "server-port", r.ServerPort, "mount-id", r.MountId)
+}
+func (r RouteServersOneRewrites) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeServersOneRewrites, "server-port", r.ServerPort)
+}
+func (r RouteServersOneRewritesOne) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeServersOneRewritesOne, "server-port", r.ServerPort, "rewrite-id", r.RewriteId)
+}
+func (r RouteServersOneStats) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeServersOneStats, "server-port", r.ServerPort)
+}
+func (r RouteTransfers) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeTransfers)
+}
+func (r RouteTransfersOne) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeTransfersOne, "transfer-id", r.TransferId)
+}
@@ -2,19 +2,84 @@
 
 package admin
 
+type AccessLog struct {
+	File       string `json:"file"`
+	Format     string `json:"format"`
+	MaxAgeDays int    `json:"max_age_days"`
+	MaxBackups int    `json:"max_backups"`
+	MaxSizeMb  int    `json:"max_size_mb"`
+	Rotate     string `json:"rotate"`
+}
+
+type CreateHookIn struct {
+	Command  []string `json:"command"`
+	Envelope string   `json:"envelope"`
+	Events   []string `json:"events"`
+	Mount    string   `json:"mount"`
+	Path     string   `json:"path"`
+	Port     int      `json:"port"`
+	Retries  int      `json:"retries"`
+	Secret   string   `json:"secret"`
+	Type     string   `json:"type"`
+	Url      string   `json:"url"`
+}
+
 type CreateMountIn struct {
 	FsParams FsParams `json:"fs_params"`
 	FsType   string   `json:"fs_type"`
 	Source   string   `json:"source"`
+	Sources  []string `json:"sources"`
 	Target   string   `json:"target"`
 }
 
 type CreateRandomServerIn struct {
-	BindAddress string `json:"bind_address"`
+	AccessLog   AccessLog  `json:"access_log"`
+	BindAddress string     `json:"bind_address"`
+	ErrorPages  ErrorPages `json:"error_pages"`
+	RootIndex   bool       `json:"root_index"`
+}
+
+type CreateRewriteIn struct {
+	Action   string `json:"action"`
+	Pattern  string `json:"pattern"`
+	Position int    `json:"position"`
+	Status   int    `json:"status"`
+	Target   string `json:"target"`
 }
 
 type CreateServerIn struct {
-	BindAddress string `json:"bind_address"`
+	AccessLog   AccessLog  `json:"access_log"`
+	BindAddress string     `json:"bind_address"`
+	ErrorPages  ErrorPages `json:"error_pages"`
+	RootIndex   bool       `json:"root_index"`
+}
+
+type FileStats struct {
+	Count int    `json:"count"`
+	Path  string `json:"path"`
+}
+
+type FileServerType struct {
+	Name   string      `json:"name"`
+	Params ParamSchema `json:"params"`
+}
+
+type Hook struct {
+	Command      []string `json:"command"`
+	Delivered    int      `json:"delivered"`
+	Dropped      int      `json:"dropped"`
+	Envelope     string   `json:"envelope"`
+	Events       []string `json:"events"`
+	Failed       int      `json:"failed"`
+	Id           string   `json:"id"`
+	LastDelivery string   `json:"last_delivery"`
+	LastError    string   `json:"last_error"`
+	Mount        string   `json:"mount"`
+	Path         string   `json:"path"`
+	Port         int      `json:"port"`
+	Retries      int      `json:"retries"`
+	Type         string   `json:"type"`
+	Url          string   `json:"url"`
 }
 
 type Mount struct {
@@ -23,8 +88,50 @@ type Mount struct {
 	Target string `json:"target"`
 }
 
+type Rewrite struct {
+	Action  string `json:"action"`
+	Id      string `json:"id"`
+	Pattern string `json:"pattern"`
+	Status  int    `json:"status"`
+	Target  string `json:"target"`
+}
+
 type Server struct {
-	BindAddress string  `json:"bind_address"`
-	Mounts      []Mount `json:"mounts"`
-	Port        int     `json:"port"`
+	BindAddress string     `json:"bind_address"`
+	ErrorPages  ErrorPages `json:"error_pages"`
+	Mounts      []Mount    `json:"mounts"`
+	Port        int        `json:"port"`
+	RootIndex   bool       `json:"root_index"`
+}
+
+type Stats struct {
+	Bytes         int          `json:"bytes"`
+	Requests      int          `json:"requests"`
+	Since         string       `json:"since"`
+	Statuses      StatusCounts `json:"statuses"`
+	TopFiles      []FileStats  `json:"top_files"`
+	UniqueClients int          `json:"unique_clients"`
+}
+
+type Transfer struct {
+	Bytes      int    `json:"bytes"`
+	Id         string `json:"id"`
+	Method     string `json:"method"`
+	Mount      string `json:"mount"`
+	Path       string `json:"path"`
+	Port       int    `json:"port"`
+	Rate       int    `json:"rate"`
+	RemoteAddr string `json:"remote_addr"`
+	Size       int    `json:"size"`
+	Started    string `json:"started"`
+	UserAgent  string `json:"user_agent"`
+}
+
+type UpdateAccessLogIn struct {
+	File       string `json:"file"`
+	Format     string `json:"format"`
+	MaxAgeDays int    `json:"max_age_days"`
+	MaxBackups int    `json:"max_backups"`
+	MaxSizeMb  int    `json:"max_size_mb"`
+	Rotate     string `json:"rotate"`
 }
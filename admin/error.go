@@ -12,9 +12,12 @@ import (
 
 type APIErrorType string
 
+// Types of an APIError.
 const (
-	apiErrTypeBadRequest  APIErrorType = "bad_request_error"
-	apiErrTypeAPIInternal              = "api_internal_error"
+	// APIErrorTypeBadRequest is the type of the errors of 4xx responses.
+	APIErrorTypeBadRequest APIErrorType = "bad_request_error"
+	// APIErrorTypeAPIInternal is the type of the errors of 5xx responses.
+	APIErrorTypeAPIInternal APIErrorType = "api_internal_error"
 )
 
 type APIError struct {
@@ -49,13 +52,13 @@ func (jr jsonRewriter) RewriteHeader(header http.Header, status int) {
 func (jr jsonRewriter) Rewrite(w io.Writer, b []byte, status int) {
 	aerr := APIError{Msg: string(b)}
 	if status >= 400 && status < 500 {
-		aerr.Type = apiErrTypeBadRequest
+		aerr.Type = APIErrorTypeBadRequest
 	} else if status >= 500 {
-		aerr.Type = apiErrTypeAPIInternal
+		aerr.Type = APIErrorTypeAPIInternal
 	}
 	b, err := json.MarshalIndent(aerr, "", "  ")
 	if err != nil {
-		jr.sph.logErr(err)
+		jr.sph.logger().Error("unable to encode API error", "err", err)
 		return
 	}
 	fmt.Fprint(w, string(b))
@@ -0,0 +1,29 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/vincent-petithory/kraken/admin/openapi"
+)
+
+func main() {
+	if os.Getenv("GOFILE") == "" || os.Getenv("GOPACKAGE") == "" {
+		log.Fatal("This should be run only by go generate")
+	}
+	schema, err := ioutil.ReadFile("schema.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	doc, err := openapi.Generate(schema)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("openapi.json", doc, 0666); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 
+	"github.com/vincent-petithory/kraken"
 	"github.com/vincent-petithory/kraken/fileserver"
 )
 
@@ -14,8 +18,18 @@ func (sph *ServerPoolHandler) writeLocation(w http.ResponseWriter, routeLocation
 	w.Header().Set("Location", routeLocation.Location(sph.router).String())
 }
 
-func (sph *ServerPoolHandler) getFileservers(w http.ResponseWriter, r *http.Request) (int, []string, error) {
-	return http.StatusOK, sph.ServerPool.Fsf.Types(), nil
+func (sph *ServerPoolHandler) getFileservers(w http.ResponseWriter, r *http.Request) (int, []FileServerType, error) {
+	types := sph.ServerPool.Fsf.Types()
+	sort.Strings(types)
+	fsts := make([]FileServerType, len(types))
+	for i, typ := range types {
+		schema, err := sph.ServerPool.Fsf.ParamSchema(typ)
+		if err != nil {
+			return http.StatusInternalServerError, nil, err
+		}
+		fsts[i] = FileServerType{Name: typ, Params: ParamSchema(schema)}
+	}
+	return http.StatusOK, fsts, nil
 }
 
 func (sph *ServerPoolHandler) getServers(w http.ResponseWriter, r *http.Request) (int, []Server, error) {
@@ -28,7 +42,15 @@ func (sph *ServerPoolHandler) getServers(w http.ResponseWriter, r *http.Request)
 }
 
 func (sph *ServerPoolHandler) postServers(w http.ResponseWriter, r *http.Request, vreq *CreateRandomServerIn) (int, *Server, error) {
-	srv, err := sph.addAndStartSrv(vreq.BindAddress, "0")
+	errorPages, err := fileserver.NewErrorPages(vreq.ErrorPages)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	al, err := newAccessLogger(vreq.AccessLog)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	srv, err := sph.addAndStartSrv(vreq.BindAddress, "0", errorPages, vreq.RootIndex, al)
 	if err != nil {
 		return http.StatusInternalServerError, nil, err
 	}
@@ -48,17 +70,20 @@ func (sph *ServerPoolHandler) deleteServers(w http.ResponseWriter, r *http.Reque
 		srvData := newServerDataFromServer(srv)
 		if ok, err := sph.ServerPool.Remove(srv.Port); err != nil {
 			errs = append(errs, err)
-			sph.logErrSrv(srv, err)
+			sph.srvLogger(srv).Error("unable to shut down server", "err", err)
 		} else if !ok {
 			err := fmt.Errorf("unable to shut down server on port %d", srv.Port)
-			sph.logErrSrv(srv, "unable to shut down server")
+			sph.srvLogger(srv).Error("unable to shut down server")
 			errs = append(errs, err)
 		} else {
-			sph.logfSrv(srv, "server shut down")
+			sph.srvLogger(srv).Info("server shut down")
+			sph.metrics.forget(srv.Port, "")
+			sph.closeAccessLog(srv)
 			srvs = append(srvs, *srvData)
 		}
-		sph.events.Send(Event{EventTypeServerRemove, ServerEvent{*srvData}})
+		sph.events.Send(Event{Type: EventTypeServerRemove, Resource: ServerEvent{*srvData}})
 	}
+	sph.ServerPool.ClearFailed()
 	if len(errs) > 0 {
 		var bufMsg bytes.Buffer
 		for _, err := range errs {
@@ -86,7 +111,15 @@ func (sph *ServerPoolHandler) putServersOne(w http.ResponseWriter, r *http.Reque
 	if err != nil {
 		return http.StatusBadRequest, nil, err
 	}
-	srv, err := sph.addAndStartSrv(vreq.BindAddress, strconv.Itoa(port))
+	errorPages, err := fileserver.NewErrorPages(vreq.ErrorPages)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	al, err := newAccessLogger(vreq.AccessLog)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	srv, err := sph.addAndStartSrv(vreq.BindAddress, strconv.Itoa(port), errorPages, vreq.RootIndex, al)
 	if err != nil {
 		return http.StatusInternalServerError, nil, err
 	}
@@ -106,15 +139,53 @@ func (sph *ServerPoolHandler) deleteServersOne(w http.ResponseWriter, r *http.Re
 	if ok, err := sph.ServerPool.Remove(srv.Port); err != nil {
 		return http.StatusInternalServerError, nil, err
 	} else if !ok {
-		sph.logErrSrv(srv, "unable to shut down server")
+		sph.srvLogger(srv).Error("unable to shut down server")
 		return http.StatusInternalServerError, nil, fmt.Errorf("unable to shut down server on port %d", srv.Port)
 	} else {
-		sph.logfSrv(srv, "server shut down")
+		sph.srvLogger(srv).Info("server shut down")
+		sph.metrics.forget(srv.Port, "")
+		sph.closeAccessLog(srv)
 	}
-	sph.events.Send(Event{EventTypeServerRemove, ServerEvent{*srvData}})
+	sph.events.Send(Event{Type: EventTypeServerRemove, Resource: ServerEvent{*srvData}})
 	return http.StatusNotImplemented, srvData, nil
 }
 
+func (sph *ServerPoolHandler) getServersOneAccesslog(w http.ResponseWriter, r *http.Request, serverPort string) (int, *AccessLog, error) {
+	status, _, al, err := sph.serverAccessLog(serverPort)
+	if err != nil {
+		return status, nil, err
+	}
+	return http.StatusOK, al.settings(), nil
+}
+
+func (sph *ServerPoolHandler) putServersOneAccesslog(w http.ResponseWriter, r *http.Request, serverPort string, vreq *UpdateAccessLogIn) (int, *AccessLog, error) {
+	status, srv, al, err := sph.serverAccessLog(serverPort)
+	if err != nil {
+		return status, nil, err
+	}
+	if err := al.configure(AccessLog(*vreq)); err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	settings := al.settings()
+	if settings.File != "" {
+		sph.srvLogger(srv).Info("writing access log", "file", settings.File, "format", settings.Format)
+	}
+	return http.StatusOK, settings, nil
+}
+
+func (sph *ServerPoolHandler) deleteServersOneAccesslog(w http.ResponseWriter, r *http.Request, serverPort string) (int, *AccessLog, error) {
+	status, srv, al, err := sph.serverAccessLog(serverPort)
+	if err != nil {
+		return status, nil, err
+	}
+	settings := al.settings()
+	if err := al.configure(AccessLog{Format: settings.Format}); err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	sph.srvLogger(srv).Info("stopped writing access log", "file", settings.File)
+	return http.StatusOK, settings, nil
+}
+
 func (sph *ServerPoolHandler) getServersOneMounts(w http.ResponseWriter, r *http.Request, serverPort string) (int, []Mount, error) {
 	port, err := strconv.Atoi(serverPort)
 	if err != nil {
@@ -145,7 +216,15 @@ func (sph *ServerPoolHandler) postServersOneMounts(w http.ResponseWriter, r *htt
 	if srv == nil {
 		return http.StatusNotFound, nil, fmt.Errorf("server %q not found", serverPort)
 	}
-	exists, err := srv.MountMap.Put(vreq.Target, vreq.Source, vreq.FsType, fileserver.Params(vreq.FsParams))
+	source := vreq.Source
+	if len(vreq.Sources) > 0 {
+		if source != "" {
+			return http.StatusBadRequest, nil, errors.New("source and sources are mutually exclusive")
+		}
+		// Layers of a union mount are stored as a list, like $PATH.
+		source = strings.Join(vreq.Sources, string(filepath.ListSeparator))
+	}
+	exists, err := srv.MountMap.Put(vreq.Target, source, vreq.FsType, fileserver.Params(vreq.FsParams))
 	if err != nil {
 		return http.StatusBadRequest, nil, err
 	}
@@ -156,11 +235,11 @@ func (sph *ServerPoolHandler) postServersOneMounts(w http.ResponseWriter, r *htt
 		Target: vreq.Target,
 	}
 	if exists {
-		sph.logfSrv(srv, "updated mount point %s: mount %s on http://%s%s", mount.Id, mount.Source, srv.Addr, mount.Target)
-		sph.events.Send(Event{EventTypeMountUpdate, MountEvent{*newServerDataFromServer(srv), mount}})
+		sph.srvLogger(srv).Info("updated mount point", "mount_id", mount.Id, "source", mount.Source, "url", "http://"+srv.Addr+mount.Target)
+		sph.events.Send(Event{Type: EventTypeMountUpdate, Resource: MountEvent{*newServerDataFromServer(srv), mount}})
 	} else {
-		sph.logfSrv(srv, "created mount point %s: mount %s on http://%s%s", mount.Id, mount.Source, srv.Addr, mount.Target)
-		sph.events.Send(Event{EventTypeMountAdd, MountEvent{*newServerDataFromServer(srv), mount}})
+		sph.srvLogger(srv).Info("created mount point", "mount_id", mount.Id, "source", mount.Source, "url", "http://"+srv.Addr+mount.Target)
+		sph.events.Send(Event{Type: EventTypeMountAdd, Resource: MountEvent{*newServerDataFromServer(srv), mount}})
 	}
 
 	sph.writeLocation(w, RouteServersOneMountsOne{ServerPort: strconv.Itoa(int(srv.Port)), MountId: mount.Id})
@@ -185,8 +264,10 @@ func (sph *ServerPoolHandler) deleteServersOneMounts(w http.ResponseWriter, r *h
 			Target: mountTarget,
 		}
 		if ok := srv.MountMap.DeleteTarget(mountTarget); ok {
-			sph.logfSrv(srv, "removed mount point %s", mountID(mountTarget))
-			sph.events.Send(Event{EventTypeMountRemove, MountEvent{*newServerDataFromServer(srv), mount}})
+			sph.srvLogger(srv).Info("removed mount point", "mount_id", mountID(mountTarget))
+			sph.metrics.forget(srv.Port, mount.Id)
+			srv.Stats.RemoveMount(mountTarget)
+			sph.events.Send(Event{Type: EventTypeMountRemove, Resource: MountEvent{*newServerDataFromServer(srv), mount}})
 			mounts = append(mounts, mount)
 		}
 	}
@@ -247,8 +328,276 @@ func (sph *ServerPoolHandler) deleteServersOneMountsOne(w http.ResponseWriter, r
 	if !ok {
 		return http.StatusNotFound, nil, fmt.Errorf("server %d has no mount target %q", srv.Port, mountTarget)
 	}
-	sph.logfSrv(srv, "removed mount point %s", mountId)
-	sph.events.Send(Event{EventTypeMountRemove, MountEvent{*newServerDataFromServer(srv), mount}})
+	sph.srvLogger(srv).Info("removed mount point", "mount_id", mountId)
+	sph.metrics.forget(srv.Port, mountId)
+	srv.Stats.RemoveMount(mountTarget)
+	sph.events.Send(Event{Type: EventTypeMountRemove, Resource: MountEvent{*newServerDataFromServer(srv), mount}})
 
 	return http.StatusOK, &mount, nil
 }
+
+func (sph *ServerPoolHandler) getServersOneMountsOneStats(w http.ResponseWriter, r *http.Request, serverPort string, mountId string) (int, *Stats, error) {
+	return sph.mountStats(r, serverPort, mountId, false)
+}
+
+func (sph *ServerPoolHandler) deleteServersOneMountsOneStats(w http.ResponseWriter, r *http.Request, serverPort string, mountId string) (int, *Stats, error) {
+	return sph.mountStats(r, serverPort, mountId, true)
+}
+
+// mountStats returns the stats of a mount, and resets them if reset is true.
+func (sph *ServerPoolHandler) mountStats(r *http.Request, serverPort string, mountId string, reset bool) (int, *Stats, error) {
+	port, err := strconv.Atoi(serverPort)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	srv := sph.ServerPool.Get(uint16(port))
+	if srv == nil {
+		return http.StatusNotFound, nil, fmt.Errorf("server %q not found", serverPort)
+	}
+	var mountTarget string
+	for _, mt := range srv.MountMap.Targets() {
+		if mountID(mt) == mountId {
+			mountTarget = mt
+			break
+		}
+	}
+	if mountTarget == "" {
+		return http.StatusNotFound, nil, fmt.Errorf("server %d has no mount %q", srv.Port, mountId)
+	}
+	top, err := statsTopParam(r)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	stats := srv.Stats.Mount(mountTarget)
+	data := newStatsDataFromSnapshot(stats.Snapshot(top))
+	if reset {
+		stats.Reset()
+		sph.srvLogger(srv).Info("reset stats", "mount_id", mountId)
+	}
+	return http.StatusOK, data, nil
+}
+
+func newRewriteDataFromRule(rule *kraken.RewriteRule) *Rewrite {
+	return &Rewrite{
+		Id:      rule.ID,
+		Pattern: rule.Pattern.String(),
+		Action:  rule.Action,
+		Target:  rule.Target,
+		Status:  rule.Status,
+	}
+}
+
+func (sph *ServerPoolHandler) getServersOneRewrites(w http.ResponseWriter, r *http.Request, serverPort string) (int, []Rewrite, error) {
+	port, err := strconv.Atoi(serverPort)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	srv := sph.ServerPool.Get(uint16(port))
+	if srv == nil {
+		return http.StatusNotFound, nil, fmt.Errorf("server %q not found", serverPort)
+	}
+	rules := srv.Rewrites.Rules()
+	rewrites := make([]Rewrite, len(rules))
+	for i, rule := range rules {
+		rewrites[i] = *newRewriteDataFromRule(rule)
+	}
+	return http.StatusOK, rewrites, nil
+}
+
+func (sph *ServerPoolHandler) postServersOneRewrites(w http.ResponseWriter, r *http.Request, serverPort string, vreq *CreateRewriteIn) (int, *Rewrite, error) {
+	port, err := strconv.Atoi(serverPort)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	srv := sph.ServerPool.Get(uint16(port))
+	if srv == nil {
+		return http.StatusNotFound, nil, fmt.Errorf("server %q not found", serverPort)
+	}
+	rule, err := kraken.NewRewriteRule(vreq.Pattern, vreq.Action, vreq.Target, vreq.Status)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	srv.Rewrites.Add(rule, vreq.Position)
+	rewrite := newRewriteDataFromRule(rule)
+	sph.srvLogger(srv).Info("created rewrite rule", "rewrite_id", rewrite.Id, "pattern", rewrite.Pattern, "action", rewrite.Action, "target", rewrite.Target)
+
+	sph.writeLocation(w, RouteServersOneRewritesOne{ServerPort: strconv.Itoa(int(srv.Port)), RewriteId: rewrite.Id})
+	return http.StatusCreated, rewrite, nil
+}
+
+func (sph *ServerPoolHandler) deleteServersOneRewrites(w http.ResponseWriter, r *http.Request, serverPort string) (int, []Rewrite, error) {
+	port, err := strconv.Atoi(serverPort)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	srv := sph.ServerPool.Get(uint16(port))
+	if srv == nil {
+		return http.StatusNotFound, nil, fmt.Errorf("server %q not found", serverPort)
+	}
+	rules := srv.Rewrites.Clear()
+	rewrites := make([]Rewrite, len(rules))
+	for i, rule := range rules {
+		rewrites[i] = *newRewriteDataFromRule(rule)
+	}
+	sph.srvLogger(srv).Info("removed all rewrite rules")
+	return http.StatusOK, rewrites, nil
+}
+
+func (sph *ServerPoolHandler) getServersOneRewritesOne(w http.ResponseWriter, r *http.Request, serverPort string, rewriteId string) (int, *Rewrite, error) {
+	port, err := strconv.Atoi(serverPort)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	srv := sph.ServerPool.Get(uint16(port))
+	if srv == nil {
+		return http.StatusNotFound, nil, fmt.Errorf("server %q not found", serverPort)
+	}
+	rule := srv.Rewrites.Get(rewriteId)
+	if rule == nil {
+		return http.StatusNotFound, nil, fmt.Errorf("server %d has no rewrite rule %q", srv.Port, rewriteId)
+	}
+	return http.StatusOK, newRewriteDataFromRule(rule), nil
+}
+
+func (sph *ServerPoolHandler) deleteServersOneRewritesOne(w http.ResponseWriter, r *http.Request, serverPort string, rewriteId string) (int, *Rewrite, error) {
+	port, err := strconv.Atoi(serverPort)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	srv := sph.ServerPool.Get(uint16(port))
+	if srv == nil {
+		return http.StatusNotFound, nil, fmt.Errorf("server %q not found", serverPort)
+	}
+	rule := srv.Rewrites.Remove(rewriteId)
+	if rule == nil {
+		return http.StatusNotFound, nil, fmt.Errorf("server %d has no rewrite rule %q", srv.Port, rewriteId)
+	}
+	sph.srvLogger(srv).Info("removed rewrite rule", "rewrite_id", rewriteId)
+	return http.StatusOK, newRewriteDataFromRule(rule), nil
+}
+
+func (sph *ServerPoolHandler) getServersOneStats(w http.ResponseWriter, r *http.Request, serverPort string) (int, *Stats, error) {
+	return sph.serverStats(r, serverPort, false)
+}
+
+func (sph *ServerPoolHandler) deleteServersOneStats(w http.ResponseWriter, r *http.Request, serverPort string) (int, *Stats, error) {
+	return sph.serverStats(r, serverPort, true)
+}
+
+// serverStats returns the stats of a server, and resets them if reset is true.
+// The stats of its mounts are left as is.
+func (sph *ServerPoolHandler) serverStats(r *http.Request, serverPort string, reset bool) (int, *Stats, error) {
+	port, err := strconv.Atoi(serverPort)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	srv := sph.ServerPool.Get(uint16(port))
+	if srv == nil {
+		return http.StatusNotFound, nil, fmt.Errorf("server %q not found", serverPort)
+	}
+	top, err := statsTopParam(r)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	data := newStatsDataFromSnapshot(srv.Stats.Snapshot(top))
+	if reset {
+		srv.Stats.Reset()
+		sph.srvLogger(srv).Info("reset stats")
+	}
+	return http.StatusOK, data, nil
+}
+
+func (sph *ServerPoolHandler) getHooks(w http.ResponseWriter, r *http.Request) (int, []Hook, error) {
+	sph.hooksMu.Lock()
+	defer sph.hooksMu.Unlock()
+	hooks := make([]Hook, len(sph.hooks))
+	for i, h := range sph.hooks {
+		hooks[i] = *newHookDataFromHook(h)
+	}
+	return http.StatusOK, hooks, nil
+}
+
+func (sph *ServerPoolHandler) postHooks(w http.ResponseWriter, r *http.Request, vreq *CreateHookIn) (int, *Hook, error) {
+	if vreq.Type == HookTypeCommand && !sph.HookCommands {
+		return http.StatusBadRequest, nil, errors.New("command hooks are not enabled")
+	}
+	sph.hooksMu.Lock()
+	defer sph.hooksMu.Unlock()
+	h, err := newHook(strconv.Itoa(sph.hooksLastID+1), vreq, sph.router)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	sph.hooksLastID++
+	sph.hooks = append(sph.hooks, h)
+	h.start(sph.events)
+	sph.logger().Info("created hook", "hook_id", h.id, "type", h.typ)
+
+	sph.writeLocation(w, RouteHooksOne{HookId: h.id})
+	return http.StatusCreated, newHookDataFromHook(h), nil
+}
+
+func (sph *ServerPoolHandler) deleteHooks(w http.ResponseWriter, r *http.Request) (int, []Hook, error) {
+	sph.hooksMu.Lock()
+	defer sph.hooksMu.Unlock()
+	hooks := make([]Hook, len(sph.hooks))
+	for i, h := range sph.hooks {
+		h.stop(sph.events)
+		hooks[i] = *newHookDataFromHook(h)
+	}
+	sph.hooks = nil
+	sph.logger().Info("removed all hooks")
+	return http.StatusOK, hooks, nil
+}
+
+func (sph *ServerPoolHandler) getHooksOne(w http.ResponseWriter, r *http.Request, hookId string) (int, *Hook, error) {
+	sph.hooksMu.Lock()
+	defer sph.hooksMu.Unlock()
+	for _, h := range sph.hooks {
+		if h.id == hookId {
+			return http.StatusOK, newHookDataFromHook(h), nil
+		}
+	}
+	return http.StatusNotFound, nil, fmt.Errorf("hook %q not found", hookId)
+}
+
+func (sph *ServerPoolHandler) deleteHooksOne(w http.ResponseWriter, r *http.Request, hookId string) (int, *Hook, error) {
+	sph.hooksMu.Lock()
+	defer sph.hooksMu.Unlock()
+	for i, h := range sph.hooks {
+		if h.id == hookId {
+			h.stop(sph.events)
+			sph.hooks = append(sph.hooks[:i], sph.hooks[i+1:]...)
+			sph.logger().Info("removed hook", "hook_id", h.id)
+			return http.StatusOK, newHookDataFromHook(h), nil
+		}
+	}
+	return http.StatusNotFound, nil, fmt.Errorf("hook %q not found", hookId)
+}
+
+func (sph *ServerPoolHandler) getTransfers(w http.ResponseWriter, r *http.Request) (int, []Transfer, error) {
+	transfers := sph.transfersList()
+	data := make([]Transfer, len(transfers))
+	for i, t := range transfers {
+		data[i] = *newTransferDataFromTransfer(t)
+	}
+	return http.StatusOK, data, nil
+}
+
+func (sph *ServerPoolHandler) getTransfersOne(w http.ResponseWriter, r *http.Request, transferId string) (int, *Transfer, error) {
+	t := sph.getTransfer(transferId)
+	if t == nil {
+		return http.StatusNotFound, nil, fmt.Errorf("transfer %q not found", transferId)
+	}
+	return http.StatusOK, newTransferDataFromTransfer(t), nil
+}
+
+func (sph *ServerPoolHandler) deleteTransfersOne(w http.ResponseWriter, r *http.Request, transferId string) (int, *Transfer, error) {
+	t := sph.getTransfer(transferId)
+	if t == nil {
+		return http.StatusNotFound, nil, fmt.Errorf("transfer %q not found", transferId)
+	}
+	t.abort()
+	data := newTransferDataFromTransfer(t)
+	sph.logger().Info("canceled transfer", "port", data.Port, "transfer_id", data.Id, "remote_addr", data.RemoteAddr, "path", data.Path)
+	return http.StatusOK, data, nil
+}
@@ -0,0 +1,176 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// additional routes
+const (
+	routeHealthz = "healthz"
+	routeReadyz  = "readyz"
+	routeVersion = "version"
+)
+
+type RouteHealthz struct{}
+
+func (r RouteHealthz) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeHealthz)
+}
+
+type RouteReadyz struct{}
+
+func (r RouteReadyz) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeReadyz)
+}
+
+type RouteVersion struct{}
+
+func (r RouteVersion) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeVersion)
+}
+
+// Version is the version of kraken. It can be set at build time with
+// -ldflags "-X github.com/vincent-petithory/kraken/admin.Version=v1.0.0".
+// Otherwise, the version of the main module is used, if known.
+var Version string
+
+// Statuses of a Health.
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// BuildInfo describes the build of kraken.
+type BuildInfo struct {
+	Version      string `json:"version"`
+	GoVersion    string `json:"go_version"`
+	Revision     string `json:"revision,omitempty"`
+	RevisionTime string `json:"revision_time,omitempty"`
+	Modified     bool   `json:"modified,omitempty"`
+}
+
+// ServerHealth is the readiness of a server.
+type ServerHealth struct {
+	Port  int    `json:"port"`
+	Addr  string `json:"addr"`
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+// Health is the response of the health, readiness and version endpoints.
+type Health struct {
+	// Status is HealthStatusOK or HealthStatusUnavailable; empty for the version endpoint.
+	Status  string         `json:"status,omitempty"`
+	Build   BuildInfo      `json:"build"`
+	Started string         `json:"started"`
+	Uptime  string         `json:"uptime"`
+	Servers []ServerHealth `json:"servers,omitempty"`
+}
+
+// buildInfo returns the build info of the running binary.
+func buildInfo() BuildInfo {
+	info := BuildInfo{Version: Version, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "" {
+		info.Version = bi.Main.Version
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.RevisionTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+func (sph *ServerPoolHandler) health() *Health {
+	return &Health{
+		Build:   buildInfo(),
+		Started: sph.started.Format(time.RFC3339),
+		Uptime:  time.Since(sph.started).Round(time.Second).String(),
+	}
+}
+
+// serversHealth returns the readiness of each server, including those which could not start,
+// and whether they are all ready.
+func (sph *ServerPoolHandler) serversHealth() ([]ServerHealth, bool) {
+	ready := true
+	var servers []ServerHealth
+	for _, srv := range sph.ServerPool.Servers() {
+		select {
+		case <-srv.Started:
+		default:
+			// It is being started.
+			continue
+		}
+		sh := ServerHealth{Port: int(srv.Port), Addr: srv.Addr, Ready: true}
+		if err := srv.Err(); err != nil {
+			sh.Ready = false
+			sh.Error = err.Error()
+			ready = false
+		}
+		servers = append(servers, sh)
+	}
+	for _, srv := range sph.ServerPool.Failed() {
+		ready = false
+		sh := ServerHealth{Port: int(srv.Port), Addr: srv.Addr}
+		if err := srv.Err(); err != nil {
+			sh.Error = err.Error()
+		}
+		servers = append(servers, sh)
+	}
+	return servers, ready
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, status int, health *Health) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	if r.Method == "HEAD" {
+		return
+	}
+	json.NewEncoder(w).Encode(health)
+}
+
+// serveHealthz replies whether krakend is alive.
+func (sph *ServerPoolHandler) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	health := sph.health()
+	health.Status = HealthStatusOK
+	writeHealth(w, r, http.StatusOK, health)
+}
+
+// serveReadyz replies whether all the servers are listening,
+// with 503 Service Unavailable if one is not.
+func (sph *ServerPoolHandler) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	health := sph.health()
+	servers, ready := sph.serversHealth()
+	health.Servers = servers
+	status := http.StatusOK
+	health.Status = HealthStatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+		health.Status = HealthStatusUnavailable
+	}
+	writeHealth(w, r, status, health)
+}
+
+// serveVersion replies with the build info and uptime of krakend.
+func (sph *ServerPoolHandler) serveVersion(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, r, http.StatusOK, sph.health())
+}
@@ -0,0 +1,132 @@
+package admin_test
+
+import (
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken/admin"
+)
+
+func TestHealth(t *testing.T) {
+	sph, c, ts := newTestServerPoolHandler(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	health, err := c.GetHealthz()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Status != admin.HealthStatusOK || health.Build.GoVersion != runtime.Version() || health.Uptime == "" {
+		t.Errorf("unexpected health %+v", health)
+	}
+	if _, err := time.Parse(time.RFC3339, health.Started); err != nil {
+		t.Errorf("unexpected start time: %v", err)
+	}
+	version, err := c.GetVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Status != "" || version.Build != health.Build {
+		t.Errorf("unexpected version %+v", version)
+	}
+	resp, err := http.Head(ts.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready, err := c.GetReadyz()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ready.Status != admin.HealthStatusOK || len(ready.Servers) != 1 {
+		t.Fatalf("unexpected readiness %+v", ready)
+	}
+	if sh := ready.Servers[0]; sh.Port != srv.Port || !sh.Ready || sh.Error != "" {
+		t.Errorf("unexpected server readiness %+v", sh)
+	}
+
+	// Stop the server without removing it from the pool.
+	if err := sph.ServerPool.Get(uint16(srv.Port)).Close(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ready, err = c.GetReadyz()
+		if err != nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err == nil {
+		t.Fatal("expected krakend not to be ready")
+	}
+	if ready.Status != admin.HealthStatusUnavailable || len(ready.Servers) != 1 || ready.Servers[0].Ready || ready.Servers[0].Error == "" {
+		t.Errorf("unexpected readiness %+v", ready)
+	}
+}
+
+func TestReadyzFailedServer(t *testing.T) {
+	sph, c, ts := newTestServerPoolHandler(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	srv, err := sph.ServerPool.Add(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The address is taken once the server is added, so that it fails to bind.
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if !sph.ServerPool.StartSrv(srv) {
+		t.Fatal("expected the server to be started")
+	}
+	<-srv.Started
+
+	ready, err := c.GetReadyz()
+	if err == nil {
+		t.Fatal("expected krakend not to be ready")
+	}
+	if ready.Status != admin.HealthStatusUnavailable || len(ready.Servers) != 1 {
+		t.Fatalf("unexpected readiness %+v", ready)
+	}
+	if sh := ready.Servers[0]; sh.Addr != addr || sh.Port != int(srv.Port) || sh.Port == 0 || sh.Ready || sh.Error == "" {
+		t.Errorf("unexpected server readiness %+v", sh)
+	}
+	resp, err := http.Get(ts.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	// A server added on the address supersedes the failed one.
+	ln.Close()
+	if _, err := c.PutServersOne(strconv.Itoa(int(srv.Port)), &admin.CreateServerIn{BindAddress: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if ready, err := c.GetReadyz(); err != nil {
+		t.Errorf("expected krakend to be ready, got %v: %+v", err, ready)
+	}
+}
@@ -0,0 +1,212 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// additional routes
+const routeEventsHistory = "events_history"
+
+type RouteEventsHistory struct{}
+
+func (r RouteEventsHistory) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeEventsHistory)
+}
+
+const (
+	// SinceQueryKey is the query param replaying the events following the event with this ID.
+	SinceQueryKey = "since"
+	// EpochQueryKey is the query param holding the epoch of the since event.
+	// If it is not the current epoch, all the events are replayed.
+	EpochQueryKey = "epoch"
+	// LimitQueryKey is the query param limiting the history to the most recent events.
+	LimitQueryKey = "limit"
+)
+
+// EventHistorySize is the number of recent events kept in the history.
+const EventHistorySize = 1000
+
+// eventHistory is a ring buffer of the most recent events.
+// It numbers the events it keeps, and timestamps them.
+type eventHistory struct {
+	mu     sync.Mutex
+	events []*Event
+	// start is the index of the oldest event, and n the number of events.
+	start  int
+	n      int
+	lastID uint64
+	epoch  string
+}
+
+func newEventHistory(size int) *eventHistory {
+	return &eventHistory{
+		events: make([]*Event, size),
+		epoch:  strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+}
+
+// add numbers and timestamps event, and keeps it,
+// dropping the oldest event if the history is full.
+func (h *eventHistory) add(event *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastID++
+	event.ID = h.lastID
+	event.Epoch = h.epoch
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	h.push(event)
+}
+
+func (h *eventHistory) push(event *Event) {
+	if len(h.events) == 0 {
+		return
+	}
+	if h.n < len(h.events) {
+		h.events[(h.start+h.n)%len(h.events)] = event
+		h.n++
+		return
+	}
+	h.events[h.start] = event
+	h.start = (h.start + 1) % len(h.events)
+}
+
+// since returns the events following the event with ID id, whose type is in types,
+// or of any type if types is nil. If limit is positive, only the limit most recent ones are returned.
+func (h *eventHistory) since(id uint64, types map[EventType]bool, limit int) []*Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var events []*Event
+	for i := 0; i < h.n; i++ {
+		event := h.events[(h.start+i)%len(h.events)]
+		if event.ID <= id || (types != nil && !types[event.Type]) {
+			continue
+		}
+		events = append(events, event)
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events
+}
+
+// restore replaces the history with events, ordered by ID.
+// The following events are numbered after the last of them, in the same epoch.
+func (h *eventHistory) restore(events []*Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.start, h.n = 0, 0
+	for _, event := range events {
+		h.push(event)
+		if event.ID > h.lastID {
+			h.lastID = event.ID
+		}
+		if event.Epoch != "" {
+			h.epoch = event.Epoch
+		}
+	}
+}
+
+func (s *serverPoolEventsHandler) serveHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	types, err := eventTypesParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	since, _, err := s.history.sinceParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var limit int
+	if v := r.URL.Query().Get(LimitQueryKey); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	encode, err := s.envelopeParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	events := s.history.since(since, types, limit)
+	encoded := make([]interface{}, len(events))
+	for i, event := range events {
+		encoded[i] = encode(event)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(encoded); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// sinceParam returns the ID of the since query param, and whether it was set.
+// The ID is 0 if the epoch query param is not the epoch of h,
+// since the event it refers to is of a previous numbering.
+func (h *eventHistory) sinceParam(r *http.Request) (uint64, bool, error) {
+	v := r.URL.Query().Get(SinceQueryKey)
+	if v == "" {
+		return 0, false, nil
+	}
+	since, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s %q", SinceQueryKey, v)
+	}
+	if epoch := r.URL.Query().Get(EpochQueryKey); epoch != "" {
+		h.mu.Lock()
+		if epoch != h.epoch {
+			since = 0
+		}
+		h.mu.Unlock()
+	}
+	return since, true, nil
+}
+
+// LoadEvents loads the event history saved in file by SaveEvents.
+// It is not an error if file doesn't exist.
+func (sph *ServerPoolHandler) LoadEvents(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var events []*Event
+	if err := json.Unmarshal(b, &events); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	sph.events.history.restore(events)
+	return nil
+}
+
+// SaveEvents saves the event history in file.
+func (sph *ServerPoolHandler) SaveEvents(file string) error {
+	b, err := json.Marshal(sph.events.history.since(0, nil, 0))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
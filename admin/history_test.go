@@ -0,0 +1,191 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken/admin"
+	"github.com/vincent-petithory/kraken/admin/client"
+)
+
+// waitEvents waits for the history to hold at least n events of the given kinds, and returns them.
+func waitEvents(t *testing.T, c *client.Client, n int, events ...string) []admin.Event {
+	deadline := time.Now().Add(time.Second)
+	for {
+		evts, err := c.GetEventsHistory(0, 0, events...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(evts) >= n || time.Now().After(deadline) {
+			return evts
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEventsHistory(t *testing.T) {
+	c, ts := newTestAdmin(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	dir, err := ioutil.TempDir("", "kraken-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(srv.Port)
+	mount, err := c.PostServersOneMounts(port, &admin.CreateMountIn{Source: dir, Target: "/files"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(t, fmt.Sprintf("http://127.0.0.1:%d/files/hello.txt", srv.Port))
+
+	evts := waitEvents(t, c, 3)
+	if len(evts) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(evts))
+	}
+	for i, evt := range evts {
+		if evt.ID != uint64(i+1) {
+			t.Errorf("expected event %d to have ID %d, got %d", i, i+1, evt.ID)
+		}
+		if evt.Time.IsZero() {
+			t.Errorf("expected event %d to have a time", i)
+		}
+	}
+	for i, typ := range []admin.EventType{admin.EventTypeServerAdd, admin.EventTypeMountAdd, admin.EventTypeFileServe} {
+		if evts[i].Type != typ {
+			t.Errorf("expected event %d to be of type %d, got %d", i, typ, evts[i].Type)
+		}
+	}
+	fse, ok := evts[2].Resource.(*admin.FileServeEvent)
+	if !ok {
+		t.Fatalf("expected a file serve event, got %T", evts[2].Resource)
+	}
+	if fse.Mount != mount.Id || fse.Path != "/hello.txt" || fse.Code != 200 || fse.Method != "GET" {
+		t.Errorf("unexpected file serve event %+v", fse)
+	}
+	if fse.Bytes != 5 || fse.ContentLength != 5 || fse.Aborted || fse.Range {
+		t.Errorf("expected a complete transfer of 5 bytes, got %+v", fse)
+	}
+
+	if evts, err := c.GetEventsHistory(1, 0); err != nil {
+		t.Fatal(err)
+	} else if len(evts) != 2 || evts[0].ID != 2 {
+		t.Errorf("expected the 2 events following event 1, got %v", evts)
+	}
+	if evts, err := c.GetEventsHistory(0, 1); err != nil {
+		t.Fatal(err)
+	} else if len(evts) != 1 || evts[0].ID != 3 {
+		t.Errorf("expected the most recent event, got %v", evts)
+	}
+	if evts, err := c.GetEventsHistory(0, 0, "mount"); err != nil {
+		t.Fatal(err)
+	} else if len(evts) != 1 || evts[0].Type != admin.EventTypeMountAdd {
+		t.Errorf("expected the mount event only, got %v", evts)
+	}
+}
+
+func TestSaveEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kraken-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "events.json")
+
+	sph, c, ts := newTestServerPoolHandler(t)
+	if _, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	waitEvents(t, c, 1)
+	if _, err := c.DeleteServers(); err != nil {
+		t.Fatal(err)
+	}
+	waitEvents(t, c, 2)
+	ts.Close()
+	if err := sph.SaveEvents(file); err != nil {
+		t.Fatal(err)
+	}
+
+	sph, c, ts = newTestServerPoolHandler(t)
+	if err := sph.LoadEvents(file); err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	defer c.DeleteServers()
+	if _, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	evts := waitEvents(t, c, 3)
+	if len(evts) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(evts))
+	}
+	for i, typ := range []admin.EventType{admin.EventTypeServerAdd, admin.EventTypeServerRemove, admin.EventTypeServerAdd} {
+		if evts[i].ID != uint64(i+1) || evts[i].Type != typ {
+			t.Errorf("expected event %d to have ID %d and type %d, got %d and %d", i, i+1, typ, evts[i].ID, evts[i].Type)
+		}
+	}
+	// The numbering goes on in the same epoch.
+	if evts[2].Epoch != evts[0].Epoch {
+		t.Errorf("expected the restored events to keep their epoch, got %q and %q", evts[0].Epoch, evts[2].Epoch)
+	}
+}
+
+func TestEventsEpoch(t *testing.T) {
+	c, ts := newTestAdmin(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evts := waitEvents(t, c, 2)
+	if len(evts) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(evts))
+	}
+	epoch := evts[0].Epoch
+	if epoch == "" || evts[1].Epoch != epoch {
+		t.Fatalf("expected the events to have the same epoch, got %q and %q", epoch, evts[1].Epoch)
+	}
+
+	tests := []struct {
+		Epoch string
+		IDs   []uint64
+	}{
+		{"", []uint64{2}},
+		{epoch, []uint64{2}},
+		// The IDs restarted since the event 1 of this epoch.
+		{"stale", []uint64{1, 2}},
+	}
+	for _, test := range tests {
+		u := fmt.Sprintf("%s/events/history?since=1&epoch=%s", ts.URL, test.Epoch)
+		var evts []admin.Event
+		if err := json.Unmarshal([]byte(get(t, u)), &evts); err != nil {
+			t.Fatal(err)
+		}
+		var ids []uint64
+		for _, evt := range evts {
+			ids = append(ids, evt.ID)
+		}
+		if !reflect.DeepEqual(ids, test.IDs) {
+			t.Errorf("epoch %q: expected events %v, got %v", test.Epoch, test.IDs, ids)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HookTypeWebhook is the type of the hooks posting the events to a URL.
+	HookTypeWebhook = "webhook"
+	// HookTypeCommand is the type of the hooks running a command with the event on its stdin.
+	HookTypeCommand = "command"
+)
+
+const (
+	// HookEventHeader is the header of a webhook request holding the type of the event.
+	HookEventHeader = "X-Kraken-Event"
+	// HookDeliveryHeader is the header of a webhook request holding the ID of the event.
+	HookDeliveryHeader = "X-Kraken-Delivery"
+	// HookSignatureHeader is the header of a webhook request holding the signature of the body,
+	// when the hook has a secret: sha256= followed by the hex-encoded HMAC-SHA256 of the body.
+	HookSignatureHeader = "X-Kraken-Signature"
+)
+
+const (
+	defaultHookRetries = 3
+	// hookQueueSize is the number of events waiting to be delivered by a hook;
+	// events are dropped when it is full.
+	hookQueueSize = 64
+	// hookTimeout is how long a delivery attempt may take.
+	hookTimeout = 30 * time.Second
+	// A failed delivery is retried after hookBackoff, doubled on each attempt up to hookMaxBackoff.
+	hookBackoff    = time.Second
+	hookMaxBackoff = time.Minute
+)
+
+// hook delivers the events it is subscribed to, by posting them to a URL or running a command.
+type hook struct {
+	id       string
+	typ      string
+	events   []string
+	port     int
+	mount    string
+	path     string
+	url      string
+	secret   string
+	command  []string
+	envelope string
+	retries  int
+	encode   func(*Event) interface{}
+
+	conn   *conn
+	queue  chan *Event
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu           sync.Mutex
+	delivered    int
+	failed       int
+	dropped      int
+	lastError    string
+	lastDelivery time.Time
+}
+
+func newHook(id string, vreq *CreateHookIn, rr RouteReverser) (*hook, error) {
+	h := &hook{
+		id:       id,
+		typ:      vreq.Type,
+		events:   vreq.Events,
+		port:     vreq.Port,
+		mount:    vreq.Mount,
+		path:     vreq.Path,
+		url:      vreq.Url,
+		secret:   vreq.Secret,
+		command:  vreq.Command,
+		envelope: vreq.Envelope,
+		retries:  vreq.Retries,
+		queue:    make(chan *Event, hookQueueSize),
+	}
+	switch h.typ {
+	case HookTypeWebhook:
+		u, err := url.Parse(h.url)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("webhook URL %q is not a http or https URL", h.url)
+		}
+	case HookTypeCommand:
+		if len(h.command) == 0 || h.command[0] == "" {
+			return nil, errors.New("no command to run")
+		}
+	default:
+		return nil, fmt.Errorf("unknown hook type %q", h.typ)
+	}
+	var events map[EventType]bool
+	if len(h.events) > 0 {
+		events = make(map[EventType]bool)
+		for _, name := range h.events {
+			types, err := MatchEventTypes(name)
+			if err != nil {
+				return nil, err
+			}
+			for _, t := range types {
+				events[t] = true
+			}
+		}
+	} else {
+		events = make(map[EventType]bool, len(eventTypeNames))
+		for t := range eventTypeNames {
+			events[t] = true
+		}
+	}
+	if h.path != "" {
+		if _, err := path.Match(h.path, ""); err != nil {
+			return nil, fmt.Errorf("path pattern %q: %v", h.path, err)
+		}
+	}
+	encode, err := envelopeEncoder(h.envelope, rr)
+	if err != nil {
+		return nil, err
+	}
+	h.encode = encode
+	switch {
+	case h.retries == 0:
+		h.retries = defaultHookRetries
+	case h.retries < 0:
+		h.retries = 0
+	}
+	h.conn = &conn{
+		events:  events,
+		eventCh: make(chan *Event, connBacklog),
+	}
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	return h, nil
+}
+
+// start subscribes h to the events of s, and delivers them until h is stopped.
+func (h *hook) start(s *serverPoolEventsHandler) {
+	s.sub <- h.conn
+	go func() {
+		for event := range h.conn.eventCh {
+			if !h.matches(event) {
+				continue
+			}
+			select {
+			case h.queue <- event:
+			default:
+				h.mu.Lock()
+				h.dropped++
+				h.mu.Unlock()
+			}
+		}
+		close(h.queue)
+	}()
+	go func() {
+		for event := range h.queue {
+			h.deliver(event)
+		}
+	}()
+}
+
+// stop unsubscribes h from the events of s, and aborts the delivery in progress.
+func (h *hook) stop(s *serverPoolEventsHandler) {
+	h.cancel()
+	s.unsub <- h.conn
+}
+
+// matches tells whether event relates to the server, mount and path h filters on.
+func (h *hook) matches(event *Event) bool {
+	port, mount, p := eventScope(event)
+	if h.port != 0 && port != h.port {
+		return false
+	}
+	if h.mount != "" && mount != h.mount {
+		return false
+	}
+	if h.path != "" {
+		if ok, _ := path.Match(h.path, p); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// deliver delivers event, retrying with an exponential backoff if it fails.
+func (h *hook) deliver(event *Event) {
+	body, err := json.Marshal(h.encode(event))
+	backoff := hookBackoff
+	for attempt := 0; body != nil; attempt++ {
+		if err = h.send(event, body); err == nil || attempt >= h.retries {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-h.ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > hookMaxBackoff {
+			backoff = hookMaxBackoff
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastDelivery = time.Now()
+	if err != nil {
+		h.failed++
+		h.lastError = err.Error()
+	} else {
+		h.delivered++
+	}
+}
+
+func (h *hook) send(event *Event, body []byte) error {
+	ctx, cancel := context.WithTimeout(h.ctx, hookTimeout)
+	defer cancel()
+	switch h.typ {
+	case HookTypeWebhook:
+		return h.post(ctx, event, body)
+	case HookTypeCommand:
+		return h.run(ctx, event, body)
+	}
+	return fmt.Errorf("unknown hook type %q", h.typ)
+}
+
+// post posts the event to the URL of h.
+func (h *hook) post(ctx context.Context, event *Event, body []byte) error {
+	req, err := http.NewRequest("POST", h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if h.envelope == envelopeCloudEvents {
+		req.Header.Set("Content-Type", "application/cloudevents+json")
+	}
+	req.Header.Set(HookEventHeader, event.Type.String())
+	req.Header.Set(HookDeliveryHeader, strconv.FormatUint(event.ID, 10))
+	if h.secret != "" {
+		req.Header.Set(HookSignatureHeader, HookSignature(h.secret, body))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", h.url, resp.Status)
+	}
+	return nil
+}
+
+// run runs the command of h, with the event on its stdin.
+// The type and ID of the event are in the KRAKEN_EVENT_TYPE and KRAKEN_EVENT_ID environment variables.
+func (h *hook) run(ctx context.Context, event *Event, body []byte) error {
+	cmd := exec.CommandContext(ctx, h.command[0], h.command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"KRAKEN_EVENT_TYPE="+event.Type.String(),
+		"KRAKEN_EVENT_ID="+strconv.FormatUint(event.ID, 10),
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %v: %s", h.command[0], err, msg)
+		}
+		return fmt.Errorf("%s: %v", h.command[0], err)
+	}
+	return nil
+}
+
+// HookSignature returns the signature of body sent by a webhook with secret.
+func HookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newHookDataFromHook(h *hook) *Hook {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hook := &Hook{
+		Id:        h.id,
+		Type:      h.typ,
+		Events:    h.events,
+		Port:      h.port,
+		Mount:     h.mount,
+		Path:      h.path,
+		Url:       h.url,
+		Command:   h.command,
+		Envelope:  h.envelope,
+		Retries:   h.retries,
+		Delivered: h.delivered,
+		Failed:    h.failed,
+		Dropped:   h.dropped,
+		LastError: h.lastError,
+	}
+	if !h.lastDelivery.IsZero() {
+		hook.LastDelivery = h.lastDelivery.Format(time.RFC3339)
+	}
+	return hook
+}
@@ -0,0 +1,222 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken/admin"
+	"github.com/vincent-petithory/kraken/admin/client"
+)
+
+// waitHook waits for the hook to have tried to deliver n events, and returns it.
+func waitHook(t *testing.T, c *client.Client, id string, n int) *admin.Hook {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		hook, err := c.GetHooksOne(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hook.Delivered+hook.Failed >= n || time.Now().After(deadline) {
+			return hook
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	c, ts := newTestAdmin(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+	defer c.DeleteHooks()
+
+	var (
+		mu       sync.Mutex
+		requests int
+		body     []byte
+		header   http.Header
+	)
+	hts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer hts.Close()
+
+	hook, err := c.PostHooks(&admin.CreateHookIn{
+		Type:   admin.HookTypeWebhook,
+		Url:    hts.URL,
+		Events: []string{"server.add"},
+		Secret: "s3cr3t",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeleteServers(); err != nil {
+		t.Fatal(err)
+	}
+
+	hook = waitHook(t, c, hook.Id, 1)
+	if hook.Delivered != 1 || hook.Failed != 0 {
+		t.Fatalf("expected 1 delivery, got %+v", hook)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 {
+		t.Errorf("expected the delivery to be retried once, got %d requests", requests)
+	}
+	if sig := header.Get(admin.HookSignatureHeader); sig != admin.HookSignature("s3cr3t", body) {
+		t.Errorf("expected signature %s, got %s", admin.HookSignature("s3cr3t", body), sig)
+	}
+	if typ := header.Get(admin.HookEventHeader); typ != "server.add" {
+		t.Errorf("expected event header server.add, got %s", typ)
+	}
+	var evt admin.Event
+	if err := json.Unmarshal(body, &evt); err != nil {
+		t.Fatal(err)
+	}
+	if evt.Type != admin.EventTypeServerAdd {
+		t.Errorf("expected a server.add event, got %s", evt.Type)
+	}
+}
+
+func TestWebhookFailure(t *testing.T) {
+	c, ts := newTestAdmin(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+	defer c.DeleteHooks()
+
+	hts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer hts.Close()
+
+	hook, err := c.PostHooks(&admin.CreateHookIn{
+		Type:    admin.HookTypeWebhook,
+		Url:     hts.URL,
+		Events:  []string{"server"},
+		Retries: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	hook = waitHook(t, c, hook.Id, 1)
+	if hook.Failed != 1 || hook.Delivered != 0 {
+		t.Fatalf("expected 1 failed delivery, got %+v", hook)
+	}
+	if !strings.Contains(hook.LastError, "500") || hook.LastDelivery == "" {
+		t.Errorf("expected the last delivery to fail with a 500, got %+v", hook)
+	}
+}
+
+func TestCommandHook(t *testing.T) {
+	sph, c, ts := newTestServerPoolHandler(t)
+	sph.HookCommands = true
+	defer ts.Close()
+	defer c.DeleteServers()
+	defer c.DeleteHooks()
+
+	dir, err := ioutil.TempDir("", "kraken-hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "event.json")
+
+	// Only the events of the mount on /b are run.
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(srv.Port)
+	hook, err := c.PostHooks(&admin.CreateHookIn{
+		Type:    admin.HookTypeCommand,
+		Command: []string{"sh", "-c", `echo "$KRAKEN_EVENT_TYPE" > "$0.type" && cat > "$0"`, out},
+		Events:  []string{"mount"},
+		Port:    srv.Port,
+		Mount:   "25c8e1f",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PostServersOneMounts(port, &admin.CreateMountIn{Source: dir, Target: "/a"}); err != nil {
+		t.Fatal(err)
+	}
+	mount, err := c.PostServersOneMounts(port, &admin.CreateMountIn{Source: dir, Target: "/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mount.Id != "25c8e1f" {
+		t.Fatalf("expected the mount on /b to have ID 25c8e1f, got %s", mount.Id)
+	}
+
+	hook = waitHook(t, c, hook.Id, 1)
+	if hook.Delivered != 1 {
+		t.Fatalf("expected 1 delivery, got %+v", hook)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var evt admin.Event
+	if err := json.Unmarshal(b, &evt); err != nil {
+		t.Fatal(err)
+	}
+	if me, ok := evt.Resource.(*admin.MountEvent); !ok || me.Mount.Target != "/b" {
+		t.Errorf("expected the mount event of /b, got %+v", evt.Resource)
+	}
+	if b, err := ioutil.ReadFile(out + ".type"); err != nil {
+		t.Fatal(err)
+	} else if typ := strings.TrimSpace(string(b)); typ != "mount.add" {
+		t.Errorf("expected KRAKEN_EVENT_TYPE to be mount.add, got %s", typ)
+	}
+}
+
+func TestInvalidHooks(t *testing.T) {
+	sph, c, ts := newTestServerPoolHandler(t)
+	defer ts.Close()
+	defer c.DeleteHooks()
+
+	// Command hooks must be enabled.
+	if hook, err := c.PostHooks(&admin.CreateHookIn{Type: admin.HookTypeCommand, Command: []string{"true"}}); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected a 400 error creating a command hook, got %+v, %v", hook, err)
+	}
+	sph.HookCommands = true
+	for _, hookIn := range []admin.CreateHookIn{
+		{Type: "email"},
+		{Type: admin.HookTypeWebhook, Url: "ftp://example.com"},
+		{Type: admin.HookTypeCommand},
+		{Type: admin.HookTypeCommand, Command: []string{"true"}, Events: []string{"nothing"}},
+		{Type: admin.HookTypeCommand, Command: []string{"true"}, Path: "["},
+		{Type: admin.HookTypeCommand, Command: []string{"true"}, Envelope: "soap"},
+	} {
+		if hook, err := c.PostHooks(&hookIn); err == nil {
+			t.Errorf("expected an error creating hook %+v, got %+v", hookIn, hook)
+		}
+	}
+	if hooks, err := c.GetHooks(); err != nil {
+		t.Fatal(err)
+	} else if len(hooks) != 0 {
+		t.Errorf("expected no hooks, got %v", hooks)
+	}
+}
@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/vincent-petithory/kraken"
+	"github.com/vincent-petithory/kraken/accesslog"
+)
+
+// logger returns the logger of sph: its Logger, or else the one of its server pool,
+// or else slog.Default().
+func (sph *ServerPoolHandler) logger() *slog.Logger {
+	if sph.Logger != nil {
+		return sph.Logger
+	}
+	if sph.ServerPool != nil && sph.ServerPool.Logger != nil {
+		return sph.ServerPool.Logger
+	}
+	return slog.Default()
+}
+
+// srvLogger returns the logger of sph, with the port of srv as attribute.
+func (sph *ServerPoolHandler) srvLogger(srv *kraken.Server) *slog.Logger {
+	return sph.logger().With("port", srv.Port)
+}
+
+// logRequests calls log with the access log entry of each request served by h,
+// and the target of the mount it was routed to, if any.
+func logRequests(h http.Handler, log func(entry *accesslog.Entry, mountTarget string)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := accesslog.Entry{
+			Time:       start,
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Host:       r.Host,
+			URI:        r.RequestURI,
+			Proto:      r.Proto,
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+		}
+		if user, _, ok := r.BasicAuth(); ok {
+			entry.User = user
+		}
+		w, r, rsl := recordResponse(w, r)
+		r, target := kraken.WithMountTarget(r)
+		h.ServeHTTP(w, r)
+
+		entry.Status = rsl.Status
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		entry.Bytes = rsl.Size
+		entry.Duration = time.Since(start)
+		log(&entry, *target)
+	})
+}
+
+// logRequest logs the request of entry with logger.
+func logRequest(logger *slog.Logger, msg string, entry *accesslog.Entry, mountTarget string) {
+	attrs := []slog.Attr{
+		slog.String("remote_addr", entry.RemoteAddr),
+		slog.String("method", entry.Method),
+		slog.String("uri", entry.URI),
+		slog.Int("status", entry.Status),
+		slog.Int64("bytes", entry.Bytes),
+		slog.Duration("duration", entry.Duration),
+	}
+	if mountTarget != "" {
+		attrs = append(attrs, slog.String("mount_id", mountID(mountTarget)))
+	}
+	if entry.User != "" {
+		attrs = append(attrs, slog.String("user", entry.User))
+	}
+	logger.LogAttrs(context.Background(), slog.LevelInfo, msg, attrs...)
+}
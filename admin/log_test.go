@@ -0,0 +1,103 @@
+package admin_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken/admin"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records returns the JSON log records written to b.
+func (b *syncBuffer) records(t *testing.T) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// findRecord waits for a log record with message msg, and returns it.
+func findRecord(t *testing.T, b *syncBuffer, msg string) map[string]interface{} {
+	deadline := time.Now().Add(time.Second)
+	for {
+		for _, record := range b.records(t) {
+			if record["msg"] == msg {
+				return record
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no %q log record", msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStructuredLogs(t *testing.T) {
+	var buf syncBuffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	_, c, ts := newTestServerPoolHandlerWithLogger(t, logger)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	dir, err := ioutil.TempDir("", "kraken-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(srv.Port)
+	mount, err := c.PostServersOneMounts(port, &admin.CreateMountIn{Source: dir, Target: "/files"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(t, fmt.Sprintf("http://127.0.0.1:%d/files/missing.txt", srv.Port))
+	if _, err := c.GetServersOne("1"); err == nil {
+		t.Fatal("expected an error getting a missing server")
+	}
+
+	if record := findRecord(t, &buf, "created server"); record["port"] != float64(srv.Port) || record["level"] != "INFO" {
+		t.Errorf("unexpected created server record %v", record)
+	}
+	if record := findRecord(t, &buf, "created mount point"); record["port"] != float64(srv.Port) || record["mount_id"] != mount.Id {
+		t.Errorf("unexpected created mount point record %v", record)
+	}
+	record := findRecord(t, &buf, "request")
+	if record["port"] != float64(srv.Port) || record["mount_id"] != mount.Id || record["status"] != float64(404) ||
+		record["uri"] != "/files/missing.txt" || !strings.HasPrefix(record["remote_addr"].(string), "127.0.0.1:") {
+		t.Errorf("unexpected request record %v", record)
+	}
+	if record := findRecord(t, &buf, "api error"); record["level"] != "WARN" || record["status"] != float64(404) {
+		t.Errorf("unexpected api error record %v", record)
+	}
+}
@@ -0,0 +1,137 @@
+package admin
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/vincent-petithory/kraken"
+)
+
+// additional routes
+const routeMetrics = "metrics"
+
+type RouteMetrics struct{}
+
+func (r RouteMetrics) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeMetrics)
+}
+
+const metricsNamespace = "kraken"
+
+// metrics are the Prometheus metrics of the servers of a pool, labelled by port and mount ID.
+// Requests which don't match a mount have an empty mount label.
+type metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	bytes    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	conns    *prometheus.GaugeVec
+}
+
+func newMetrics(events *serverPoolEventsHandler) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of requests served, by status code.",
+		}, []string{"port", "mount", "code"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_response_bytes_total",
+			Help:      "Number of bytes of response bodies sent.",
+		}, []string{"port", "mount"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of the requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"port", "mount"}),
+		conns: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_connections_active",
+			Help:      "Number of open client connections.",
+		}, []string{"port"}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.bytes,
+		m.duration,
+		m.conns,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "events_subscribers",
+			Help:      "Number of clients listening for events.",
+		}, func() float64 {
+			return float64(atomic.LoadInt64(&events.nconns))
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "events_dropped_total",
+			Help:      "Number of events not delivered to a client too slow to receive them.",
+		}, func() float64 {
+			return float64(atomic.LoadUint64(&events.dropped))
+		}),
+	)
+	return m
+}
+
+// Handler returns the handler serving the metrics, in the Prometheus text format.
+func (m *metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// serverHandler records the metrics of the requests served by h for srv.
+func (m *metrics) serverHandler(srv *kraken.Server, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		w, r, rsl := recordResponse(w, r)
+		r, target := kraken.WithMountTarget(r)
+		h.ServeHTTP(w, r)
+
+		port := strconv.Itoa(int(srv.Port))
+		var mount string
+		if *target != "" {
+			mount = mountID(*target)
+		}
+		status := rsl.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m.requests.WithLabelValues(port, mount, strconv.Itoa(status)).Inc()
+		m.bytes.WithLabelValues(port, mount).Add(float64(rsl.Size))
+		m.duration.WithLabelValues(port, mount).Observe(time.Since(start).Seconds())
+	})
+}
+
+// connState tracks the open connections of srv. See http.Server.ConnState.
+func (m *metrics) connState(srv *kraken.Server) func(net.Conn, http.ConnState) {
+	return func(c net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			m.conns.WithLabelValues(strconv.Itoa(int(srv.Port))).Inc()
+		case http.StateHijacked, http.StateClosed:
+			m.conns.WithLabelValues(strconv.Itoa(int(srv.Port))).Dec()
+		}
+	}
+}
+
+// forget removes the metrics of a server, or the request metrics of one of its mounts
+// if mount is not empty.
+func (m *metrics) forget(port uint16, mount string) {
+	labels := prometheus.Labels{"port": strconv.Itoa(int(port))}
+	if mount != "" {
+		labels["mount"] = mount
+	} else {
+		m.conns.DeletePartialMatch(labels)
+	}
+	m.requests.DeletePartialMatch(labels)
+	m.bytes.DeletePartialMatch(labels)
+	m.duration.DeletePartialMatch(labels)
+}
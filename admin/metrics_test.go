@@ -0,0 +1,125 @@
+package admin_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vincent-petithory/kraken"
+	"github.com/vincent-petithory/kraken/admin"
+	"github.com/vincent-petithory/kraken/admin/client"
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+// newTestAdmin starts an admin server on a new server pool.
+func newTestAdmin(t *testing.T) (*client.Client, *httptest.Server) {
+	_, c, ts := newTestServerPoolHandler(t)
+	return c, ts
+}
+
+// newTestServerPoolHandler is like newTestAdmin, and also returns the handler of the admin server.
+func newTestServerPoolHandler(t *testing.T) (*admin.ServerPoolHandler, *client.Client, *httptest.Server) {
+	return newTestServerPoolHandlerWithLogger(t, nil)
+}
+
+// newTestServerPoolHandlerWithLogger is like newTestServerPoolHandler, with a server pool logging to logger.
+func newTestServerPoolHandlerWithLogger(t *testing.T, logger *slog.Logger) (*admin.ServerPoolHandler, *client.Client, *httptest.Server) {
+	serverPool := kraken.NewServerPool(make(fileserver.Factory))
+	serverPool.Logger = logger
+	go serverPool.Listen()
+	ts := httptest.NewUnstartedServer(nil)
+	u, err := url.Parse("http://" + ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sph := admin.NewServerPoolHandler(serverPool, u)
+	ts.Config.Handler = sph
+	ts.Start()
+	return sph, client.New(u), ts
+}
+
+func get(t *testing.T, u string) string {
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestMetrics(t *testing.T) {
+	c, ts := newTestAdmin(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	dir, err := ioutil.TempDir("", "kraken-metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(srv.Port)
+	mount, err := c.PostServersOneMounts(port, &admin.CreateMountIn{Source: dir, Target: "/files"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvURL := fmt.Sprintf("http://127.0.0.1:%d", srv.Port)
+	get(t, srvURL+"/files/hello.txt")
+	get(t, srvURL+"/files/hello.txt")
+	get(t, srvURL+"/files/missing.txt")
+	get(t, srvURL+"/elsewhere")
+
+	metrics := get(t, ts.URL+"/metrics")
+	for _, line := range []string{
+		fmt.Sprintf(`kraken_http_requests_total{code="200",mount="%s",port="%s"} 2`, mount.Id, port),
+		fmt.Sprintf(`kraken_http_requests_total{code="404",mount="%s",port="%s"} 1`, mount.Id, port),
+		fmt.Sprintf(`kraken_http_requests_total{code="404",mount="",port="%s"} 1`, port),
+		fmt.Sprintf(`kraken_http_request_duration_seconds_count{mount="%s",port="%s"} 3`, mount.Id, port),
+		"kraken_events_subscribers 0",
+		"kraken_events_dropped_total 0",
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("expected metrics to contain %q, got\n%s", line, metrics)
+		}
+	}
+	for _, prefix := range []string{
+		fmt.Sprintf(`kraken_http_response_bytes_total{mount="%s",port="%s"} `, mount.Id, port),
+		fmt.Sprintf(`kraken_http_connections_active{port="%s"} `, port),
+	} {
+		if !strings.Contains(metrics, prefix) {
+			t.Errorf("expected metrics to contain %q, got\n%s", prefix, metrics)
+		}
+	}
+
+	if _, err := c.DeleteServersOneMountsOne(port, mount.Id); err != nil {
+		t.Fatal(err)
+	}
+	if metrics := get(t, ts.URL+"/metrics"); strings.Contains(metrics, mount.Id) {
+		t.Errorf("expected the metrics of the removed mount to be removed, got\n%s", metrics)
+	}
+	if _, err := c.DeleteServers(); err != nil {
+		t.Fatal(err)
+	}
+	if metrics := get(t, ts.URL+"/metrics"); strings.Contains(metrics, fmt.Sprintf(`port="%s"`, port)) {
+		t.Errorf("expected the metrics of the removed server to be removed, got\n%s", metrics)
+	}
+}
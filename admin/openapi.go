@@ -0,0 +1,31 @@
+package admin
+
+import (
+	_ "embed"
+	"net/http"
+	"net/url"
+)
+
+// additional routes
+const routeOpenAPI = "openapi"
+
+type RouteOpenAPI struct{}
+
+func (r RouteOpenAPI) Location(rr RouteReverser) *url.URL {
+	return rr.ReverseRoute(routeOpenAPI)
+}
+
+// openAPIDocument is the OpenAPI document of the API, generated from schema.json.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// serveOpenAPI replies with the OpenAPI document of the API.
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
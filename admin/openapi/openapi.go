@@ -0,0 +1,473 @@
+// Package openapi generates the OpenAPI 3 document of the kraken admin API,
+// from the JSON hyper-schema describing it.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vincent-petithory/kraken/admin"
+)
+
+// Version is the version of the OpenAPI specification of the generated documents.
+const Version = "3.0.3"
+
+type object = map[string]interface{}
+
+// Generate returns the OpenAPI document of the admin API described by the JSON hyper-schema schema,
+// along with the routes of the admin API not described by the schema.
+func Generate(schema []byte) ([]byte, error) {
+	var root object
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return nil, err
+	}
+	g := &generator{root: root, names: componentNames(root)}
+	doc, err := g.document()
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+type generator struct {
+	root object
+	// names are the names of the components of the top-level definitions.
+	names map[string]string
+}
+
+// componentNames returns the component names of the top-level definitions of root,
+// from the properties referring to them, e.g access-log for #/definitions/accesslog is AccessLog.
+func componentNames(root object) map[string]string {
+	names := make(map[string]string)
+	definitions, _ := root["definitions"].(object)
+	for name := range definitions {
+		names[name] = symbolName(name)
+	}
+	properties, _ := root["properties"].(object)
+	for name, prop := range properties {
+		prop, _ := prop.(object)
+		ref, _ := prop["$ref"].(string)
+		if def := strings.TrimPrefix(ref, "#/definitions/"); def != ref && !strings.Contains(def, "/") {
+			names[def] = symbolName(name)
+		}
+	}
+	return names
+}
+
+// symbolName returns s capitalized, without its dashes, e.g file-server-type is FileServerType.
+func symbolName(s string) string {
+	parts := strings.Split(s, "-")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func (g *generator) document() (object, error) {
+	schemas := object{}
+	paths := object{}
+	definitions, _ := g.root["definitions"].(object)
+	for _, name := range sortedKeys(definitions) {
+		def, _ := definitions[name].(object)
+		schema, err := g.convert(def)
+		if err != nil {
+			return nil, fmt.Errorf("definition %s: %v", name, err)
+		}
+		schemas[g.names[name]] = schema
+		links, _ := def["links"].([]interface{})
+		for _, link := range links {
+			link, _ := link.(object)
+			if err := g.addOperation(paths, link); err != nil {
+				return nil, fmt.Errorf("definition %s: %v", name, err)
+			}
+		}
+	}
+	for name, schema := range additionalSchemas() {
+		schemas[name] = schema
+	}
+	for path, ops := range additionalPaths() {
+		paths[path] = ops
+	}
+	return object{
+		"openapi": Version,
+		"info": object{
+			"title":       "Kraken admin API",
+			"description": "Manages the servers of krakend, their mount points and settings.",
+			"version":     "1",
+		},
+		"paths": paths,
+		"components": object{
+			"schemas": schemas,
+			"responses": object{
+				"Error": object{
+					"description": "The request failed",
+					"content": object{
+						"application/json": object{"schema": componentRef("APIError")},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func componentRef(name string) object {
+	return object{"$ref": "#/components/schemas/" + name}
+}
+
+// convert converts the JSON schema node to an OpenAPI schema.
+func (g *generator) convert(node object) (object, error) {
+	if ref, ok := node["$ref"].(string); ok {
+		return g.ref(ref)
+	}
+	schema := object{}
+	for key, v := range node {
+		switch key {
+		case "type", "enum", "format", "description", "default", "minimum", "maximum", "pattern", "required", "readOnly":
+			schema[key] = v
+		case "items":
+			items, _ := v.(object)
+			s, err := g.convert(items)
+			if err != nil {
+				return nil, err
+			}
+			schema[key] = s
+		case "properties":
+			props, _ := v.(object)
+			converted := object{}
+			for name, prop := range props {
+				prop, _ := prop.(object)
+				s, err := g.convert(prop)
+				if err != nil {
+					return nil, fmt.Errorf("property %s: %v", name, err)
+				}
+				converted[name] = s
+			}
+			schema[key] = converted
+		case "patternProperties":
+			// OpenAPI 3.0 has no patternProperties: the values of the properties
+			// are described by additionalProperties, if they all have the same schema.
+			props, _ := v.(object)
+			if len(props) != 1 {
+				schema["additionalProperties"] = true
+				continue
+			}
+			for _, prop := range props {
+				prop, _ := prop.(object)
+				s, err := g.convert(prop)
+				if err != nil {
+					return nil, err
+				}
+				schema["additionalProperties"] = s
+			}
+		}
+	}
+	return schema, nil
+}
+
+// ref returns the schema referred to by ref: a reference to its component
+// if it is a top-level definition, or else the converted schema.
+func (g *generator) ref(ref string) (object, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported reference %q", ref)
+	}
+	parts := strings.Split(ref[2:], "/")
+	if len(parts) == 2 && parts[0] == "definitions" {
+		if name, ok := g.names[parts[1]]; ok {
+			return componentRef(name), nil
+		}
+	}
+	node := g.root
+	for _, part := range parts {
+		next, ok := node[part].(object)
+		if !ok {
+			return nil, fmt.Errorf("unresolved reference %q", ref)
+		}
+		node = next
+	}
+	return g.convert(node)
+}
+
+// hrefParam matches the params of a link href, e.g {(#/definitions/server/definitions/port)}.
+var hrefParam = regexp.MustCompile(`\{\((#/definitions/[^/)]+/definitions/[^/)]+)\)\}`)
+
+// path returns the path template of href, and its params, named like the params of the routes,
+// e.g /servers/{server-port} for /servers/{(#/definitions/server/definitions/port)}.
+func (g *generator) path(href string) (string, []interface{}, error) {
+	var params []interface{}
+	var err error
+	path := hrefParam.ReplaceAllStringFunc(href, func(m string) string {
+		ref := hrefParam.FindStringSubmatch(m)[1]
+		parts := strings.Split(ref, "/")
+		name := parts[2] + "-" + parts[4]
+		schema, rerr := g.ref(ref)
+		if rerr != nil {
+			err = rerr
+		}
+		params = append(params, object{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+		return "{" + name + "}"
+	})
+	return path, params, err
+}
+
+// operationID returns the ID of the operation on path with method,
+// which is also the name of the client function, e.g getServersOneMounts.
+func operationID(method string, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(path, "/") {
+		switch {
+		case segment == "":
+		case strings.HasPrefix(segment, "{"):
+			id += "One"
+		default:
+			id += symbolName(segment)
+		}
+	}
+	return id
+}
+
+func (g *generator) addOperation(paths object, link object) error {
+	href, _ := link["href"].(string)
+	method, _ := link["method"].(string)
+	path, params, err := g.path(href)
+	if err != nil {
+		return err
+	}
+	op := object{
+		"operationId": operationID(method, path),
+		"summary":     link["title"],
+		"tags":        []string{strings.Split(path, "/")[1]},
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if in, ok := link["schema"].(object); ok {
+		schema, err := g.convert(in)
+		if err != nil {
+			return err
+		}
+		op["requestBody"] = object{
+			"required": true,
+			"content":  object{"application/json": object{"schema": schema}},
+		}
+	}
+	status := http.StatusOK
+	if method == "POST" {
+		status = http.StatusCreated
+	}
+	response := object{"description": http.StatusText(status)}
+	if out, ok := link["targetSchema"].(object); ok {
+		schema, err := g.convert(out)
+		if err != nil {
+			return err
+		}
+		response["content"] = object{"application/json": object{"schema": schema}}
+	}
+	op["responses"] = object{
+		fmt.Sprint(status): response,
+		"default":          object{"$ref": "#/components/responses/Error"},
+	}
+
+	ops, _ := paths[path].(object)
+	if ops == nil {
+		ops = object{}
+		paths[path] = ops
+	}
+	ops[strings.ToLower(method)] = op
+	return nil
+}
+
+func sortedKeys(m object) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// schemaOf returns the schema of the JSON encoding of the values of t.
+func schemaOf(t reflect.Type) object {
+	switch t.Kind() {
+	case reflect.Bool:
+		return object{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return object{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return object{"type": "number"}
+	case reflect.String:
+		return object{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return object{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return object{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Ptr:
+		return schemaOf(t.Elem())
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return object{"type": "string", "format": "date-time"}
+		}
+		props := object{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := f.Name
+			if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			props[name] = schemaOf(f.Type)
+		}
+		return object{"type": "object", "properties": props}
+	}
+	return object{}
+}
+
+// additionalSchemas returns the schemas of the types used by the routes not described by the schema.
+func additionalSchemas() object {
+	apiError := schemaOf(reflect.TypeOf(admin.APIError{}))
+	apiError["description"] = "The error of a failed request: bad_request_error for 4xx responses, api_internal_error for 5xx ones."
+	apiError["required"] = []string{"type", "msg"}
+	props := apiError["properties"].(object)
+	props["type"].(object)["enum"] = []admin.APIErrorType{admin.APIErrorTypeBadRequest, admin.APIErrorTypeAPIInternal}
+
+	health := schemaOf(reflect.TypeOf(admin.Health{}))
+	health["properties"].(object)["status"].(object)["enum"] = []string{admin.HealthStatusOK, admin.HealthStatusUnavailable}
+
+	return object{
+		"APIError": apiError,
+		"Health":   health,
+		// An event is encoded with the name of its type, and its resource depends on its type.
+		"Event": object{
+			"type": "object",
+			"properties": object{
+				"ID":       object{"type": "integer"},
+				"Time":     object{"type": "string", "format": "date-time"},
+				"Type":     object{"type": "string"},
+				"Resource": object{"type": "object"},
+			},
+		},
+	}
+}
+
+func queryParam(name string, description string, schema object) object {
+	return object{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      schema,
+	}
+}
+
+func jsonResponse(description string, schema object) object {
+	return object{
+		"description": description,
+		"content":     object{"application/json": object{"schema": schema}},
+	}
+}
+
+// additionalPaths returns the routes of the admin API not described by the schema.
+func additionalPaths() object {
+	eventsParam := queryParam(admin.EventsQueryKey, "Comma-separated events to receive, e.g mount or file.serve; all events if empty", object{"type": "string"})
+	envelopeParam := queryParam(admin.EnvelopeQueryKey, "Envelope of the events", object{"type": "string", "enum": []string{"cloudevents"}})
+	healthOp := func(id string, summary string) object {
+		return object{
+			"operationId": id,
+			"summary":     summary,
+			"tags":        []string{"health"},
+			"responses": object{
+				"200": jsonResponse("OK", componentRef("Health")),
+			},
+		}
+	}
+	readyz := healthOp("getReadyz", "Readiness of krakend and of its servers")
+	readyz["responses"].(object)["503"] = jsonResponse("A server is not listening", componentRef("Health"))
+
+	return object{
+		"/events": object{
+			"get": object{
+				"operationId": "getEvents",
+				"summary":     "Stream the events, over a WebSocket, or as server-sent events or newline-delimited JSON depending on the Accept header",
+				"tags":        []string{"events"},
+				"parameters": []interface{}{
+					eventsParam,
+					queryParam(admin.SinceQueryKey, "Replay the recent events following the event with this ID first", object{"type": "integer"}),
+					envelopeParam,
+				},
+				"responses": object{
+					"200": object{
+						"description": "The stream of events",
+						"content": object{
+							"text/event-stream":    object{"schema": object{"type": "string"}},
+							"application/x-ndjson": object{"schema": componentRef("Event")},
+						},
+					},
+					"default": object{"$ref": "#/components/responses/Error"},
+				},
+			},
+		},
+		"/events/history": object{
+			"get": object{
+				"operationId": "getEventsHistory",
+				"summary":     "Recent events",
+				"tags":        []string{"events"},
+				"parameters": []interface{}{
+					eventsParam,
+					queryParam(admin.SinceQueryKey, "Return only the events following the event with this ID", object{"type": "integer"}),
+					queryParam(admin.LimitQueryKey, "Return only the most recent events", object{"type": "integer"}),
+					envelopeParam,
+				},
+				"responses": object{
+					"200":     jsonResponse("OK", object{"type": "array", "items": componentRef("Event")}),
+					"default": object{"$ref": "#/components/responses/Error"},
+				},
+			},
+		},
+		"/metrics": object{
+			"get": object{
+				"operationId": "getMetrics",
+				"summary":     "Prometheus metrics",
+				"tags":        []string{"metrics"},
+				"responses": object{
+					"200": object{
+						"description": "OK",
+						"content":     object{"text/plain": object{"schema": object{"type": "string"}}},
+					},
+				},
+			},
+		},
+		"/healthz": object{"get": healthOp("getHealthz", "Health of krakend")},
+		"/readyz":  object{"get": readyz},
+		"/version": object{"get": healthOp("getVersion", "Build info and uptime of krakend")},
+		"/openapi.json": object{
+			"get": object{
+				"operationId": "getOpenapi",
+				"summary":     "This OpenAPI document",
+				"tags":        []string{"openapi"},
+				"responses": object{
+					"200": jsonResponse("OK", object{"type": "object"}),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/vincent-petithory/kraken/admin"
+	"github.com/vincent-petithory/kraken/admin/openapi"
+)
+
+func TestOpenAPI(t *testing.T) {
+	_, ts := newTestAdmin(t)
+	defer ts.Close()
+
+	schema, err := ioutil.ReadFile("schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := openapi.Generate(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := get(t, ts.URL+"/openapi.json")
+	if b != string(expected) {
+		t.Fatal("openapi.json is not up to date with schema.json, run go generate")
+	}
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(b), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != openapi.Version {
+		t.Errorf("expected OpenAPI version %s, got %s", openapi.Version, doc.OpenAPI)
+	}
+
+	// Each route is documented, and each documented path is a route.
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := make(map[string]bool)
+	router := admin.NewServerPoolRoutes(u).(*admin.GorillaRouter).Router
+	if err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		routes[path] = true
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for path := range routes {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("route %s is not documented", path)
+		}
+	}
+	param := regexp.MustCompile(`\{[^}]+\}`)
+	for path, ops := range doc.Paths {
+		if !routes[path] {
+			t.Errorf("documented path %s is not a route", path)
+			continue
+		}
+		// The routes of the schema reply with their methods.
+		req, err := http.NewRequest("OPTIONS", ts.URL+param.ReplaceAllString(path, "1"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		allow := resp.Header.Get("Allow")
+		if allow == "" {
+			continue
+		}
+		allowed := strings.Split(allow, ", ")
+		sort.Strings(allowed)
+		var methods []string
+		for method := range ops {
+			methods = append(methods, strings.ToUpper(method))
+		}
+		sort.Strings(methods)
+		if strings.Join(methods, ", ") != strings.Join(allowed, ", ") {
+			t.Errorf("expected %s to document the methods %v, got %v", path, allowed, methods)
+		}
+	}
+}
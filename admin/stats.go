@@ -0,0 +1,134 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/vincent-petithory/kraken"
+)
+
+// StatsTopQueryKey is the query param setting the number of files listed
+// in the top downloads of the stats.
+const StatsTopQueryKey = "top"
+
+const defaultStatsTop = 10
+
+func statsTopParam(r *http.Request) (int, error) {
+	v := r.URL.Query().Get(StatsTopQueryKey)
+	if v == "" {
+		return defaultStatsTop, nil
+	}
+	top, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, err
+	}
+	if top < 0 {
+		return 0, fmt.Errorf("negative %s %d", StatsTopQueryKey, top)
+	}
+	return top, nil
+}
+
+func newStatsDataFromSnapshot(snap kraken.StatsSnapshot) *Stats {
+	stats := &Stats{
+		Since:         snap.Since.Format(time.RFC3339),
+		Requests:      int(snap.Requests),
+		Bytes:         int(snap.Bytes),
+		Statuses:      make(StatusCounts, len(snap.Statuses)),
+		TopFiles:      make([]FileStats, len(snap.TopFiles)),
+		UniqueClients: snap.UniqueClients,
+	}
+	for status, count := range snap.Statuses {
+		stats.Statuses[strconv.Itoa(status)] = int(count)
+	}
+	for i, f := range snap.TopFiles {
+		stats.TopFiles[i] = FileStats{Path: f.Path, Count: int(f.Count)}
+	}
+	return stats
+}
+
+// statsHandler records the requests served by h in the stats of srv.
+func statsHandler(srv *kraken.Server, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := r.URL.Path
+		w, r, rsl := recordResponse(w, r)
+		r, target := kraken.WithMountTarget(r)
+		h.ServeHTTP(w, r)
+
+		status := rsl.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		clientIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+		srv.Stats.Record(clientIP, r.Method, urlPath, status, rsl.Size)
+		if *target != "" {
+			// The mount map made the request path relative to the mount target.
+			srv.Stats.Mount(*target).Record(clientIP, r.Method, r.URL.Path, status, rsl.Size)
+		}
+	})
+}
+
+// LoadStats loads the stats saved in file by SaveStats.
+// They are restored when a server is created on the same port.
+// It is not an error if file doesn't exist.
+func (sph *ServerPoolHandler) LoadStats(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	saved := make(map[uint16]*kraken.ServerStats)
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	sph.statsMu.Lock()
+	sph.savedStats = saved
+	sph.statsMu.Unlock()
+	return nil
+}
+
+// SaveStats saves the stats of the servers in file,
+// along with the ones loaded by LoadStats which were not restored.
+func (sph *ServerPoolHandler) SaveStats(file string) error {
+	stats := make(map[uint16]*kraken.ServerStats)
+	sph.statsMu.Lock()
+	for port, ss := range sph.savedStats {
+		stats[port] = ss
+	}
+	sph.statsMu.Unlock()
+	for _, srv := range sph.ServerPool.Servers() {
+		if srv.Port != 0 {
+			stats[srv.Port] = srv.Stats
+		}
+	}
+	b, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// restoreStats sets the stats of srv to the saved stats of port, if any.
+func (sph *ServerPoolHandler) restoreStats(srv *kraken.Server, port uint16) {
+	sph.statsMu.Lock()
+	defer sph.statsMu.Unlock()
+	if ss, ok := sph.savedStats[port]; ok {
+		srv.Stats = ss
+		delete(sph.savedStats, port)
+	}
+}
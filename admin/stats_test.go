@@ -0,0 +1,95 @@
+package admin_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/vincent-petithory/kraken/admin"
+)
+
+func TestStats(t *testing.T) {
+	c, ts := newTestAdmin(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	dir, err := ioutil.TempDir("", "kraken-stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(srv.Port)
+	mount, err := c.PostServersOneMounts(port, &admin.CreateMountIn{Source: dir, Target: "/files"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvURL := fmt.Sprintf("http://127.0.0.1:%d", srv.Port)
+	get(t, srvURL+"/files/hello.txt")
+	get(t, srvURL+"/files/hello.txt")
+	get(t, srvURL+"/files/missing.txt")
+	get(t, srvURL+"/elsewhere")
+
+	stats, err := c.GetServersOneStats(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Requests != 4 {
+		t.Errorf("expected 4 server requests, got %d", stats.Requests)
+	}
+	if stats.UniqueClients != 1 {
+		t.Errorf("expected 1 unique client, got %d", stats.UniqueClients)
+	}
+	if stats.Statuses["200"] != 2 || stats.Statuses["404"] != 2 {
+		t.Errorf("expected 2 200s and 2 404s, got %v", stats.Statuses)
+	}
+	if len(stats.TopFiles) != 1 || stats.TopFiles[0] != (admin.FileStats{Path: "/files/hello.txt", Count: 2}) {
+		t.Errorf("expected /files/hello.txt to be downloaded twice, got %v", stats.TopFiles)
+	}
+
+	mountStats, err := c.GetServersOneMountsOneStats(port, mount.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mountStats.Requests != 3 {
+		t.Errorf("expected 3 mount requests, got %d", mountStats.Requests)
+	}
+	if mountStats.Bytes >= stats.Bytes {
+		t.Errorf("expected the mount bytes to exclude the unmounted request, got %d and %d", mountStats.Bytes, stats.Bytes)
+	}
+	if len(mountStats.TopFiles) != 1 || mountStats.TopFiles[0] != (admin.FileStats{Path: "/hello.txt", Count: 2}) {
+		t.Errorf("expected /hello.txt to be downloaded twice, got %v", mountStats.TopFiles)
+	}
+
+	if _, err := c.DeleteServersOneMountsOneStats(port, mount.Id); err != nil {
+		t.Fatal(err)
+	}
+	if mountStats, err = c.GetServersOneMountsOneStats(port, mount.Id); err != nil {
+		t.Fatal(err)
+	} else if mountStats.Requests != 0 {
+		t.Errorf("expected mount stats to be reset, got %d requests", mountStats.Requests)
+	}
+	if stats, err = c.GetServersOneStats(port); err != nil {
+		t.Fatal(err)
+	} else if stats.Requests != 4 {
+		t.Errorf("expected server stats to be kept on mount reset, got %d requests", stats.Requests)
+	}
+	if _, err := c.DeleteServersOneStats(port); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = c.GetServersOneStats(port); err != nil {
+		t.Fatal(err)
+	} else if stats.Requests != 0 {
+		t.Errorf("expected server stats to be reset, got %d requests", stats.Requests)
+	}
+}
@@ -0,0 +1,178 @@
+package admin
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sseContentType    = "text/event-stream"
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// streamContentType returns the content type of the event stream accepted by r,
+// newline-delimited JSON by default, or "" if none is.
+func streamContentType(r *http.Request) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return ndjsonContentType
+	}
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case sseContentType:
+			return sseContentType
+		case ndjsonContentType, "application/json", "application/*", "*/*":
+			return ndjsonContentType
+		}
+	}
+	return ""
+}
+
+// serveStream streams the events of c in the response, written by write,
+// until the client goes away.
+func (s *serverPoolEventsHandler) serveStream(w http.ResponseWriter, r *http.Request, c *conn, contentType string, write func(io.Writer, *Event) error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s.sub <- c
+	defer func() {
+		s.unsub <- c
+	}()
+	if c.resume {
+		for _, event := range <-c.replay {
+			if err := write(w, event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+	ticker := time.NewTicker(pingWait)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-c.eventCh:
+			if !ok {
+				return
+			}
+			if err := write(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if contentType == sseContentType {
+				// A comment keeps the connection open through proxies.
+				io.WriteString(w, ": ping\n\n")
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// EnvelopeQueryKey is the query param setting the envelope of the events.
+// With "cloudevents", events are sent as CloudEvents.
+const EnvelopeQueryKey = "envelope"
+
+const envelopeCloudEvents = "cloudevents"
+
+// envelopeParam returns the function wrapping the events in the envelope
+// set by the envelope query param.
+func (s *serverPoolEventsHandler) envelopeParam(r *http.Request) (func(*Event) interface{}, error) {
+	return envelopeEncoder(r.URL.Query().Get(EnvelopeQueryKey), s.routes)
+}
+
+// envelopeEncoder returns the function wrapping the events in envelope:
+// none if it is empty, or cloudevents.
+func envelopeEncoder(envelope string, rr RouteReverser) (func(*Event) interface{}, error) {
+	switch envelope {
+	case "":
+		return func(event *Event) interface{} { return event }, nil
+	case envelopeCloudEvents:
+		return func(event *Event) interface{} { return NewCloudEvent(event, rr) }, nil
+	default:
+		return nil, fmt.Errorf("unknown %s %q", EnvelopeQueryKey, envelope)
+	}
+}
+
+const (
+	// CloudEventsSpecVersion is the version of the CloudEvents specification CloudEvent follows.
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventTypePrefix prefixes the name of the event type in the type of a CloudEvent.
+	CloudEventTypePrefix = "com.github.vincent-petithory.kraken."
+)
+
+// CloudEvent is an event in the structured JSON format of CloudEvents.
+// See https://cloudevents.io.
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data"`
+}
+
+// NewCloudEvent returns event as a CloudEvent. Its source is the URL of the server
+// or mount the event relates to, and the subject of a file serve event is the path served.
+func NewCloudEvent(event *Event, rr RouteReverser) *CloudEvent {
+	ce := &CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              strconv.FormatUint(event.ID, 10),
+		Source:          RouteEvents{}.Location(rr).String(),
+		Type:            CloudEventTypePrefix + event.Type.String(),
+		Time:            event.Time,
+		DataContentType: "application/json",
+		Data:            event.Resource,
+	}
+	port, mount, path := eventScope(event)
+	switch {
+	case mount != "":
+		ce.Source = RouteServersOneMountsOne{ServerPort: strconv.Itoa(port), MountId: mount}.Location(rr).String()
+	case port != 0:
+		ce.Source = RouteServersOne{ServerPort: strconv.Itoa(port)}.Location(rr).String()
+	}
+	ce.Subject = path
+	return ce
+}
+
+// eventScope returns the port of the server and the ID of the mount event relates to,
+// and the path served for a file serve event.
+func eventScope(event *Event) (port int, mount string, path string) {
+	res := event.Resource
+	switch r := res.(type) {
+	case ServerEvent:
+		res = &r
+	case MountEvent:
+		res = &r
+	case FileServeEvent:
+		res = &r
+	}
+	switch r := res.(type) {
+	case *ServerEvent:
+		return r.Server.Port, "", ""
+	case *MountEvent:
+		return r.Server.Port, r.Mount.Id, ""
+	case *FileServeEvent:
+		return r.Server.Port, r.Mount, r.Path
+	}
+	return 0, "", ""
+}
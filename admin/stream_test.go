@@ -0,0 +1,178 @@
+package admin_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vincent-petithory/kraken/admin"
+)
+
+func TestEventTypeJSON(t *testing.T) {
+	for _, typ := range []admin.EventType{
+		admin.EventTypeServerAdd,
+		admin.EventTypeServerRemove,
+		admin.EventTypeMountAdd,
+		admin.EventTypeMountUpdate,
+		admin.EventTypeMountRemove,
+		admin.EventTypeFileServe,
+	} {
+		b, err := json.Marshal(typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := `"` + typ.String() + `"`; string(b) != expected {
+			t.Errorf("expected %d to be encoded as %s, got %s", typ, expected, b)
+		}
+		var decoded admin.EventType
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded != typ {
+			t.Errorf("expected %s to be decoded as %d, got %d", b, typ, decoded)
+		}
+	}
+	var typ admin.EventType
+	if err := json.Unmarshal([]byte("4"), &typ); err != nil {
+		t.Fatal(err)
+	}
+	if typ != admin.EventTypeMountUpdate {
+		t.Errorf("expected code 4 to be decoded as %d, got %d", admin.EventTypeMountUpdate, typ)
+	}
+	if err := json.Unmarshal([]byte(`"mount.unknown"`), &typ); err == nil {
+		t.Error("expected an error decoding an unknown event type")
+	}
+}
+
+func TestMatchEventTypes(t *testing.T) {
+	for _, r := range []struct {
+		Name  string
+		Types []admin.EventType
+	}{
+		{"server", []admin.EventType{admin.EventTypeServerAdd, admin.EventTypeServerRemove}},
+		{"mount", []admin.EventType{admin.EventTypeMountAdd, admin.EventTypeMountUpdate, admin.EventTypeMountRemove}},
+		{"fileserve", []admin.EventType{admin.EventTypeFileServe}},
+		{"mount.update", []admin.EventType{admin.EventTypeMountUpdate}},
+		{"1", []admin.EventType{admin.EventTypeServerAdd}},
+		{"serv", nil},
+		{"42", nil},
+	} {
+		types, err := admin.MatchEventTypes(r.Name)
+		if r.Types == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", r.Name, types)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", r.Name, err)
+			continue
+		}
+		if len(types) != len(r.Types) {
+			t.Errorf("%s: expected %v, got %v", r.Name, r.Types, types)
+			continue
+		}
+		for i := range types {
+			if types[i] != r.Types[i] {
+				t.Errorf("%s: expected %v, got %v", r.Name, r.Types, types)
+				break
+			}
+		}
+	}
+}
+
+// openStream requests the events at u, accepting contentType.
+func openStream(t *testing.T, u string, contentType string) *http.Response {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != contentType {
+		resp.Body.Close()
+		t.Fatalf("expected content type %s, got %s", contentType, ct)
+	}
+	return resp
+}
+
+func TestEventsNDJSON(t *testing.T) {
+	c, ts := newTestAdmin(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	if _, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	waitEvents(t, c, 1)
+
+	resp := openStream(t, ts.URL+"/events?since=0&e=server", "application/x-ndjson")
+	defer resp.Body.Close()
+	if _, err := c.DeleteServers(); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(resp.Body)
+	for i, typ := range []admin.EventType{admin.EventTypeServerAdd, admin.EventTypeServerRemove} {
+		var evt admin.Event
+		if err := dec.Decode(&evt); err != nil {
+			t.Fatal(err)
+		}
+		if evt.ID != uint64(i+1) || evt.Type != typ {
+			t.Errorf("expected event %d to have ID %d and type %s, got %d and %s", i, i+1, typ, evt.ID, evt.Type)
+		}
+		if _, ok := evt.Resource.(*admin.ServerEvent); !ok {
+			t.Errorf("expected a server event, got %T", evt.Resource)
+		}
+	}
+}
+
+func TestEventsSSECloudEvents(t *testing.T) {
+	c, ts := newTestAdmin(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitEvents(t, c, 1)
+
+	resp := openStream(t, ts.URL+"/events?since=0&envelope=cloudevents", "text/event-stream")
+	defer resp.Body.Close()
+	br := bufio.NewReader(resp.Body)
+	var lines []string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 || lines[0] != "id: 1" || lines[1] != "event: server.add" || !strings.HasPrefix(lines[2], "data: ") {
+		t.Fatalf("unexpected server-sent event %q", lines)
+	}
+	var ce admin.CloudEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &ce); err != nil {
+		t.Fatal(err)
+	}
+	if ce.SpecVersion != admin.CloudEventsSpecVersion || ce.ID != "1" || ce.Type != admin.CloudEventTypePrefix+"server.add" {
+		t.Errorf("unexpected cloud event %+v", ce)
+	}
+	if expected := ts.URL + "/servers/" + strconv.Itoa(srv.Port); ce.Source != expected {
+		t.Errorf("expected the source to be %s, got %s", expected, ce.Source)
+	}
+}
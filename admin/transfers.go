@@ -0,0 +1,199 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vincent-petithory/kraken"
+)
+
+// errTransferCanceled is returned when writing the response of a canceled transfer.
+var errTransferCanceled = errors.New("transfer canceled")
+
+// transfer is a response being sent by a server.
+type transfer struct {
+	id         string
+	port       uint16
+	remoteAddr string
+	method     string
+	path       string
+	userAgent  string
+	started    time.Time
+	cancel     context.CancelFunc
+	rc         *http.ResponseController
+
+	mu       sync.Mutex
+	mount    string
+	size     int64
+	bytes    int64
+	canceled bool
+}
+
+// abort cancels the transfer: the response is not written anymore,
+// and the connection with the client is closed.
+func (t *transfer) abort() {
+	t.mu.Lock()
+	t.canceled = true
+	t.mu.Unlock()
+	t.cancel()
+	// Unblock a write waiting for the client.
+	t.rc.SetWriteDeadline(time.Now())
+}
+
+func (t *transfer) isCanceled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.canceled
+}
+
+// transferWriter records the progress of a transfer.
+type transferWriter struct {
+	http.ResponseWriter
+	t           *transfer
+	target      *string
+	wroteHeader bool
+}
+
+func (tw *transferWriter) recordHeader() {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.t.mu.Lock()
+	defer tw.t.mu.Unlock()
+	// The mount map has routed the request once the response starts.
+	if *tw.target != "" {
+		tw.t.mount = mountID(*tw.target)
+	}
+	if cl, err := strconv.ParseInt(tw.Header().Get("Content-Length"), 10, 64); err == nil {
+		tw.t.size = cl
+	}
+}
+
+func (tw *transferWriter) WriteHeader(s int) {
+	tw.recordHeader()
+	tw.ResponseWriter.WriteHeader(s)
+}
+
+func (tw *transferWriter) Write(b []byte) (int, error) {
+	tw.recordHeader()
+	if tw.t.isCanceled() {
+		return 0, errTransferCanceled
+	}
+	n, err := tw.ResponseWriter.Write(b)
+	tw.t.mu.Lock()
+	tw.t.bytes += int64(n)
+	tw.t.mu.Unlock()
+	return n, err
+}
+
+func (tw *transferWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *transferWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := tw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	return hj.Hijack()
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for use by http.ResponseController.
+func (tw *transferWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// transfersHandler registers the requests served by h for srv as transfers, while they are served.
+func (sph *ServerPoolHandler) transfersHandler(srv *kraken.Server, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		r, target := kraken.WithMountTarget(r.WithContext(ctx))
+		t := &transfer{
+			port:       srv.Port,
+			remoteAddr: r.RemoteAddr,
+			method:     r.Method,
+			path:       r.URL.Path,
+			userAgent:  r.UserAgent(),
+			started:    time.Now(),
+			cancel:     cancel,
+			rc:         http.NewResponseController(w),
+			size:       -1,
+		}
+		sph.addTransfer(t)
+		defer sph.removeTransfer(t)
+		h.ServeHTTP(&transferWriter{ResponseWriter: w, t: t, target: target}, r)
+		if t.isCanceled() {
+			// Close the connection, so that the client knows the response is incomplete.
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+func (sph *ServerPoolHandler) addTransfer(t *transfer) {
+	sph.transfersMu.Lock()
+	defer sph.transfersMu.Unlock()
+	if sph.transfers == nil {
+		sph.transfers = make(map[string]*transfer)
+	}
+	sph.transfersLastID++
+	t.id = strconv.FormatUint(sph.transfersLastID, 10)
+	sph.transfers[t.id] = t
+}
+
+func (sph *ServerPoolHandler) removeTransfer(t *transfer) {
+	sph.transfersMu.Lock()
+	defer sph.transfersMu.Unlock()
+	delete(sph.transfers, t.id)
+}
+
+// transfersList returns the transfers in progress, oldest first.
+func (sph *ServerPoolHandler) transfersList() []*transfer {
+	sph.transfersMu.Lock()
+	defer sph.transfersMu.Unlock()
+	transfers := make([]*transfer, 0, len(sph.transfers))
+	for _, t := range sph.transfers {
+		transfers = append(transfers, t)
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].started.Before(transfers[j].started)
+	})
+	return transfers
+}
+
+func (sph *ServerPoolHandler) getTransfer(id string) *transfer {
+	sph.transfersMu.Lock()
+	defer sph.transfersMu.Unlock()
+	return sph.transfers[id]
+}
+
+func newTransferDataFromTransfer(t *transfer) *Transfer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	transfer := &Transfer{
+		Id:         t.id,
+		Port:       int(t.port),
+		Mount:      t.mount,
+		RemoteAddr: t.remoteAddr,
+		Method:     t.method,
+		Path:       t.path,
+		UserAgent:  t.userAgent,
+		Bytes:      int(t.bytes),
+		Size:       int(t.size),
+		Started:    t.started.Format(time.RFC3339),
+	}
+	if elapsed := time.Since(t.started).Seconds(); elapsed > 0 {
+		transfer.Rate = int(float64(t.bytes) / elapsed)
+	}
+	return transfer
+}
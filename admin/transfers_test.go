@@ -0,0 +1,109 @@
+package admin_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken/admin"
+	"github.com/vincent-petithory/kraken/admin/client"
+)
+
+// waitTransfers waits for n transfers to be in progress, and returns them.
+func waitTransfers(t *testing.T, c *client.Client, n int) []admin.Transfer {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		transfers, err := c.GetTransfers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(transfers) == n || time.Now().After(deadline) {
+			return transfers
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTransfers(t *testing.T) {
+	c, ts := newTestAdmin(t)
+	defer ts.Close()
+	defer c.DeleteServers()
+
+	dir, err := ioutil.TempDir("", "kraken-transfers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The file is larger than what the connection buffers,
+	// so that it is not sent until the client reads it.
+	const size = 64 << 20
+	f, err := os.Create(filepath.Join(dir, "big.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	srv, err := c.PostServers(&admin.CreateRandomServerIn{BindAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mount, err := c.PostServersOneMounts(strconv.Itoa(srv.Port), &admin.CreateMountIn{Source: dir, Target: "/files"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/files/big.bin", srv.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadFull(resp.Body, make([]byte, 1024)); err != nil {
+		t.Fatal(err)
+	}
+
+	transfers := waitTransfers(t, c, 1)
+	if len(transfers) != 1 {
+		t.Fatalf("expected 1 transfer, got %d", len(transfers))
+	}
+	tr := transfers[0]
+	if tr.Port != srv.Port || tr.Mount != mount.Id || tr.Method != "GET" || tr.Path != "/files/big.bin" || tr.Size != size {
+		t.Errorf("unexpected transfer %+v", tr)
+	}
+	if tr.Bytes < 1024 || tr.Bytes >= size {
+		t.Errorf("expected the transfer to be in progress, sent %d bytes", tr.Bytes)
+	}
+	if _, err := time.Parse(time.RFC3339, tr.Started); err != nil {
+		t.Errorf("unexpected start time: %v", err)
+	}
+	if one, err := c.GetTransfersOne(tr.Id); err != nil {
+		t.Fatal(err)
+	} else if one.Id != tr.Id || one.Path != tr.Path {
+		t.Errorf("expected transfer %+v, got %+v", tr, one)
+	}
+
+	if _, err := c.DeleteTransfersOne(tr.Id); err != nil {
+		t.Fatal(err)
+	}
+	n, err := io.Copy(ioutil.Discard, resp.Body)
+	if err == nil || n+1024 >= size {
+		t.Errorf("expected the canceled transfer to end early, got %d bytes (%v)", n+1024, err)
+	}
+	if transfers := waitTransfers(t, c, 0); len(transfers) != 0 {
+		t.Errorf("expected no transfers, got %+v", transfers)
+	}
+	if _, err := c.GetTransfersOne(tr.Id); err == nil {
+		t.Error("expected an error getting a finished transfer")
+	}
+	if _, err := c.DeleteTransfersOne(tr.Id); err == nil {
+		t.Error("expected an error canceling a finished transfer")
+	}
+}
@@ -2,9 +2,13 @@ package admin
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -14,6 +18,12 @@ const EventsQueryKey = "e"
 
 type (
 	Event struct {
+		// ID numbers the events in the order they were sent, starting at 1.
+		ID uint64
+		// Epoch identifies the numbering of ID: it changes when the IDs
+		// start again at 1, e.g when krakend restarts without saving its events.
+		Epoch    string
+		Time     time.Time
 		Type     EventType
 		Resource interface{}
 	}
@@ -21,6 +31,9 @@ type (
 )
 
 type rawevt struct {
+	ID       uint64
+	Epoch    string
+	Time     time.Time
 	Type     EventType
 	Resource json.RawMessage
 }
@@ -50,6 +63,9 @@ func (e *Event) UnmarshalJSON(b []byte) error {
 		return err
 	}
 	*e = Event{
+		ID:       evt.ID,
+		Epoch:    evt.Epoch,
+		Time:     evt.Time,
 		Type:     evt.Type,
 		Resource: res,
 	}
@@ -65,6 +81,83 @@ const (
 	EventTypeFileServe
 )
 
+var eventTypeNames = map[EventType]string{
+	EventTypeServerAdd:    "server.add",
+	EventTypeServerRemove: "server.remove",
+	EventTypeMountAdd:     "mount.add",
+	EventTypeMountUpdate:  "mount.update",
+	EventTypeMountRemove:  "mount.remove",
+	EventTypeFileServe:    "file.serve",
+}
+
+// String returns the name of the event type, e.g mount.update.
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return strconv.Itoa(int(t))
+}
+
+// ParseEventType returns the event type of name, or of its numeric code.
+func ParseEventType(name string) (EventType, error) {
+	for t, n := range eventTypeNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	if code, err := strconv.Atoi(name); err == nil {
+		if _, ok := eventTypeNames[EventType(code)]; ok {
+			return EventType(code), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown event type %q", name)
+}
+
+// MatchEventTypes returns the event types selected by name: either an event type
+// or its numeric code, or a kind of events: server, mount or fileserve.
+func MatchEventTypes(name string) ([]EventType, error) {
+	if name == "fileserve" {
+		return []EventType{EventTypeFileServe}, nil
+	}
+	if t, err := ParseEventType(name); err == nil {
+		return []EventType{t}, nil
+	}
+	var types []EventType
+	for t, n := range eventTypeNames {
+		if strings.HasPrefix(n, name+".") {
+			types = append(types, t)
+		}
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("unknown event %q", name)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types, nil
+}
+
+// MarshalJSON encodes the event type as its name.
+func (t EventType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON decodes the name of an event type, or its numeric code.
+func (t *EventType) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		var code int
+		if err := json.Unmarshal(b, &code); err != nil {
+			return err
+		}
+		name = strconv.Itoa(code)
+	}
+	et, err := ParseEventType(name)
+	if err != nil {
+		return err
+	}
+	*t = et
+	return nil
+}
+
 type (
 	ServerEvent struct {
 		Server Server `json:"server"`
@@ -75,8 +168,24 @@ type (
 	}
 	FileServeEvent struct {
 		Server Server `json:"server"`
-		Path   string `json:"path"`
-		Code   int    `json:"code"`
+		// Mount is the ID of the mount which served the request,
+		// empty if it matched none.
+		Mount string `json:"mount"`
+		// Path is the path of the request, relative to the mount target.
+		Path       string `json:"path"`
+		Code       int    `json:"code"`
+		Method     string `json:"method"`
+		RemoteAddr string `json:"remote_addr"`
+		UserAgent  string `json:"user_agent"`
+		// Bytes is the number of bytes of the response body written,
+		// and ContentLength the announced one, or -1 if unknown.
+		Bytes         int64         `json:"bytes"`
+		ContentLength int64         `json:"content_length"`
+		Duration      time.Duration `json:"duration"`
+		// Range tells whether the client requested only a range of the file.
+		Range bool `json:"range"`
+		// Aborted tells whether the client went away before the response was complete.
+		Aborted bool `json:"aborted"`
 	}
 )
 
@@ -91,17 +200,32 @@ var (
 )
 
 type serverPoolEventsHandler struct {
+	// nconns is the number of subscribed clients, and dropped the number of events
+	// which could not be sent to a client. They are updated atomically.
+	nconns  int64
+	dropped uint64
 	conns   map[*conn]bool
 	events  chan string
 	sub     chan *conn
 	unsub   chan *conn
 	eventCh chan *Event
+	history *eventHistory
+	// routes locate the servers and mounts events relate to.
+	routes RouteReverser
 }
 
+// connBacklog is the number of events queued for a client,
+// e.g while the events it missed are replayed.
+const connBacklog = 64
+
 type conn struct {
-	*websocket.Conn
 	events  map[EventType]bool
 	eventCh chan *Event
+	// resume tells whether the client asked for the events following the event with ID since,
+	// which are sent on replay when it subscribes.
+	resume bool
+	since  uint64
+	replay chan []*Event
 }
 
 func (s *serverPoolEventsHandler) Send(event Event) {
@@ -112,11 +236,20 @@ func (s *serverPoolEventsHandler) Broadcast() {
 	for {
 		select {
 		case conn := <-s.sub:
+			if conn.resume {
+				conn.replay <- s.history.since(conn.since, conn.events, 0)
+			}
 			s.conns[conn] = true
+			atomic.AddInt64(&s.nconns, 1)
 		case conn := <-s.unsub:
+			if !s.conns[conn] {
+				continue
+			}
+			atomic.AddInt64(&s.nconns, -1)
 			delete(s.conns, conn)
 			close(conn.eventCh)
 		case event := <-s.eventCh:
+			s.history.add(event)
 			for c := range s.conns {
 				if ok := c.events[event.Type]; !ok {
 					continue
@@ -124,6 +257,7 @@ func (s *serverPoolEventsHandler) Broadcast() {
 				select {
 				case c.eventCh <- event:
 				case <-time.After(time.Second):
+					atomic.AddUint64(&s.dropped, 1)
 					go func() {
 						s.unsub <- c
 					}()
@@ -133,24 +267,20 @@ func (s *serverPoolEventsHandler) Broadcast() {
 	}
 }
 
+// ServeHTTP streams the events over a websocket, or as Server-Sent Events (text/event-stream)
+// or newline-delimited JSON (application/x-ndjson), depending on the Accept header.
 func (s *serverPoolEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
 
-	events := make(map[EventType]bool)
-	eventsStr := r.URL.Query().Get(EventsQueryKey)
-	if eventsStr != "" {
-		for _, evt := range strings.Split(eventsStr, ",") {
-			evti, err := strconv.Atoi(evt)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			events[EventType(evti)] = true
-		}
-	} else {
+	events, err := eventTypesParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if events == nil {
 		events = map[EventType]bool{
 			EventTypeServerAdd:    true,
 			EventTypeServerRemove: true,
@@ -160,7 +290,56 @@ func (s *serverPoolEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 			EventTypeFileServe:    true,
 		}
 	}
+	since, resume, err := s.history.sinceParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if id := r.Header.Get("Last-Event-ID"); id != "" && !resume {
+		// An EventSource reconnecting after the event it last received.
+		if since, err = strconv.ParseUint(id, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid Last-Event-ID %q", id), http.StatusBadRequest)
+			return
+		}
+		resume = true
+	}
+	encode, err := s.envelopeParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c := &conn{
+		events:  events,
+		eventCh: make(chan *Event, connBacklog),
+		resume:  resume,
+		since:   since,
+		replay:  make(chan []*Event, 1),
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebSocket(w, r, c, encode)
+		return
+	}
+	switch streamContentType(r) {
+	case sseContentType:
+		s.serveStream(w, r, c, sseContentType, func(w io.Writer, event *Event) error {
+			b, err := json.Marshal(encode(event))
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, b)
+			return err
+		})
+	case ndjsonContentType:
+		s.serveStream(w, r, c, ndjsonContentType, func(w io.Writer, event *Event) error {
+			return json.NewEncoder(w).Encode(encode(event))
+		})
+	default:
+		http.Error(w, fmt.Sprintf("events are available as %s or %s", sseContentType, ndjsonContentType), http.StatusNotAcceptable)
+	}
+}
 
+func (s *serverPoolEventsHandler) serveWebSocket(w http.ResponseWriter, r *http.Request, c *conn, encode func(*Event) interface{}) {
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -168,7 +347,6 @@ func (s *serverPoolEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 	}
 	defer ws.Close()
 
-	c := &conn{ws, events, make(chan *Event)}
 	s.sub <- c
 	defer func() {
 		s.unsub <- c
@@ -183,9 +361,9 @@ func (s *serverPoolEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 
 	quit := make(chan struct{})
 	go func() {
-		c.SetReadDeadline(time.Now().Add(pongWait))
-		c.SetPongHandler(func(string) error {
-			c.SetReadDeadline(time.Now().Add(pongWait))
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+		ws.SetPongHandler(func(string) error {
+			ws.SetReadDeadline(time.Now().Add(pongWait))
 			return nil
 		})
 	L:
@@ -194,8 +372,8 @@ func (s *serverPoolEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 			case <-quit:
 				return
 			default:
-				if _, _, err := c.ReadMessage(); err != nil {
-					c.Close()
+				if _, _, err := ws.ReadMessage(); err != nil {
+					ws.Close()
 					break L
 				}
 			}
@@ -203,18 +381,23 @@ func (s *serverPoolEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 		close(quit)
 	}()
 	go func() {
+		if c.resume {
+			for _, event := range <-c.replay {
+				ws.WriteJSON(encode(event))
+			}
+		}
 		for {
 			select {
 			case event, ok := <-c.eventCh:
 				if !ok {
-					c.SetWriteDeadline(time.Now().Add(writeWait))
-					c.WriteMessage(websocket.CloseMessage, []byte{})
+					ws.SetWriteDeadline(time.Now().Add(writeWait))
+					ws.WriteMessage(websocket.CloseMessage, []byte{})
 					close(quit)
 					return
 				}
-				c.WriteJSON(event)
+				ws.WriteJSON(encode(event))
 			case <-ticker.C:
-				if err := c.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(writeWait)); err != nil {
+				if err := ws.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(writeWait)); err != nil {
 					close(quit)
 					return
 				}
@@ -228,3 +411,23 @@ func (s *serverPoolEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 	}()
 	<-quit
 }
+
+// eventTypesParam returns the event types of the events query param,
+// or nil if it is not set. See MatchEventTypes.
+func eventTypesParam(r *http.Request) (map[EventType]bool, error) {
+	eventsStr := r.URL.Query().Get(EventsQueryKey)
+	if eventsStr == "" {
+		return nil, nil
+	}
+	events := make(map[EventType]bool)
+	for _, evt := range strings.Split(eventsStr, ",") {
+		types, err := MatchEventTypes(evt)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range types {
+			events[t] = true
+		}
+	}
+	return events, nil
+}
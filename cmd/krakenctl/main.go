@@ -1,15 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/vincent-petithory/kraken/admin"
@@ -20,6 +26,9 @@ import (
 // Environnement var for the url on which the admin service is accessible.
 const envKrakenURL = "KRAKEN_URL"
 
+// How long to wait before reconnecting when listening for events.
+const reconnectDelay = time.Second
+
 func loadKrakenURL() (*url.URL, error) {
 	rawurl := os.Getenv(envKrakenURL)
 	if rawurl == "" {
@@ -39,10 +48,31 @@ func loadKrakenURL() (*url.URL, error) {
 }
 
 type flagSet struct {
-	ServerAddBind    string
-	MountTarget      string
-	FileServerType   string
-	FileServerParams string
+	ServerAddBind     string
+	ServerErrorPages  []string
+	ServerRootIndex   bool
+	AccessLogFile     string
+	AccessLogFormat   string
+	AccessLogRotate   string
+	AccessLogMaxSize  int
+	AccessLogBackups  int
+	AccessLogMaxAge   int
+	MountTarget       string
+	FileServerType    string
+	FileServerParams  string
+	FileServerVerbose bool
+	RewriteStatus     int
+	RewritePosition   int
+	StatsReset        bool
+	EventsSince       int64
+	EventsLimit       int
+	HookEvents        []string
+	HookPort          int
+	HookMount         string
+	HookPath          string
+	HookSecret        string
+	HookRetries       int
+	HookCloudEvents   bool
 }
 
 func clientCmd(client *client.Client, flags *flagSet, runFn func(*client.Client, *flagSet, *cobra.Command, []string)) func(*cobra.Command, []string) {
@@ -75,6 +105,17 @@ func main() {
 		Run:   clientCmd(c, flags, serverAdd),
 	}
 	serverAddCmd.Flags().StringVarP(&flags.ServerAddBind, "bind", "b", "", "Address to bind to, defaults to not bind")
+	serverAddCmd.Flags().StringSliceVarP(&flags.ServerErrorPages, "error-page", "e", nil, "Custom error page, as STATUS=FILE, e.g 404=/srv/404.html or 5xx=/srv/error.json; can be repeated")
+	serverAddCmd.Flags().BoolVarP(&flags.ServerRootIndex, "index", "i", false, "Serve a page listing the mounts on /, when nothing is mounted there")
+	serverAddCmd.Flags().StringVarP(&flags.AccessLogFile, "access-log", "l", "", "Write the access log of the server to this file, instead of the log of krakend")
+	accessLogFlags := func(cmd *cobra.Command) {
+		cmd.Flags().StringVarP(&flags.AccessLogFormat, "format", "f", "", "Format of the access log: common, combined (default) or json")
+		cmd.Flags().StringVar(&flags.AccessLogRotate, "rotate", "", "Rotate the access log file hourly or daily")
+		cmd.Flags().IntVar(&flags.AccessLogMaxSize, "max-size", 0, "Rotate the access log file when it exceeds this size, in megabytes")
+		cmd.Flags().IntVar(&flags.AccessLogBackups, "max-backups", 0, "Number of rotated access log files to keep; all by default")
+		cmd.Flags().IntVar(&flags.AccessLogMaxAge, "max-age", 0, "Number of days to keep the rotated access log files; forever by default")
+	}
+	accessLogFlags(serverAddCmd)
 
 	serverRmCmd := &cobra.Command{
 		Use:   "rm PORT",
@@ -98,10 +139,17 @@ func main() {
 	}
 
 	mountAddCmd := &cobra.Command{
-		Use:   "mount PORT SOURCE",
+		Use:   "mount PORT SOURCE...",
 		Short: "Mount a directory on a server",
 		Long: `Mount the SOURCE directory on the server listening on PORT.
-By default, SOURCE is mounted on /$(basename SOURCE)`,
+By default, SOURCE is mounted on /$(basename SOURCE)
+
+When several SOURCE directories are given, they are layered in a union mount
+using the union file server type: the first SOURCE having a file wins, and
+directory listings are merged. By default, the mount target is based on the first SOURCE.
+
+With the proxy file server type, SOURCE is the URL of an upstream server,
+e.g http://localhost:8080/api, and the mount target must be given.`,
 		Run: clientCmd(c, flags, mountAdd),
 	}
 	mountAddCmd.Flags().StringVarP(&flags.MountTarget, "target", "t", "", "Alternate mount target; it must start with / and not end with /")
@@ -121,6 +169,7 @@ By default, SOURCE is mounted on /$(basename SOURCE)`,
 		Long:  "Lists the available file servers",
 		Run:   clientCmd(c, flags, fileServerList),
 	}
+	fileServersGetCmd.Flags().BoolVarP(&flags.FileServerVerbose, "verbose", "v", false, "Print the params recognized by each file server type")
 
 	eventsCmd := &cobra.Command{
 		Use:   "events [EVENT]...",
@@ -130,16 +179,175 @@ Available EVENTs are:
 
  * server: events related to creating and deleting servers,
  * mount: events related to creating, changing and deleting mounts on a server,
- * fileserve: whenever a file/directory is served by a server.
+ * fileserve: whenever a file/directory is served by a server, with the mount, client, bytes sent and duration of the transfer, and whether it was a range request or was aborted.
 
+An EVENT may also be a single event type: server.add, server.remove, mount.add, mount.update, mount.remove or file.serve.
 `,
 		Run: clientCmd(c, flags, listenEvents),
 	}
+	eventsCmd.Flags().Int64VarP(&flags.EventsSince, "since", "s", -1, "Replay the recent events following the event with this ID first")
+	eventsHistoryCmd := &cobra.Command{
+		Use:   "history [EVENT]...",
+		Short: "Print the recent events of kraken",
+		Long: `Print the recent events kept by kraken, with their ID and time.
+If EVENTs are provided, only those events are printed.`,
+		Run: clientCmd(c, flags, eventsHistory),
+	}
+	eventsHistoryCmd.Flags().Int64VarP(&flags.EventsSince, "since", "s", 0, "Print only the events following the event with this ID")
+	eventsHistoryCmd.Flags().IntVarP(&flags.EventsLimit, "limit", "n", 0, "Print only the most recent events")
+	eventsCmd.AddCommand(eventsHistoryCmd)
+
+	rewriteCmd := &cobra.Command{
+		Use:   "rewrite",
+		Short: "Manage the rewrite rules of a server",
+		Long: `Manage the rewrite rules of a server.
+
+Rules are evaluated in order against the path of each request, before it is routed
+to a mount point. The first rule whose PATTERN matches applies.`,
+	}
+	rewriteListCmd := &cobra.Command{
+		Use:   "ls PORT",
+		Short: "List the rewrite rules of a server",
+		Long:  "List the rewrite rules of the server listening on PORT, in order",
+		Run:   clientCmd(c, flags, rewriteList),
+	}
+	rewriteAddCmd := &cobra.Command{
+		Use:   "add PORT PATTERN ACTION [TARGET]",
+		Short: "Add a rewrite rule to a server",
+		Long: `Add a rewrite rule to the server listening on PORT.
+
+PATTERN is a regular expression matched against the request path.
+ACTION is one of:
+
+ * redirect: redirect the client to TARGET, with status 301, 302 (default), 307 or 308.
+ * rewrite: serve TARGET in place of the request path.
+ * status: reply with the given status, and TARGET as body if any.
+
+TARGET may refer to the capture groups of PATTERN, e.g $1 or ${name}.`,
+		Run: clientCmd(c, flags, rewriteAdd),
+	}
+	rewriteAddCmd.Flags().IntVarP(&flags.RewriteStatus, "status", "s", 0, "Status of a redirect or status rule")
+	rewriteAddCmd.Flags().IntVarP(&flags.RewritePosition, "position", "n", 0, "Position (starting at 1) to insert the rule at; by default, it is added last")
+	rewriteRmCmd := &cobra.Command{
+		Use:   "rm PORT RULE_ID",
+		Short: "Remove a rewrite rule from a server",
+		Long:  "Removes the rewrite rule RULE_ID, on the server listening on PORT",
+		Run:   clientCmd(c, flags, rewriteRm),
+	}
+	rewriteCmd.AddCommand(rewriteListCmd, rewriteAddCmd, rewriteRmCmd)
+
+	accessLogCmd := &cobra.Command{
+		Use:   "accesslog",
+		Short: "Manage the access log of a server",
+		Long: `Manage the access log of a server.
+
+By default, the requests served by a server are logged by krakend, along with its own logs.
+They can be written to a file instead, rotated when it exceeds a size or periodically.
+Rotated files are suffixed with the time of the rotation.`,
+	}
+	accessLogShowCmd := &cobra.Command{
+		Use:   "show PORT",
+		Short: "Print the access log settings of a server",
+		Long:  "Print the access log settings of the server listening on PORT",
+		Run:   clientCmd(c, flags, accessLogShow),
+	}
+	accessLogSetCmd := &cobra.Command{
+		Use:   "set PORT FILE",
+		Short: "Write the access log of a server to a file",
+		Long:  "Write the access log of the server listening on PORT to FILE, in place of its current one",
+		Run:   clientCmd(c, flags, accessLogSet),
+	}
+	accessLogFlags(accessLogSetCmd)
+	accessLogOffCmd := &cobra.Command{
+		Use:   "off PORT",
+		Short: "Stop writing the access log of a server to a file",
+		Long:  "Stop writing the access log of the server listening on PORT to a file; it is logged by krakend again",
+		Run:   clientCmd(c, flags, accessLogOff),
+	}
+	accessLogCmd.AddCommand(accessLogShowCmd, accessLogSetCmd, accessLogOffCmd)
+
+	hooksCmd := &cobra.Command{
+		Use:   "hooks",
+		Short: "Manage the hooks run on events",
+		Long: `Manage the hooks run on events.
+
+A hook posts the events to a URL, or runs a command with the event on its stdin,
+as they occur. Failed deliveries are retried with an increasing delay.`,
+	}
+	hooksListCmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List the hooks",
+		Long:  "List the hooks, with their delivery status",
+		Run:   clientCmd(c, flags, hookList),
+	}
+	hooksAddCmd := &cobra.Command{
+		Use:   "add webhook URL | add command COMMAND [ARG]...",
+		Short: "Add a hook",
+		Long: `Add a hook run on events.
+
+ * webhook: POST the JSON event to URL. With a secret, the request has a
+   X-Kraken-Signature header: sha256= followed by the HMAC-SHA256 of the body.
+ * command: run COMMAND with the JSON event on its stdin, and the type and ID
+   of the event in the KRAKEN_EVENT_TYPE and KRAKEN_EVENT_ID environment variables.
+   krakend must be started with KRAKEN_HOOK_COMMANDS=true to allow them.
+
+e.g, to be notified of downloads:
+
+    krakenctl hooks add -e file.serve command sh -c 'jq -r .Resource.path | xargs notify-send Downloaded'`,
+		Run: clientCmd(c, flags, hookAdd),
+	}
+	hooksAddCmd.Flags().StringSliceVarP(&flags.HookEvents, "event", "e", nil, "Event to run the hook on, e.g mount or file.serve; can be repeated; defaults to all events")
+	hooksAddCmd.Flags().IntVarP(&flags.HookPort, "port", "p", 0, "Run the hook only on the events of the server listening on this port")
+	hooksAddCmd.Flags().StringVarP(&flags.HookMount, "mount", "m", "", "Run the hook only on the events of this mount ID")
+	hooksAddCmd.Flags().StringVar(&flags.HookPath, "path", "", "Run the hook only when a file matching this pattern is served, e.g /*.iso")
+	hooksAddCmd.Flags().StringVarP(&flags.HookSecret, "secret", "s", "", "Secret signing the webhook requests")
+	hooksAddCmd.Flags().IntVarP(&flags.HookRetries, "retries", "r", 0, "Number of retries of a failed delivery; defaults to 3, -1 for none")
+	hooksAddCmd.Flags().BoolVar(&flags.HookCloudEvents, "cloudevents", false, "Deliver the events as CloudEvents")
+	hooksRmCmd := &cobra.Command{
+		Use:   "rm HOOK_ID",
+		Short: "Remove a hook",
+		Long:  "Removes the hook HOOK_ID",
+		Run:   clientCmd(c, flags, hookRm),
+	}
+	hooksCmd.AddCommand(hooksListCmd, hooksAddCmd, hooksRmCmd)
+
+	statsCmd := &cobra.Command{
+		Use:   "stats PORT [MOUNT_ID]",
+		Short: "Print the traffic stats of a server",
+		Long: `Print the traffic stats of the server listening on PORT and of its mounts,
+or of the mount point MOUNT_ID only, followed by the most downloaded files.`,
+		Run: clientCmd(c, flags, statsPrint),
+	}
+	statsCmd.Flags().BoolVarP(&flags.StatsReset, "reset", "r", false, "Reset the stats once printed")
+
+	transfersCmd := &cobra.Command{
+		Use:   "transfers [PORT]",
+		Short: "List the transfers in progress",
+		Long: `List the responses being sent by the servers, or by the server listening on PORT only,
+with their client, progress and throughput.`,
+		Run: clientCmd(c, flags, transferList),
+	}
+	transfersCancelCmd := &cobra.Command{
+		Use:   "cancel TRANSFER_ID",
+		Short: "Cancel a transfer",
+		Long:  "Stops sending the response of the transfer TRANSFER_ID, and closes the connection with its client",
+		Run:   clientCmd(c, flags, transferCancel),
+	}
+	transfersCmd.AddCommand(transfersCancelCmd)
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Print the status of krakend",
+		Long: `Print the version and uptime of krakend, and whether each server is listening.
+Exits with status 1 if krakend can't be reached, or if a server is not listening.`,
+		Run: clientCmd(c, flags, statusPrint),
+	}
 
 	rootCmd := &cobra.Command{
 		Use: "krakenctl",
 	}
 	rootCmd.AddCommand(
+		statusCmd,
 		// server commands
 		serversGetCmd,
 		serverAddCmd,
@@ -149,16 +357,67 @@ Available EVENTs are:
 		mountsGetCmd,
 		mountAddCmd,
 		mountRmCmd,
+		// rewrite commands
+		rewriteCmd,
+		// access log commands
+		accessLogCmd,
+		// stats commands
+		statsCmd,
+		transfersCmd,
 		// fileserver commands
 		fileServersGetCmd,
 		// events
 		eventsCmd,
+		hooksCmd,
 	)
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
 	}
 }
 
+func statusPrint(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		cmd.Usage()
+		return
+	}
+	health, readyErr := client.GetReadyz()
+	if health == nil {
+		log.Fatal(readyErr)
+	}
+	build := health.Build
+	version := build.Version
+	if version == "" {
+		version = "unknown version"
+	}
+	fmt.Printf("krakend %s (%s), %s, up %s since %s\n", version, build.GoVersion, health.Status, health.Uptime, health.Started)
+	if build.Revision != "" {
+		fmt.Printf("revision %s", build.Revision)
+		if build.RevisionTime != "" {
+			fmt.Printf(" of %s", build.RevisionTime)
+		}
+		if build.Modified {
+			fmt.Print(" (modified)")
+		}
+		fmt.Println()
+	}
+	if len(health.Servers) > 0 {
+		fmt.Println()
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "PORT\tADDRESS\tSTATUS")
+		for _, sh := range health.Servers {
+			status := "listening"
+			if !sh.Ready {
+				status = "error: " + sh.Error
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", sh.Port, sh.Addr, status)
+		}
+		tw.Flush()
+	}
+	if readyErr != nil {
+		os.Exit(1)
+	}
+}
+
 func serverList(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
 	if len(args) > 0 {
 		cmd.Usage()
@@ -189,19 +448,42 @@ func serverAdd(client *client.Client, flags *flagSet, cmd *cobra.Command, args [
 		cmd.Usage()
 		return
 	}
-	var (
-		srv *admin.Server
-		err error
-	)
+	errorPages := make(admin.ErrorPages)
+	for _, ep := range flags.ServerErrorPages {
+		i := strings.Index(ep, "=")
+		if i < 0 {
+			log.Fatalf("invalid error page %q: expected STATUS=FILE", ep)
+		}
+		file, err := filepath.Abs(ep[i+1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		errorPages[ep[:i]] = file
+	}
+	accessLog, err := accessLogFromFlags(flags, flags.AccessLogFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var srv *admin.Server
 	if len(args) == 0 {
-		srv, err = client.PostServers(&admin.CreateRandomServerIn{BindAddress: flags.ServerAddBind})
+		srv, err = client.PostServers(&admin.CreateRandomServerIn{
+			BindAddress: flags.ServerAddBind,
+			ErrorPages:  errorPages,
+			RootIndex:   flags.ServerRootIndex,
+			AccessLog:   accessLog,
+		})
 	} else {
 		var port int
 		port, err = strconv.Atoi(args[0])
 		if err != nil {
 			log.Fatalf("error parsing port: %v", err)
 		}
-		srv, err = client.PutServersOne(strconv.Itoa(port), &admin.CreateServerIn{BindAddress: flags.ServerAddBind})
+		srv, err = client.PutServersOne(strconv.Itoa(port), &admin.CreateServerIn{
+			BindAddress: flags.ServerAddBind,
+			ErrorPages:  errorPages,
+			RootIndex:   flags.ServerRootIndex,
+			AccessLog:   accessLog,
+		})
 	}
 	if err != nil {
 		log.Fatal(err)
@@ -210,6 +492,98 @@ func serverAdd(client *client.Client, flags *flagSet, cmd *cobra.Command, args [
 	fmt.Printf("server available on %s\n", addr)
 }
 
+// accessLogFromFlags returns the access log settings of the flags, writing to file.
+func accessLogFromFlags(flags *flagSet, file string) (admin.AccessLog, error) {
+	if file != "" {
+		var err error
+		if file, err = filepath.Abs(file); err != nil {
+			return admin.AccessLog{}, err
+		}
+	}
+	return admin.AccessLog{
+		File:       file,
+		Format:     flags.AccessLogFormat,
+		Rotate:     flags.AccessLogRotate,
+		MaxSizeMb:  flags.AccessLogMaxSize,
+		MaxBackups: flags.AccessLogBackups,
+		MaxAgeDays: flags.AccessLogMaxAge,
+	}, nil
+}
+
+func printAccessLog(settings *admin.AccessLog) {
+	if settings.File == "" {
+		fmt.Println("logged by krakend")
+		return
+	}
+	fmt.Printf("%s, format=%s", settings.File, settings.Format)
+	if settings.Rotate != "" {
+		fmt.Printf(" rotate=%s", settings.Rotate)
+	}
+	if settings.MaxSizeMb > 0 {
+		fmt.Printf(" max-size=%dMB", settings.MaxSizeMb)
+	}
+	if settings.MaxBackups > 0 {
+		fmt.Printf(" max-backups=%d", settings.MaxBackups)
+	}
+	if settings.MaxAgeDays > 0 {
+		fmt.Printf(" max-age=%dd", settings.MaxAgeDays)
+	}
+	fmt.Println()
+}
+
+func accessLogShow(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	port, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("error parsing port: %v", err)
+	}
+	settings, err := client.GetServersOneAccesslog(strconv.Itoa(port))
+	if err != nil {
+		log.Fatal(err)
+	}
+	printAccessLog(settings)
+}
+
+func accessLogSet(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		cmd.Usage()
+		return
+	}
+	port, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("error parsing port: %v", err)
+	}
+	accessLog, err := accessLogFromFlags(flags, args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	updateIn := admin.UpdateAccessLogIn(accessLog)
+	settings, err := client.PutServersOneAccesslog(strconv.Itoa(port), &updateIn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printAccessLog(settings)
+}
+
+func accessLogOff(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	port, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("error parsing port: %v", err)
+	}
+	settings, err := client.DeleteServersOneAccesslog(strconv.Itoa(port))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Stopped writing the access log to %s\n", settings.File)
+}
+
 func serverRm(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
 	if len(args) != 1 {
 		cmd.Usage()
@@ -250,7 +624,207 @@ func fileServerList(client *client.Client, flags *flagSet, cmd *cobra.Command, a
 		log.Fatal(err)
 	}
 
-	fmt.Println(strings.Join(fsrvs, ", "))
+	if !flags.FileServerVerbose {
+		names := make([]string, len(fsrvs))
+		for i, fsrv := range fsrvs {
+			names[i] = fsrv.Name
+		}
+		fmt.Println(strings.Join(names, ", "))
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, fsrv := range fsrvs {
+		fmt.Fprintf(tw, "%s\n", fsrv.Name)
+		for _, spec := range fsrv.Params {
+			name := spec.Name
+			if spec.Prefix {
+				name += "*"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", name, spec.Type, spec.Default, spec.Description)
+		}
+	}
+	tw.Flush()
+}
+
+// checkFsParams checks params are valid for the file server type fsType, as described by the server.
+func checkFsParams(client *client.Client, fsType string, params fileserver.Params) error {
+	fsrvs, err := client.GetFileservers()
+	if err != nil {
+		return err
+	}
+	if fsType == "" {
+		fsType = "default"
+	}
+	for _, fsrv := range fsrvs {
+		if fsrv.Name == fsType {
+			return fileserver.ParamSchema(fsrv.Params).Validate(params)
+		}
+	}
+	return fmt.Errorf("unknown file server type %q", fsType)
+}
+
+func printRewrite(rewrite *admin.Rewrite) {
+	fmt.Printf("%s: %s %s", rewrite.Id, rewrite.Pattern, rewrite.Action)
+	if rewrite.Status != 0 {
+		fmt.Printf(" %d", rewrite.Status)
+	}
+	if rewrite.Target != "" {
+		fmt.Printf(" %s", rewrite.Target)
+	}
+	fmt.Println()
+}
+
+func rewriteList(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	port, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("error parsing port: %v", err)
+	}
+	rewrites, err := client.GetServersOneRewrites(strconv.Itoa(port))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for i := range rewrites {
+		printRewrite(&rewrites[i])
+	}
+}
+
+func rewriteAdd(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) != 3 && len(args) != 4 {
+		cmd.Usage()
+		return
+	}
+	port, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("error parsing port: %v", err)
+	}
+	rewriteIn := &admin.CreateRewriteIn{
+		Pattern:  args[1],
+		Action:   args[2],
+		Status:   flags.RewriteStatus,
+		Position: flags.RewritePosition,
+	}
+	if len(args) == 4 {
+		rewriteIn.Target = args[3]
+	}
+	rewrite, err := client.PostServersOneRewrites(strconv.Itoa(port), rewriteIn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printRewrite(rewrite)
+}
+
+func rewriteRm(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		cmd.Usage()
+		return
+	}
+	port, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("error parsing port: %v", err)
+	}
+	rewrite, err := client.DeleteServersOneRewritesOne(strconv.Itoa(port), args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print("Removed rewrite rule ")
+	printRewrite(rewrite)
+}
+
+func printHook(hook *admin.Hook) {
+	fmt.Printf("%s: %s", hook.Id, hook.Type)
+	if hook.Type == admin.HookTypeWebhook {
+		fmt.Printf(" %s", hook.Url)
+	} else {
+		fmt.Printf(" %q", hook.Command)
+	}
+	if len(hook.Events) > 0 {
+		fmt.Printf(" on %s", strings.Join(hook.Events, ","))
+	}
+	if hook.Port != 0 {
+		fmt.Printf(" port=%d", hook.Port)
+	}
+	if hook.Mount != "" {
+		fmt.Printf(" mount=%s", hook.Mount)
+	}
+	if hook.Path != "" {
+		fmt.Printf(" path=%s", hook.Path)
+	}
+	fmt.Printf(" - %d delivered, %d failed, %d dropped", hook.Delivered, hook.Failed, hook.Dropped)
+	if hook.LastDelivery != "" {
+		fmt.Printf(", last at %s", hook.LastDelivery)
+	}
+	if hook.LastError != "" {
+		fmt.Printf(" (last error: %s)", hook.LastError)
+	}
+	fmt.Println()
+}
+
+func hookList(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		cmd.Usage()
+		return
+	}
+	hooks, err := client.GetHooks()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i := range hooks {
+		printHook(&hooks[i])
+	}
+}
+
+func hookAdd(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		cmd.Usage()
+		return
+	}
+	hookIn := &admin.CreateHookIn{
+		Type:    args[0],
+		Events:  flags.HookEvents,
+		Port:    flags.HookPort,
+		Mount:   flags.HookMount,
+		Path:    flags.HookPath,
+		Secret:  flags.HookSecret,
+		Retries: flags.HookRetries,
+	}
+	switch hookIn.Type {
+	case admin.HookTypeWebhook:
+		if len(args) != 2 {
+			cmd.Usage()
+			return
+		}
+		hookIn.Url = args[1]
+	case admin.HookTypeCommand:
+		hookIn.Command = args[1:]
+	default:
+		log.Fatalf("unknown hook type %q", hookIn.Type)
+	}
+	if flags.HookCloudEvents {
+		hookIn.Envelope = "cloudevents"
+	}
+	hook, err := client.PostHooks(hookIn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printHook(hook)
+}
+
+func hookRm(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	hook, err := client.DeleteHooksOne(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print("Removed hook ")
+	printHook(hook)
 }
 
 func mountList(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
@@ -273,7 +847,7 @@ func mountList(client *client.Client, flags *flagSet, cmd *cobra.Command, args [
 }
 
 func mountAdd(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
-	if len(args) != 2 {
+	if len(args) < 2 {
 		cmd.Usage()
 		return
 	}
@@ -281,26 +855,44 @@ func mountAdd(client *client.Client, flags *flagSet, cmd *cobra.Command, args []
 	if err != nil {
 		log.Fatalf("error parsing port: %v", err)
 	}
-	source, err := filepath.Abs(args[1])
-	if err != nil {
-		log.Fatal(err)
+	sources := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		// URL sources, e.g for the proxy type, are sent as is.
+		if strings.Contains(arg, "://") {
+			sources[i] = arg
+			continue
+		}
+		if sources[i], err = filepath.Abs(arg); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	target := "/" + filepath.Base(source)
+	target := "/" + filepath.Base(sources[0])
 	if flags.MountTarget != "" {
 		target = flags.MountTarget
+	} else if strings.Contains(sources[0], "://") {
+		log.Fatal("a mount target is required for URL sources")
 	}
 	var fsParams fileserver.Params
 	if err := json.Unmarshal([]byte(flags.FileServerParams), &fsParams); err != nil {
 		log.Fatal(err)
 	}
 
-	mount, err := client.PostServersOneMounts(strconv.Itoa(port), &admin.CreateMountIn{
+	mountIn := &admin.CreateMountIn{
 		Target:   target,
-		Source:   source,
 		FsType:   flags.FileServerType,
 		FsParams: admin.FsParams(fsParams),
-	})
+	}
+	if len(sources) == 1 {
+		mountIn.Source = sources[0]
+	} else {
+		mountIn.Sources = sources
+		mountIn.FsType = "union"
+	}
+	if err := checkFsParams(client, mountIn.FsType, fsParams); err != nil {
+		log.Fatal(err)
+	}
+	mount, err := client.PostServersOneMounts(strconv.Itoa(port), mountIn)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -324,34 +916,254 @@ func mountRm(client *client.Client, flags *flagSet, cmd *cobra.Command, args []s
 	}
 }
 
+func statsPrint(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return
+	}
+	port, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("error parsing port: %v", err)
+	}
+	serverPort := strconv.Itoa(port)
+	mountStats := func(mount admin.Mount) *admin.Stats {
+		var stats *admin.Stats
+		if flags.StatsReset {
+			stats, err = client.DeleteServersOneMountsOneStats(serverPort, mount.Id)
+		} else {
+			stats, err = client.GetServersOneMountsOneStats(serverPort, mount.Id)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return stats
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "MOUNT\tTARGET\tREQUESTS\tBYTES\tCLIENTS\tSTATUSES\tSINCE")
+	printRow := func(id string, target string, stats *admin.Stats) {
+		statuses := make([]string, 0, len(stats.Statuses))
+		for status, count := range stats.Statuses {
+			statuses = append(statuses, fmt.Sprintf("%s:%d", status, count))
+		}
+		sort.Strings(statuses)
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\n", id, target, stats.Requests, stats.Bytes, stats.UniqueClients, strings.Join(statuses, " "), stats.Since)
+	}
+
+	var top *admin.Stats
+	if len(args) == 2 {
+		mount, err := client.GetServersOneMountsOne(serverPort, args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		top = mountStats(*mount)
+		printRow(mount.Id, mount.Target, top)
+	} else {
+		mounts, err := client.GetServersOneMounts(serverPort)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if flags.StatsReset {
+			top, err = client.DeleteServersOneStats(serverPort)
+		} else {
+			top, err = client.GetServersOneStats(serverPort)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		printRow("*", "", top)
+		for _, mount := range mounts {
+			printRow(mount.Id, mount.Target, mountStats(mount))
+		}
+	}
+	tw.Flush()
+
+	if len(top.TopFiles) > 0 {
+		fmt.Println()
+		tw = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "DOWNLOADS\tPATH")
+		for _, f := range top.TopFiles {
+			fmt.Fprintf(tw, "%d\t%s\n", f.Count, f.Path)
+		}
+		tw.Flush()
+	}
+	if flags.StatsReset {
+		fmt.Println("\nStats reset")
+	}
+}
+
+// byteCount formats n bytes in a human readable way, e.g 1.5 MiB.
+func byteCount(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := unit, 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func transferList(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		cmd.Usage()
+		return
+	}
+	port := -1
+	if len(args) == 1 {
+		var err error
+		if port, err = strconv.Atoi(args[0]); err != nil {
+			log.Fatalf("error parsing port: %v", err)
+		}
+	}
+	transfers, err := client.GetTransfers()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPORT\tCLIENT\tPATH\tPROGRESS\tRATE\tSTARTED")
+	for _, t := range transfers {
+		if port != -1 && t.Port != port {
+			continue
+		}
+		progress := byteCount(t.Bytes)
+		if t.Size > 0 {
+			progress = fmt.Sprintf("%s / %s (%d%%)", progress, byteCount(t.Size), t.Bytes*100/t.Size)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s/s\t%s\n", t.Id, t.Port, t.RemoteAddr, t.Path, progress, byteCount(t.Rate), t.Started)
+	}
+	tw.Flush()
+}
+
+func transferCancel(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	t, err := client.DeleteTransfersOne(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Canceled transfer %s of %s to %s, after %s\n", t.Id, t.Path, t.RemoteAddr, byteCount(t.Bytes))
+}
+
 func listenEvents(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
 	events := args
 	eventsCh := make(chan *admin.Event)
+	// epoch and lastID are those of the last event received, from which
+	// listening resumes when the connection is lost.
+	var (
+		mu     sync.Mutex
+		epoch  string
+		lastID uint64
+	)
+	resume := flags.EventsSince >= 0
+	if resume {
+		lastID = uint64(flags.EventsSince)
+	}
 	go func() {
-		if err := client.ListenEvents(eventsCh, events...); err != nil {
-			log.Fatal(err)
+		for {
+			mu.Lock()
+			sinceEpoch, since := epoch, lastID
+			mu.Unlock()
+			var err error
+			if resume {
+				err = client.ListenEventsSince(eventsCh, sinceEpoch, since, events...)
+			} else {
+				err = client.ListenEvents(eventsCh, events...)
+			}
+			mu.Lock()
+			received := epoch != ""
+			mu.Unlock()
+			if !resume && !received {
+				if err == nil {
+					err = errors.New("connection closed")
+				}
+				log.Fatal(err)
+			}
+			log.Printf("connection lost (%v), reconnecting", err)
+			resume = true
+			time.Sleep(reconnectDelay)
 		}
 	}()
 	for evt := range eventsCh {
-		switch evt.Type {
-		case admin.EventTypeServerAdd:
-			se := evt.Resource.(*admin.ServerEvent)
-			fmt.Printf("server added on http://%s:%d\n", se.Server.BindAddress, se.Server.Port)
-		case admin.EventTypeServerRemove:
-			se := evt.Resource.(*admin.ServerEvent)
-			fmt.Printf("server removed on http://%s:%d\n", se.Server.BindAddress, se.Server.Port)
-		case admin.EventTypeMountAdd:
-			me := evt.Resource.(*admin.MountEvent)
-			fmt.Printf("mount point %s added: %q -> http://%s:%d%s\n", me.Mount.Id, me.Mount.Source, me.Server.BindAddress, me.Server.Port, me.Mount.Target)
-		case admin.EventTypeMountUpdate:
-			me := evt.Resource.(*admin.MountEvent)
-			fmt.Printf("mount point %s updated: %q -> http://%s:%d%s\n", me.Mount.Id, me.Mount.Source, me.Server.BindAddress, me.Server.Port, me.Mount.Target)
-		case admin.EventTypeMountRemove:
-			me := evt.Resource.(*admin.MountEvent)
-			fmt.Printf("mount point %s removed: %q X http://%s:%d%s\n", me.Mount.Id, me.Mount.Source, me.Server.BindAddress, me.Server.Port, me.Mount.Target)
-		case admin.EventTypeFileServe:
-			fse := evt.Resource.(*admin.FileServeEvent)
-			fmt.Printf("file served on http://%s:%d - %d - %s\n", fse.Server.BindAddress, fse.Server.Port, fse.Code, fse.Path)
+		mu.Lock()
+		switch {
+		case epoch != "" && evt.Epoch != epoch:
+			// krakend restarted without its events: their IDs start again.
+			log.Printf("event IDs restarted at %d", evt.ID)
+		case evt.ID != 0 && evt.ID <= lastID:
+			// Already received before reconnecting.
+			mu.Unlock()
+			continue
 		}
+		epoch, lastID = evt.Epoch, evt.ID
+		mu.Unlock()
+		fmt.Println(eventString(evt))
+	}
+}
+
+func eventsHistory(client *client.Client, flags *flagSet, cmd *cobra.Command, args []string) {
+	if flags.EventsSince < 0 {
+		cmd.Usage()
+		return
+	}
+	evts, err := client.GetEventsHistory(uint64(flags.EventsSince), flags.EventsLimit, args...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, evt := range evts {
+		fmt.Printf("%d %s %s\n", evt.ID, evt.Time.Format(time.RFC3339), eventString(&evt))
+	}
+}
+
+func eventString(evt *admin.Event) string {
+	switch evt.Type {
+	case admin.EventTypeServerAdd:
+		se := evt.Resource.(*admin.ServerEvent)
+		return fmt.Sprintf("server added on http://%s:%d", se.Server.BindAddress, se.Server.Port)
+	case admin.EventTypeServerRemove:
+		se := evt.Resource.(*admin.ServerEvent)
+		return fmt.Sprintf("server removed on http://%s:%d", se.Server.BindAddress, se.Server.Port)
+	case admin.EventTypeMountAdd:
+		me := evt.Resource.(*admin.MountEvent)
+		return fmt.Sprintf("mount point %s added: %q -> http://%s:%d%s", me.Mount.Id, me.Mount.Source, me.Server.BindAddress, me.Server.Port, me.Mount.Target)
+	case admin.EventTypeMountUpdate:
+		me := evt.Resource.(*admin.MountEvent)
+		return fmt.Sprintf("mount point %s updated: %q -> http://%s:%d%s", me.Mount.Id, me.Mount.Source, me.Server.BindAddress, me.Server.Port, me.Mount.Target)
+	case admin.EventTypeMountRemove:
+		me := evt.Resource.(*admin.MountEvent)
+		return fmt.Sprintf("mount point %s removed: %q X http://%s:%d%s", me.Mount.Id, me.Mount.Source, me.Server.BindAddress, me.Server.Port, me.Mount.Target)
+	case admin.EventTypeFileServe:
+		fse := evt.Resource.(*admin.FileServeEvent)
+		return fileServeEventString(fse)
+	}
+	return fmt.Sprintf("unknown event %d", evt.Type)
+}
+
+func fileServeEventString(fse *admin.FileServeEvent) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "file served on http://%s:%d", fse.Server.BindAddress, fse.Server.Port)
+	if fse.Mount != "" {
+		fmt.Fprintf(&buf, " (mount %s)", fse.Mount)
+	}
+	fmt.Fprintf(&buf, " - %d - %s %s - %s", fse.Code, fse.Method, fse.Path, fse.RemoteAddr)
+	if fse.ContentLength >= 0 {
+		fmt.Fprintf(&buf, " - %d/%d bytes", fse.Bytes, fse.ContentLength)
+	} else {
+		fmt.Fprintf(&buf, " - %d bytes", fse.Bytes)
+	}
+	fmt.Fprintf(&buf, " in %s", fse.Duration)
+	if fse.Range {
+		buf.WriteString(" [range]")
+	}
+	if fse.Aborted {
+		buf.WriteString(" [aborted]")
+	}
+	if fse.UserAgent != "" {
+		fmt.Fprintf(&buf, " %q", fse.UserAgent)
 	}
+	return buf.String()
 }
@@ -3,16 +3,28 @@ package main
 import (
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/vincent-petithory/kraken"
 	"github.com/vincent-petithory/kraken/admin"
 	"github.com/vincent-petithory/kraken/fileserver"
+	"github.com/vincent-petithory/kraken/fileserver/archive"
 	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+	"github.com/vincent-petithory/kraken/fileserver/cgi"
+	"github.com/vincent-petithory/kraken/fileserver/git"
+	"github.com/vincent-petithory/kraken/fileserver/proxy"
+	"github.com/vincent-petithory/kraken/fileserver/spa"
+	"github.com/vincent-petithory/kraken/fileserver/union"
 )
 
 const (
@@ -21,6 +33,22 @@ const (
 	envKrakenAddr = "KRAKEN_ADDR"
 	// Environnement var for the base URL of the admin service.
 	envKrakenURL = "KRAKEN_URL"
+	// Environnement var for the directory where krakend keeps its state.
+	envKrakenStateDir = "KRAKEN_STATE_DIR"
+	// Environnement var for the directory of the default theme of directory listings.
+	envKrakenThemeDir = "KRAKEN_THEME_DIR"
+	// Environnement var enabling saving the traffic stats of the servers in the state directory.
+	envKrakenSaveStats = "KRAKEN_SAVE_STATS"
+	// Environnement var enabling saving the recent events in the state directory.
+	envKrakenSaveEvents = "KRAKEN_SAVE_EVENTS"
+	// Environnement var enabling the hooks running commands.
+	envKrakenHookCommands = "KRAKEN_HOOK_COMMANDS"
+	// Environnement var for the minimum level of the logs.
+	envKrakenLogLevel = "KRAKEN_LOG_LEVEL"
+	// Environnement var for the format of the logs.
+	envKrakenLogFormat = "KRAKEN_LOG_FORMAT"
+	// How often the stats and events are saved, when enabled.
+	stateSaveInterval = time.Minute
 	// Default value of KRAKEN_ADDR
 	defaultAddr = "localhost:4214"
 )
@@ -40,23 +68,136 @@ krakend takes no arguments and is configured through the following environment v
 
     %s: Address to bind to and port to listen to; defaults to %s
     %s: URL on which the API is accessible; defaults to http://{KRAKEN_ADDR}
+    %s: Directory where krakend keeps its state (e.g thumbnails); defaults to $XDG_STATE_HOME/kraken
+    %s: Directory of a theme replacing the default look of directory listings;
+        it may contain list.html, gallery.html, style.css and brand.json;
+        the templates are executed with a beachplug.TemplateContext
+    %s: If true, the traffic stats of the servers are saved in the state directory
+        and restored when a server is created again on the same port
+    %s: If true, the recent events are saved in the state directory
+        and can still be replayed after krakend restarts
+    %s: If true, hooks may run commands; anyone who can reach the API can then
+        run any command as krakend, so only set it if the API is not exposed
+    %s: Minimum level of the logs: debug, info (default), warn or error
+    %s: Format of the logs: text (default) or json
 
 See krakenctl for a command-line client of the API.
-`, envKrakenAddr, defaultAddr, envKrakenURL)
+`, envKrakenAddr, defaultAddr, envKrakenURL, envKrakenStateDir, envKrakenThemeDir, envKrakenSaveStats, envKrakenSaveEvents,
+			envKrakenHookCommands, envKrakenLogLevel, envKrakenLogFormat)
 	}
 	flag.Parse()
 }
 
+// stateDir returns the directory where krakend keeps its state.
+// It returns "" if it can't be determined.
+func stateDir() string {
+	if dir := os.Getenv(envKrakenStateDir); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "kraken")
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, ".local", "state", "kraken")
+	}
+	return ""
+}
+
+// newLogger returns the logger of krakend, writing to w at the level and in the format
+// of the environment.
+func newLogger(w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if v := os.Getenv(envKrakenLogLevel); v != "" {
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("%s: %v", envKrakenLogLevel, err)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	switch v := os.Getenv(envKrakenLogFormat); v {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("%s: unknown format %q", envKrakenLogFormat, v)
+	}
+}
+
+// fatal logs err and exits.
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
 func main() {
-	log.SetOutput(os.Stdout)
+	logger, err := newLogger(os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// The logs of the standard logger, e.g of the file servers, go through it too.
+	slog.SetDefault(logger)
 	adminAddr := defaultAddr
 	// Register fileservers
 	fsf := make(fileserver.Factory)
-	if err := fsf.Register("beachplug", beachplug.Server); err != nil {
-		log.Fatal(err)
+	beachplugOpts := beachplug.Options{
+		StateDir: stateDir(),
+		ThemeDir: os.Getenv(envKrakenThemeDir),
+	}
+	beachplugParams := beachplug.NewParamsChecker(beachplugOpts)
+	if err := fsf.RegisterType("beachplug", fileserver.Type{
+		New:         beachplug.NewServer(beachplugOpts),
+		CheckParams: beachplugParams,
+		Params:      beachplug.Params,
+	}); err != nil {
+		fatal(err)
+	}
+	if err := fsf.RegisterType("spa", fileserver.Type{
+		New:    spa.Server,
+		Params: spa.Params,
+	}); err != nil {
+		fatal(err)
+	}
+	if err := fsf.RegisterType("cgi", fileserver.Type{
+		New:    cgi.Server,
+		Params: cgi.Params,
+	}); err != nil {
+		fatal(err)
+	}
+	if err := fsf.RegisterType("archive", fileserver.Type{
+		New:         archive.NewServer(beachplug.NewFileSystemServer(beachplugOpts)),
+		CheckSource: archive.CheckSource,
+		CheckParams: beachplugParams,
+		Params:      beachplug.Params,
+	}); err != nil {
+		fatal(err)
+	}
+	if err := fsf.RegisterType("git", fileserver.Type{
+		New:         git.NewServer(beachplug.NewFileSystemServer(beachplugOpts)),
+		CheckSource: git.CheckSource,
+		CheckParams: beachplugParams,
+		Params:      append(git.Params, beachplug.Params...),
+	}); err != nil {
+		fatal(err)
+	}
+	if err := fsf.RegisterType("proxy", fileserver.Type{
+		New:         proxy.Server,
+		CheckSource: proxy.CheckSource,
+		Params:      proxy.Params,
+	}); err != nil {
+		fatal(err)
+	}
+	if err := fsf.RegisterType("union", fileserver.Type{
+		New:         union.NewServer(beachplug.NewFileSystemServer(beachplugOpts)),
+		CheckSource: union.CheckSource,
+		CheckParams: beachplugParams,
+		Params:      append(union.Params, beachplug.Params...),
+	}); err != nil {
+		fatal(err)
 	}
 	// Init server pool, run existing servers and listen for new ones
 	serverPool := kraken.NewServerPool(fsf)
+	serverPool.Logger = logger
 	go serverPool.Listen()
 
 	if envAdminAddr := os.Getenv(envKrakenAddr); envAdminAddr != "" {
@@ -64,7 +205,7 @@ func main() {
 	}
 	ln, err := net.Listen("tcp", adminAddr)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
 	var (
@@ -76,16 +217,81 @@ func main() {
 		adminURL, urlErr = url.Parse(envAdminURL)
 	}
 	if urlErr != nil {
-		log.Fatal(urlErr)
+		fatal(urlErr)
 	}
 
 	// Start administration server
 	sph := admin.NewServerPoolHandler(serverPool, adminURL)
+	sph.HookCommands, _ = strconv.ParseBool(os.Getenv(envKrakenHookCommands))
+	var states []state
+	for _, st := range []struct {
+		env  string
+		s    state
+		name string
+	}{
+		{envKrakenSaveStats, state{"stats", sph.LoadStats, sph.SaveStats, ""}, "stats.json"},
+		{envKrakenSaveEvents, state{"events", sph.LoadEvents, sph.SaveEvents, ""}, "events.json"},
+	} {
+		if save, _ := strconv.ParseBool(os.Getenv(st.env)); !save {
+			continue
+		}
+		dir := stateDir()
+		if dir == "" {
+			slog.Warn("no state directory to save the state in", "env", st.env)
+			continue
+		}
+		st.s.file = filepath.Join(dir, st.name)
+		states = append(states, st.s)
+	}
+	if len(states) > 0 {
+		saveStates(states)
+	}
 
 	srv := &http.Server{
-		Handler: sph,
+		Handler:  sph,
+		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+	slog.Info("listening", "addr", ln.Addr().String(), "url", sph.BaseURL().String())
+	fatal(srv.Serve(ln))
+}
+
+// state is a part of the state of krakend, saved in file.
+type state struct {
+	name string
+	load func(file string) error
+	save func(file string) error
+	file string
+}
+
+// saveStates restores the states saved in their file, and saves them periodically
+// and when krakend is interrupted.
+func saveStates(states []state) {
+	for _, st := range states {
+		if err := st.load(st.file); err != nil {
+			slog.Error("unable to load state", "state", st.name, "file", st.file, "err", err)
+		}
+	}
+	saveAll := func() {
+		for _, st := range states {
+			if err := st.save(st.file); err != nil {
+				slog.Error("unable to save state", "state", st.name, "file", st.file, "err", err)
+			}
+		}
 	}
-	log.Printf("Listening on %s", ln.Addr())
-	log.Printf("Available on %s", sph.BaseURL())
-	log.Fatal(srv.Serve(ln))
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		ticker := time.NewTicker(stateSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				saveAll()
+			case sig := <-sigCh:
+				saveAll()
+				slog.Info("exiting", "signal", sig.String())
+				os.Exit(0)
+			}
+		}
+	}()
 }
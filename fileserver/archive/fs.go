@@ -0,0 +1,192 @@
+package archive
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fileSystem is a http.FileSystem serving the entries of an archive.
+//
+// Its index is kept in memory, and rebuilt when the archive changes.
+type fileSystem struct {
+	path    string
+	mu      sync.Mutex
+	idx     *index
+	modTime time.Time
+	size    int64
+}
+
+// index returns the index of the archive, rebuilding it if the archive changed.
+func (fs *fileSystem) index() (*index, error) {
+	fi, err := os.Stat(fs.path)
+	if err != nil {
+		return nil, err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.idx != nil && fi.ModTime().Equal(fs.modTime) && fi.Size() == fs.size {
+		return fs.idx, nil
+	}
+	idx, err := buildIndex(fs.path)
+	if err != nil {
+		return nil, err
+	}
+	fs.idx, fs.modTime, fs.size = idx, fi.ModTime(), fi.Size()
+	return idx, nil
+}
+
+func (fs *fileSystem) Open(name string) (http.File, error) {
+	idx, err := fs.index()
+	if err != nil {
+		return nil, err
+	}
+	p := path.Clean("/" + name)
+	e, ok := idx.entries[p]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return &file{idx: idx, path: p, e: e}, nil
+}
+
+// Lstat returns the info of the entry at name.
+// Archives are indexed without their symbolic links, so it is never a link.
+func (fs *fileSystem) Lstat(name string) (os.FileInfo, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// headSize is the size of the head of the compressed entries kept by a file:
+// the bytes http.ServeContent reads to sniff their content type.
+const headSize = 512
+
+// file is a http.File reading an archive entry.
+//
+// Reads are sequential: seeking backward, or forward in a compressed entry,
+// reopens the entry and skips the data up to the new offset.
+// The head of a compressed entry is kept once read, so that seeking back to read it again
+// goes on with the same decompression.
+type file struct {
+	idx  *index
+	path string
+	e    *entry
+
+	pos  int64
+	r    io.ReadCloser
+	rpos int64
+	head []byte
+
+	dirPos int
+}
+
+func (f *file) Read(b []byte) (int, error) {
+	if f.e.IsDir() {
+		return 0, &os.PathError{Op: "read", Path: f.path, Err: os.ErrInvalid}
+	}
+	if f.pos >= f.e.size {
+		return 0, io.EOF
+	}
+	if f.pos < int64(len(f.head)) {
+		n := copy(b, f.head[f.pos:])
+		f.pos += int64(n)
+		return n, nil
+	}
+	if f.r != nil && f.rpos != f.pos {
+		f.r.Close()
+		f.r = nil
+	}
+	if f.r == nil {
+		r, err := f.idx.openAt(f.e, f.pos)
+		if err != nil {
+			return 0, err
+		}
+		f.r, f.rpos = r, f.pos
+	}
+	n, err := f.r.Read(b)
+	if f.idx.compressed(f.e) && f.rpos == int64(len(f.head)) && f.rpos < headSize {
+		f.head = append(f.head, b[:min(n, headSize-len(f.head))]...)
+	}
+	f.pos += int64(n)
+	f.rpos += int64(n)
+	return n, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = f.pos + offset
+	case io.SeekEnd:
+		pos = f.e.size + offset
+	default:
+		return 0, os.ErrInvalid
+	}
+	if pos < 0 {
+		return 0, os.ErrInvalid
+	}
+	f.pos = pos
+	return pos, nil
+}
+
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.e.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: f.path, Err: os.ErrInvalid}
+	}
+	names := f.e.children[f.dirPos:]
+	if count > 0 {
+		if len(names) == 0 {
+			return nil, io.EOF
+		}
+		if len(names) > count {
+			names = names[:count]
+		}
+	}
+	fis := make([]os.FileInfo, 0, len(names))
+	for _, name := range names {
+		if e, ok := f.idx.entries[path.Join(f.path, name)]; ok {
+			fis = append(fis, entryInfo{e})
+		}
+	}
+	f.dirPos += len(names)
+	sort.Sort(byName(fis))
+	return fis, nil
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	return entryInfo{f.e}, nil
+}
+
+func (f *file) Close() error {
+	if f.r != nil {
+		return f.r.Close()
+	}
+	return nil
+}
+
+// entryInfo implements os.FileInfo for an archive entry.
+type entryInfo struct {
+	e *entry
+}
+
+func (fi entryInfo) Name() string       { return fi.e.name }
+func (fi entryInfo) Size() int64        { return fi.e.size }
+func (fi entryInfo) Mode() os.FileMode  { return fi.e.mode }
+func (fi entryInfo) ModTime() time.Time { return fi.e.modTime }
+func (fi entryInfo) IsDir() bool        { return fi.e.IsDir() }
+func (fi entryInfo) Sys() interface{}   { return nil }
+
+type byName []os.FileInfo
+
+func (l byName) Less(i int, j int) bool { return l[i].Name() < l[j].Name() }
+func (l byName) Swap(i int, j int)      { l[i], l[j] = l[j], l[i] }
+func (l byName) Len() int               { return len(l) }
@@ -0,0 +1,288 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// format is an archive format.
+type format int
+
+const (
+	formatUnknown format = iota
+	formatZip
+	formatTar
+	formatTarGz
+)
+
+// formatOf returns the format of the archive at name, based on its extension.
+func formatOf(name string) format {
+	lname := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lname, ".zip"):
+		return formatZip
+	case strings.HasSuffix(lname, ".tar"):
+		return formatTar
+	case strings.HasSuffix(lname, ".tar.gz"), strings.HasSuffix(lname, ".tgz"):
+		return formatTarGz
+	}
+	return formatUnknown
+}
+
+// entry describes a file or a directory of an archive.
+type entry struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+
+	// Location of the data of a file.
+	// For zip and plain tar archives, offset is the offset of the data in the archive file,
+	// and csize its compressed size. For tar.gz archives, offset is the offset in the
+	// uncompressed tar stream.
+	offset int64
+	csize  int64
+	method uint16
+
+	children []string
+}
+
+func (e *entry) IsDir() bool {
+	return e.mode.IsDir()
+}
+
+// index holds the entries of an archive, by clean absolute path.
+type index struct {
+	path    string
+	format  format
+	entries map[string]*entry
+}
+
+// buildIndex reads the archive at name and indexes its entries.
+func buildIndex(name string) (*index, error) {
+	idx := &index{
+		path:    name,
+		format:  formatOf(name),
+		entries: map[string]*entry{"/": {name: "/", mode: os.ModeDir | 0555}},
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch idx.format {
+	case formatZip:
+		err = idx.readZip(f)
+	case formatTar:
+		cr := &countingReader{r: f, s: f}
+		err = idx.readTar(countingSeeker{cr}, cr)
+	case formatTarGz:
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		cr := &countingReader{r: gz}
+		err = idx.readTar(cr, cr)
+	default:
+		err = fmt.Errorf("%s: unsupported archive format", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *index) readZip(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		zfi := zf.FileInfo()
+		e := &entry{
+			size:    zfi.Size(),
+			mode:    zfi.Mode(),
+			modTime: zfi.ModTime(),
+		}
+		if !e.IsDir() {
+			if !e.mode.IsRegular() {
+				continue
+			}
+			offset, err := zf.DataOffset()
+			if err != nil {
+				return err
+			}
+			e.offset = offset
+			e.csize = int64(zf.CompressedSize64)
+			e.method = zf.Method
+		}
+		idx.add(zf.Name, e)
+	}
+	return nil
+}
+
+// readTar indexes the tar archive read from r.
+// cr must count the bytes read from r, to locate the data of the entries.
+func (idx *index) readTar(r io.Reader, cr *countingReader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fi := hdr.FileInfo()
+		e := &entry{
+			size:    fi.Size(),
+			mode:    fi.Mode(),
+			modTime: fi.ModTime(),
+		}
+		// Links and special files are not served.
+		if !e.IsDir() && !e.mode.IsRegular() {
+			continue
+		}
+		e.offset = cr.n
+		e.csize = e.size
+		e.method = zip.Store
+		idx.add(hdr.Name, e)
+	}
+}
+
+// add adds e at name, and creates the missing parent directories.
+func (idx *index) add(name string, e *entry) {
+	p := path.Clean("/" + name)
+	if p == "/" {
+		return
+	}
+	e.name = path.Base(p)
+	if old, ok := idx.entries[p]; ok {
+		// Implicit directories may be defined after their children;
+		// later entries win, but children are kept.
+		e.children = old.children
+	} else {
+		idx.link(p)
+	}
+	idx.entries[p] = e
+}
+
+// link registers p as a child of its parent directory, creating it if needed.
+func (idx *index) link(p string) {
+	dir := path.Dir(p)
+	parent, ok := idx.entries[dir]
+	if !ok {
+		parent = &entry{name: path.Base(dir), mode: os.ModeDir | 0555}
+		idx.entries[dir] = parent
+		idx.link(dir)
+	}
+	parent.children = append(parent.children, path.Base(p))
+}
+
+// compressed reports whether the data of e is decompressed from its start,
+// or from the start of the archive for tar.gz archives, to be read at an offset.
+func (idx *index) compressed(e *entry) bool {
+	return idx.format == formatTarGz || e.method != zip.Store
+}
+
+// openAt returns a reader of the data of e, starting at off.
+// For tar.gz archives, it decompresses the archive up to e.offset+off.
+func (idx *index) openAt(e *entry, off int64) (io.ReadCloser, error) {
+	f, err := os.Open(idx.path)
+	if err != nil {
+		return nil, err
+	}
+	remaining := e.size - off
+	if remaining < 0 {
+		remaining = 0
+	}
+	switch {
+	case idx.format == formatTarGz:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if _, err := io.CopyN(ioutil.Discard, gz, e.offset+off); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return readCloser{io.LimitReader(gz, remaining), f}, nil
+	case e.method == zip.Store:
+		return readCloser{io.NewSectionReader(f, e.offset+off, remaining), f}, nil
+	case e.method == zip.Deflate:
+		fr := flate.NewReader(io.NewSectionReader(f, e.offset, e.csize))
+		if _, err := io.CopyN(ioutil.Discard, fr, off); err != nil {
+			fr.Close()
+			f.Close()
+			return nil, err
+		}
+		return readCloser{io.LimitReader(fr, remaining), multiCloser{fr, f}}, nil
+	default:
+		f.Close()
+		return nil, errUnsupportedMethod
+	}
+}
+
+var errUnsupportedMethod = errors.New("archive: unsupported compression method")
+
+// countingReader counts the bytes read from r.
+// If s is not nil, it is used to skip data without reading it.
+type countingReader struct {
+	r io.Reader
+	s io.Seeker
+	n int64
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n += int64(n)
+	return n, err
+}
+
+// countingSeeker is a countingReader which can seek,
+// allowing archive/tar to skip the data of entries.
+type countingSeeker struct {
+	*countingReader
+}
+
+func (cs countingSeeker) Seek(offset int64, whence int) (int64, error) {
+	n, err := cs.s.Seek(offset, whence)
+	if err == nil {
+		cs.n = n
+	}
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type multiCloser []io.Closer
+
+func (mc multiCloser) Close() error {
+	var err error
+	for _, c := range mc {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
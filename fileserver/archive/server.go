@@ -0,0 +1,34 @@
+package archive
+
+import (
+	"fmt"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+)
+
+// NewServer returns the constructor of archive servers, which serve the entries of
+// a .zip, .tar, .tar.gz or .tgz archive as a directory tree, listed with fsc.
+//
+// Range requests are cheap on entries stored without compression; on compressed ones,
+// the entry is decompressed up to the start of the range.
+// The entries of .tar.gz and .tgz archives can't be located in the compressed data:
+// each request decompresses the archive from its start, so it costs as much as reading
+// the archive up to the entry served.
+// The params are the ones of fsc.
+func NewServer(fsc beachplug.FileSystemConstructor) fileserver.Constructor {
+	return func(root string, params fileserver.Params) (fileserver.Server, error) {
+		return fsc(root, &fileSystem{path: root}, params)
+	}
+}
+
+// CheckSource checks source is the absolute path of an archive file of a supported format.
+func CheckSource(source string) error {
+	if err := fileserver.CheckFile(source); err != nil {
+		return err
+	}
+	if formatOf(source) == formatUnknown {
+		return fmt.Errorf("%s: unsupported archive format", source)
+	}
+	return nil
+}
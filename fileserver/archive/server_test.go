@@ -0,0 +1,167 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken/fileserver/archive"
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+)
+
+var archiveFiles = []struct {
+	Name, Body string
+}{
+	{"README.txt", "This archive contains kraken docs."},
+	{"docs/guide/intro.txt", strings.Repeat("kraken ", 1000)},
+}
+
+func writeZip(t *testing.T, w io.Writer) {
+	zw := zip.NewWriter(w)
+	for i, f := range archiveFiles {
+		method := zip.Deflate
+		if i%2 == 0 {
+			method = zip.Store
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(fw, f.Body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTar(t *testing.T, w io.Writer) {
+	tw := tar.NewWriter(w)
+	for _, f := range archiveFiles {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    f.Name,
+			Mode:    0644,
+			Size:    int64(len(f.Body)),
+			ModTime: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, f.Body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTarGz(t *testing.T, w io.Writer) {
+	gw := gzip.NewWriter(w)
+	writeTar(t, gw)
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writers := map[string]func(*testing.T, io.Writer){
+		"bundle.zip":    writeZip,
+		"bundle.tar":    writeTar,
+		"bundle.tar.gz": writeTarGz,
+	}
+	newServer := archive.NewServer(beachplug.NewFileSystemServer(beachplug.Options{}))
+	servers := make(map[string]http.Handler)
+	for name, write := range writers {
+		p := filepath.Join(dir, name)
+		f, err := os.Create(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(t, f)
+		f.Close()
+		if err := archive.CheckSource(p); err != nil {
+			t.Fatal(err)
+		}
+
+		fs, err := newServer(p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		servers[name] = fs
+		get := func(urlPath string, rangeHeader string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest("GET", urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rangeHeader != "" {
+				r.Header.Set("Range", rangeHeader)
+			}
+			fs.ServeHTTP(w, r)
+			return w
+		}
+
+		for _, af := range archiveFiles {
+			w := get("/"+af.Name, "")
+			if w.Code != http.StatusOK || w.Body.String() != af.Body {
+				t.Errorf("%s: %s: unexpected response %d %.20q", name, af.Name, w.Code, w.Body.String())
+			}
+			w = get("/"+af.Name, "bytes=5-11")
+			if w.Code != http.StatusPartialContent || w.Body.String() != af.Body[5:12] {
+				t.Errorf("%s: %s: unexpected range response %d %q", name, af.Name, w.Code, w.Body.String())
+			}
+		}
+		w := get("/docs/", "")
+		if !strings.Contains(w.Body.String(), "guide/") {
+			t.Errorf("%s: expected implicit directory guide/ in listing", name)
+		}
+		if w := get("/missing", ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status %d, got %d", name, http.StatusNotFound, w.Code)
+		}
+	}
+
+	// The index is rebuilt when the archive changes.
+	p := filepath.Join(dir, "bundle.zip")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	fw, err := zw.Create("new.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, "new")
+	zw.Close()
+	f.Close()
+	mtime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(p, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/new.txt", nil)
+	servers["bundle.zip"].ServeHTTP(w, r)
+	if w.Body.String() != "new" {
+		t.Errorf("expected rebuilt index to serve new.txt, got %d %q", w.Code, w.Body.String())
+	}
+
+	if err := archive.CheckSource(dir); err == nil {
+		t.Error("expected a directory to be rejected as archive source")
+	}
+}
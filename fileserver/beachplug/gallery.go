@@ -0,0 +1,196 @@
+package beachplug
+
+import (
+	"html/template"
+)
+
+var galleryTpl = template.Must(newTemplate("", galleryTplstr))
+
+var galleryTplstr = `<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>Gallery of {{.Root}}</title>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <style type="text/css">{{.Style}}</style>
+  </head>
+<body>
+  <div class="header">
+  {{ template "brand" .Brand }}
+  <h3>{{.Root}}</h3>
+  <form class="search" method="get"><input type="search" name="q" placeholder="Search {{.Root}}"></form>
+  </div>
+  <div class="contents">
+    <p class="views"><a href="?view=list">List view</a></p>
+    <table>
+    {{range sorted .Directories}}
+      <tr>
+        <td colspan="3"><a href="{{ urlpath . }}/{{ $.Query }}">{{ . }}/</a></td>
+      </tr>
+    {{end}}
+    </table>
+    {{ if and .NumDirectories .NumImages }}
+    <hr/>
+    {{ end }}
+
+    <div class="gallery">
+    {{range sorted .Images}}
+      <a class="thumb" href="{{ urlpath .Name }}" title="{{ .Name }}">
+        <img src="{{ urlpath .Name }}?thumb" alt="{{ .Name }}" loading="lazy">
+        <span>{{ ellipsis .Name 30 }}</span>
+      </a>
+    {{end}}
+    </div>
+
+    {{ if .NumFiles }}
+    <hr/>
+    <table>
+    <tr>
+      <th>File</th>
+      <th>Size</th>
+      <th>Mod time</th>
+    </tr>
+    {{range sorted .Files}}
+      <tr>
+        <td><a href="{{ urlpath .Name }}{{ if and $.Markdown (ismarkdown .Name) }}?render=1{{ end }}">{{ ellipsis .Name 50 }}</a></td>
+        <td>{{ humanbytes .Size }}</td>
+        <td>{{ fmttime .ModTime }}</td>
+      </tr>
+    {{end}}
+    </table>
+    {{end}}
+    {{ if .Readme }}
+    <hr/>
+    <div class="markdown">{{ .Readme }}</div>
+    {{ end }}
+  </div>
+  <div id="lightbox" class="lightbox" hidden>
+    <a class="lb-close" href="#" title="Close">&times;</a>
+    <a class="lb-prev" href="#" title="Previous">&lsaquo;</a>
+    <figure>
+      <img id="lb-img" alt="">
+      <figcaption id="lb-caption"></figcaption>
+    </figure>
+    <a class="lb-next" href="#" title="Next">&rsaquo;</a>
+  </div>
+  <script>
+  (function() {
+    var items = document.querySelectorAll(".gallery a.thumb");
+    var lb = document.getElementById("lightbox");
+    var img = document.getElementById("lb-img");
+    var caption = document.getElementById("lb-caption");
+    var cur = -1;
+
+    function show(i) {
+      if (items.length === 0) {
+        return;
+      }
+      cur = (i + items.length) % items.length;
+      img.src = items[cur].getAttribute("href");
+      caption.textContent = items[cur].getAttribute("title");
+      lb.hidden = false;
+    }
+    function hide() {
+      lb.hidden = true;
+      img.removeAttribute("src");
+      cur = -1;
+    }
+    function on(el, f) {
+      el.addEventListener("click", function(e) {
+        e.preventDefault();
+        f();
+      });
+    }
+
+    Array.prototype.forEach.call(items, function(a, i) {
+      on(a, function() { show(i); });
+    });
+    on(lb.querySelector(".lb-prev"), function() { show(cur - 1); });
+    on(lb.querySelector(".lb-next"), function() { show(cur + 1); });
+    on(lb.querySelector(".lb-close"), hide);
+    lb.addEventListener("click", function(e) {
+      if (e.target === lb) {
+        hide();
+      }
+    });
+    document.addEventListener("keydown", function(e) {
+      if (lb.hidden) {
+        return;
+      }
+      switch (e.key) {
+      case "ArrowLeft":
+        show(cur - 1);
+        break;
+      case "ArrowRight":
+        show(cur + 1);
+        break;
+      case "Escape":
+        hide();
+        break;
+      }
+    });
+  })();
+  </script>
+</body>
+</html>
+`
+
+const galleryCSS = template.CSS(`
+.gallery {
+  display: flex;
+  flex-wrap: wrap;
+  gap: 10px;
+}
+
+.gallery a.thumb {
+  display: flex;
+  flex-direction: column;
+  align-items: center;
+  justify-content: flex-end;
+  width: 160px;
+  height: 180px;
+  padding: 5px;
+}
+.gallery a.thumb img {
+  max-width: 150px;
+  max-height: 150px;
+  margin-bottom: 5px;
+}
+
+.lightbox {
+  position: fixed;
+  top: 0;
+  left: 0;
+  width: 100%;
+  height: 100%;
+  display: flex;
+  align-items: center;
+  justify-content: center;
+  background: rgba(0, 0, 0, 0.85);
+}
+.lightbox[hidden] {
+  display: none;
+}
+.lightbox figure {
+  text-align: center;
+  color: rgb(255, 233, 198);
+}
+.lightbox img {
+  max-width: 85vw;
+  max-height: 85vh;
+}
+.lightbox a, .lightbox a:visited {
+  color: rgb(255, 233, 198);
+  font-size: 3em;
+  padding: 0 0.5em;
+  text-decoration: none;
+}
+.lightbox a:hover {
+  color: rgb(199, 65, 79);
+}
+.lightbox .lb-close {
+  position: absolute;
+  top: 0;
+  right: 0;
+}
+`)
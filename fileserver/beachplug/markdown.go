@@ -0,0 +1,141 @@
+package beachplug
+
+import (
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday"
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+// renderQueryKey is the query parameter requesting a markdown file to be rendered as HTML.
+const renderQueryKey = "render"
+
+// maxMarkdownSize is the maximum number of bytes of a markdown file that are rendered.
+const maxMarkdownSize = 1 << 20
+
+// mdPolicy sanitizes the HTML generated from markdown files,
+// which may contain arbitrary HTML.
+var mdPolicy = bluemonday.UGCPolicy()
+
+// isMarkdown reports whether name has the extension of a markdown file.
+func isMarkdown(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// isReadme reports whether name is a README file rendered below a listing.
+func isReadme(name string) bool {
+	return strings.EqualFold(name, "README.md")
+}
+
+// renderMarkdown reads markdown from r and returns its sanitized HTML rendering.
+func renderMarkdown(r io.Reader) (template.HTML, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxMarkdownSize))
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(mdPolicy.SanitizeBytes(blackfriday.MarkdownCommon(b))), nil
+}
+
+// renderFile renders the markdown file at name in s.fs.
+func (s server) renderFile(name string) (template.HTML, error) {
+	f, err := s.fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return renderMarkdown(f)
+}
+
+type markdownCtx struct {
+	Root    string
+	Name    string
+	Style   template.CSS
+	Brand   Brand
+	Content template.HTML
+}
+
+// serveMarkdown replies to the request with the markdown file f rendered as a HTML page.
+func (s server) serveMarkdown(w http.ResponseWriter, r *http.Request, f io.Reader, fi os.FileInfo) {
+	content, err := renderMarkdown(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx := markdownCtx{
+		Root:    r.URL.Path,
+		Name:    fi.Name(),
+		Style:   markdownCSS + s.theme.css,
+		Brand:   s.theme.brand,
+		Content: content,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if err := markdownTpl.Execute(w, ctx); err != nil {
+		fileserver.Logger(r.Context()).Error("unable to render markdown file", "path", r.URL.Path, "err", err)
+	}
+}
+
+var markdownTpl = template.Must(newTemplate("", markdownTplstr))
+
+var markdownTplstr = `<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>{{.Root}}</title>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <style type="text/css">{{.Style}}</style>
+  </head>
+<body>
+  <div class="header">
+  {{ template "brand" .Brand }}
+  <h3>{{.Root}}</h3>
+  </div>
+  <div class="contents">
+    <p class="views"><a href="./">Back to listing</a> | <a href="{{ urlpath .Name }}">Raw</a></p>
+    <div class="markdown">{{ .Content }}</div>
+  </div>
+</body>
+</html>
+`
+
+const markdownCSS = template.CSS(`
+.markdown {
+  font-size: 1.3em;
+  line-height: 1.5;
+}
+.markdown h1, .markdown h2, .markdown h3, .markdown h4 {
+  margin: 1em 0 0.5em;
+}
+.markdown p, .markdown ul, .markdown ol, .markdown pre, .markdown blockquote, .markdown table {
+  margin-bottom: 1em;
+}
+.markdown ul, .markdown ol {
+  padding-left: 2em;
+}
+.markdown pre, .markdown code {
+  font-family: monospace;
+  background: rgba(0, 0, 0, 0.05);
+}
+.markdown pre {
+  padding: 0.5em;
+  overflow: auto;
+}
+.markdown blockquote {
+  border-left: 3px solid rgb(199, 65, 79);
+  padding-left: 1em;
+}
+.markdown img {
+  max-width: 100%;
+}
+`)
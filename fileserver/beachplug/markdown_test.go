@@ -0,0 +1,121 @@
+package beachplug_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+)
+
+func TestMarkdown(t *testing.T) {
+	root, err := ioutil.TempDir("", "beachplug-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"README.md":         "# Welcome\n\nSee the *docs*.\n",
+		"docs/guide.md":     "## Guide\n\n<script>alert(1)</script>\n\n<a href=\"https://example.com/\" onclick=\"steal()\">link</a>\n",
+		"docs/notes.txt":    "# not markdown\n",
+		"docs/CHANGELOG.md": "- fixed\n",
+	}
+	for name, body := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Params      fileserver.Params
+		URLPath     string
+		ContentType string
+		Contains    []string
+		NotContains []string
+	}{
+		{
+			// The README is rendered below the listing.
+			URLPath:     "/",
+			ContentType: "text/html; charset=utf-8",
+			Contains:    []string{`<div class="markdown">`, "<h1>Welcome</h1>", "<em>docs</em>"},
+		},
+		{
+			// Markdown files are linked to their rendered view.
+			URLPath:     "/docs/",
+			ContentType: "text/html; charset=utf-8",
+			Contains:    []string{`href="guide.md?render=1"`, `href="CHANGELOG.md?render=1"`, `href="notes.txt"`},
+			NotContains: []string{`<div class="markdown">`},
+		},
+		{
+			URLPath:     "/docs/guide.md?render=1",
+			ContentType: "text/html; charset=utf-8",
+			Contains:    []string{"<h2>Guide</h2>", `href="https://example.com/"`, ">link</a>", `href="guide.md"`},
+			NotContains: []string{"<script>", "alert(1)", "onclick", "steal()"},
+		},
+		{
+			// Without ?render=1, markdown files are served raw.
+			URLPath:     "/docs/guide.md",
+			ContentType: "text/markdown; charset=utf-8",
+			Contains:    []string{"## Guide", "<script>alert(1)</script>"},
+		},
+		{
+			// Only markdown files are rendered.
+			URLPath:     "/docs/notes.txt?render=1",
+			ContentType: "text/plain; charset=utf-8",
+			Contains:    []string{"# not markdown"},
+		},
+		{
+			Params:      fileserver.Params{"markdown": "false"},
+			URLPath:     "/",
+			ContentType: "text/html; charset=utf-8",
+			Contains:    []string{`href="README.md"`},
+			NotContains: []string{"<h1>Welcome</h1>", "?render=1"},
+		},
+		{
+			Params:      fileserver.Params{"markdown": "false"},
+			URLPath:     "/docs/guide.md?render=1",
+			ContentType: "text/markdown; charset=utf-8",
+			Contains:    []string{"## Guide", "<script>alert(1)</script>"},
+		},
+	}
+	for i, test := range tests {
+		fs, err := beachplug.Server(root, test.Params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", test.URLPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%d: expected status %d, got %d", i, http.StatusOK, w.Code)
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != test.ContentType {
+			t.Errorf("%d: expected content type %q, got %q", i, test.ContentType, ct)
+		}
+		body := w.Body.String()
+		for _, s := range test.Contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("%d: expected body to contain %q, got %q", i, s, body)
+			}
+		}
+		for _, s := range test.NotContains {
+			if strings.Contains(body, s) {
+				t.Errorf("%d: expected body not to contain %q", i, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package beachplug
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+const (
+	// searchQueryKey is the query parameter holding the pattern of a search.
+	searchQueryKey = "q"
+	// searchModeQueryKey is the query parameter selecting how the pattern is matched:
+	// either "glob" or "substring". If empty, the mode is guessed from the pattern.
+	searchModeQueryKey = "mode"
+	// formatQueryKey is the query parameter selecting the format of the search results:
+	// either "html" or "json". If empty, it is negotiated with the Accept header.
+	formatQueryKey = "format"
+
+	searchModeGlob      = "glob"
+	searchModeSubstring = "substring"
+
+	defaultSearchMaxResults = 1000
+	defaultSearchTimeout    = 5 * time.Second
+)
+
+type searchResult struct {
+	Path    string    `json:"path"`
+	Name    string    `json:"name"`
+	IsDir   bool      `json:"is_dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// searchStatus describes why a search ended.
+type searchStatus struct {
+	Truncated bool `json:"truncated"`
+	TimedOut  bool `json:"timed_out"`
+}
+
+// newMatcher returns a func reporting whether a file name matches pattern, using mode.
+// Matching is case insensitive.
+func newMatcher(pattern string, mode string) (func(name string) bool, error) {
+	pattern = strings.ToLower(pattern)
+	if mode == "" {
+		mode = searchModeSubstring
+		if strings.ContainsAny(pattern, "*?[") {
+			mode = searchModeGlob
+		}
+	}
+	switch mode {
+	case searchModeGlob:
+		// Check the pattern is well formed
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %v", pattern, err)
+		}
+		return func(name string) bool {
+			ok, _ := path.Match(pattern, strings.ToLower(name))
+			return ok
+		}, nil
+	case searchModeSubstring:
+		return func(name string) bool {
+			return strings.Contains(strings.ToLower(name), pattern)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown search mode %q", mode)
+	}
+}
+
+// search walks the tree under dir breadth first and sends the files whose name match
+// to results, which is closed when the walk ends.
+//
+// The walk stops when max results are found, or when the deadline or done is reached.
+// Directories already visited through symbolic links are not walked again.
+func (s server) search(logger *slog.Logger, dir string, match func(string) bool, max int, deadline time.Time, done <-chan struct{}, results chan<- searchResult, status *searchStatus) {
+	defer close(results)
+	type queued struct {
+		dir       string
+		ancestors []os.FileInfo
+	}
+	queue := []queued{{dir: dir}}
+	n := 0
+	for len(queue) > 0 {
+		q := queue[0]
+		queue = queue[1:]
+
+		f, err := s.fs.Open(q.dir)
+		if err != nil {
+			continue
+		}
+		dfi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			continue
+		}
+		ancestors := append(q.ancestors[:len(q.ancestors):len(q.ancestors)], dfi)
+		for {
+			if time.Now().After(deadline) {
+				status.TimedOut = true
+				f.Close()
+				return
+			}
+			fis, err := f.Readdir(100)
+			if err != nil && err != io.EOF {
+				logger.Error("unable to search directory", "dir", q.dir, "err", err)
+			}
+			if len(fis) == 0 {
+				break
+			}
+			for _, fi := range fis {
+				fi, ok := s.resolve(q.dir, fi)
+				if !ok {
+					continue
+				}
+				p := path.Join(q.dir, fi.Name())
+				if fi.IsDir() && !visited(ancestors, fi) {
+					queue = append(queue, queued{p, ancestors})
+				}
+				if !match(fi.Name()) {
+					continue
+				}
+				if n >= max {
+					status.Truncated = true
+					f.Close()
+					return
+				}
+				select {
+				case results <- searchResult{
+					Path:    strings.TrimPrefix(p, dir),
+					Name:    fi.Name(),
+					IsDir:   fi.IsDir(),
+					Size:    fi.Size(),
+					ModTime: fi.ModTime().Truncate(time.Second),
+				}:
+					n++
+				case <-done:
+					f.Close()
+					return
+				}
+			}
+		}
+		f.Close()
+	}
+}
+
+// visited reports whether the directory fi is one of ancestors,
+// meaning a symbolic link loops back to it.
+func visited(ancestors []os.FileInfo, fi os.FileInfo) bool {
+	if nfi, ok := fi.(namedFileInfo); ok {
+		fi = nfi.FileInfo
+	}
+	for _, afi := range ancestors {
+		if os.SameFile(afi, fi) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveSearch replies to the request with the files under r.URL.Path whose name matches q.
+// Results are streamed as they are found, either as HTML or JSON.
+func (s server) serveSearch(w http.ResponseWriter, r *http.Request, q string) {
+	match, err := newMatcher(q, r.URL.Query().Get(searchModeQueryKey))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asJSON := false
+	switch r.URL.Query().Get(formatQueryKey) {
+	case "json":
+		asJSON = true
+	case "html":
+	default:
+		asJSON = strings.Contains(r.Header.Get("Accept"), "application/json")
+	}
+
+	// Stop the search when the client goes away or when we stop reading results.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	results := make(chan searchResult)
+	var status searchStatus
+	logger := fileserver.Logger(r.Context())
+	go s.search(logger, r.URL.Path, match, s.searchMaxResults, time.Now().Add(s.searchTimeout), ctx.Done(), results, &status)
+
+	fw := flushWriter{w}
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(fw, `{"results":[`)
+		i := 0
+		for res := range results {
+			if i > 0 {
+				io.WriteString(fw, ",")
+			}
+			b, err := json.Marshal(res)
+			if err != nil {
+				logger.Error("unable to encode search result", "err", err)
+				continue
+			}
+			fw.Write(b)
+			i++
+		}
+		b, err := json.Marshal(status)
+		if err != nil {
+			logger.Error("unable to encode search status", "err", err)
+			return
+		}
+		// Inline the status fields in the enclosing object
+		fmt.Fprintf(fw, "],%s", b[1:])
+		return
+	}
+
+	tctx := searchCtx{
+		Root:    r.URL.Path,
+		Style:   s.theme.css,
+		Brand:   s.theme.brand,
+		Query:   q,
+		Results: results,
+		Status:  &status,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if err := searchTpl.Execute(fw, tctx); err != nil {
+		logger.Error("unable to render search results", "path", r.URL.Path, "err", err)
+	}
+}
+
+// flushWriter flushes the underlying http.ResponseWriter after each write,
+// so that search results reach the client as soon as they are found.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (fw flushWriter) Write(b []byte) (int, error) {
+	n, err := fw.w.Write(b)
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
+
+type searchCtx struct {
+	Root    string
+	Style   template.CSS
+	Brand   Brand
+	Query   string
+	Results <-chan searchResult
+	// Status is only complete once Results is drained.
+	Status *searchStatus
+}
+
+var searchTpl = template.Must(newTemplate("", searchTplstr))
+
+var searchTplstr = `<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>Search {{.Query}} in {{.Root}}</title>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <style type="text/css">{{.Style}}</style>
+  </head>
+<body>
+  <div class="header">
+  {{ template "brand" .Brand }}
+  <h3>{{.Root}}</h3>
+  <form class="search" method="get"><input type="search" name="q" value="{{.Query}}" placeholder="Search {{.Root}}"></form>
+  </div>
+  <div class="contents">
+    <p class="views"><a href="./">Back to listing</a></p>
+    <table>
+    <tr>
+      <th>File</th>
+      <th>Size</th>
+      <th>Mod time</th>
+    </tr>
+    {{range .Results}}
+      <tr>
+      {{ if .IsDir }}
+        <td colspan="3"><a href="{{ urlpath .Path }}/">{{ .Path }}/</a></td>
+      {{ else }}
+        <td><a href="{{ urlpath .Path }}">{{ .Path }}</a></td>
+        <td>{{ humanbytes .Size }}</td>
+        <td>{{ fmttime .ModTime }}</td>
+      {{ end }}
+      </tr>
+    {{end}}
+    </table>
+    {{ if .Status.Truncated }}
+    <p>Too many results; only the first ones are shown.</p>
+    {{ else if .Status.TimedOut }}
+    <p>The search took too long; only the results found so far are shown.</p>
+    {{ end }}
+  </div>
+</body>
+</html>
+`
@@ -0,0 +1,129 @@
+package beachplug_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+)
+
+func TestSearch(t *testing.T) {
+	root, err := ioutil.TempDir("", "beachplug-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, name := range []string{
+		"notes.txt",
+		"a/photo.jpg",
+		"a/b/photo-2.JPG",
+		"a/b/notes.md",
+		".hidden/photo.jpg",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A symlink looping to its ancestor must not be walked endlessly.
+	if err := os.Symlink(filepath.Join(root, "a"), filepath.Join(root, "a", "b", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		params   fileserver.Params
+		urlPath  string
+		expected []string
+	}{
+		{nil, "/?q=*.jpg", []string{".hidden/photo.jpg", "a/b/photo-2.JPG", "a/photo.jpg"}},
+		{nil, "/a/?q=notes", []string{"b/notes.md"}},
+		{nil, "/?q=photo&mode=substring", []string{".hidden/photo.jpg", "a/b/photo-2.JPG", "a/photo.jpg"}},
+		{fileserver.Params{"hidden": "false"}, "/?q=*.jpg", []string{"a/b/photo-2.JPG", "a/photo.jpg"}},
+		{fileserver.Params{"symlinks": "false"}, "/?q=lo", nil},
+		{nil, "/?q=lo", []string{"a/b/loop"}},
+	}
+	for i, test := range tests {
+		fs, err := beachplug.Server(root, test.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", test.urlPath+"&format=json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%d: expected status %d, got %d", i, http.StatusOK, w.Code)
+			continue
+		}
+		var resp struct {
+			Results []struct {
+				Path string `json:"path"`
+			} `json:"results"`
+			Truncated bool `json:"truncated"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%d: %v: %s", i, err, w.Body.String())
+			continue
+		}
+		var paths []string
+		for _, res := range resp.Results {
+			paths = append(paths, res.Path)
+		}
+		sort.Strings(paths)
+		if len(paths) != len(test.expected) {
+			t.Errorf("%d: expected %v, got %v", i, test.expected, paths)
+			continue
+		}
+		for j := range paths {
+			if paths[j] != test.expected[j] {
+				t.Errorf("%d: expected %v, got %v", i, test.expected, paths)
+				break
+			}
+		}
+	}
+}
+
+func TestSearchMaxResults(t *testing.T) {
+	root, err := ioutil.TempDir("", "beachplug-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, name := range []string{"a1", "a2", "a3"} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs, err := beachplug.Server(root, fileserver.Params{"search_max_results": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/?q=a&format=json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.ServeHTTP(w, r)
+	var resp struct {
+		Results   []json.RawMessage `json:"results"`
+		Truncated bool              `json:"truncated"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 2 || !resp.Truncated {
+		t.Errorf("expected 2 truncated results, got %d (truncated: %v)", len(resp.Results), resp.Truncated)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"html/template"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -15,28 +18,187 @@ import (
 	"github.com/vincent-petithory/kraken/fileserver"
 )
 
-// Server defines the beachplug server constructor.
-var Server fileserver.Constructor = func(root string, params fileserver.Params) fileserver.Server {
-	return &server{
-		root: root,
-		fs:   http.Dir(root),
+// Options holds the settings shared by all the beachplug servers created
+// by a constructor.
+type Options struct {
+	// StateDir is the directory where generated files, like thumbnails, are cached.
+	// If empty, nothing is cached and thumbnails are generated on each request.
+	StateDir string
+	// ThemeDir is the directory of the default theme, replacing the built-in one.
+	// It may contain list.html and gallery.html templates, executed with a TemplateContext,
+	// a style.css stylesheet and a brand.json file, e.g {"name": "files", "url": "/"}.
+	// Missing files are taken from the built-in default theme.
+	ThemeDir string
+}
+
+// NewServer returns a beachplug server constructor using opts.
+//
+// The following params are recognized:
+//
+//   - gallery: if true, directories are rendered as a thumbnail grid by default.
+//   - markdown: if false, README.md files and markdown files are not rendered as HTML.
+//     Defaults to true.
+//   - hidden: if false, hidden files (whose name starts with a dot) are not listed,
+//     searched nor served. Defaults to true.
+//   - symlinks: if false, symbolic links are not listed, searched nor followed.
+//     Defaults to true.
+//   - search_max_results: maximum number of results of a search. Defaults to 1000.
+//   - search_timeout: maximum duration of a search, e.g "5s". Defaults to 5s.
+//   - theme: either the name of a built-in theme, "default" or "dark", or the absolute path
+//     of a theme directory, laid out as Options.ThemeDir.
+//   - template: absolute path of a template replacing the list view template of the theme.
+//   - stylesheet: absolute path of a stylesheet replacing the one of the theme.
+//   - brand_name, brand_url: the name and link shown in the header of the pages.
+//     An empty brand_name hides it.
+//
+// Invalid params are reported as errors; invalid theme params are also reported by NewParamsChecker.
+func NewServer(opts Options) fileserver.Constructor {
+	fsc := NewFileSystemServer(opts)
+	return func(root string, params fileserver.Params) (fileserver.Server, error) {
+		return fsc(root, Dir(root), params)
+	}
+}
+
+// FileSystem is a file system served by a beachplug server.
+type FileSystem interface {
+	http.FileSystem
+	// Lstat returns the info of the file at name, which describes
+	// the link itself if the file is a symbolic link.
+	// It is used to apply the symlinks param.
+	Lstat(name string) (os.FileInfo, error)
+}
+
+// SnapshotFileSystem is a FileSystem whose files may change between requests,
+// e.g the tree of a git branch. The servers take a snapshot of it for each request,
+// so that the files of a request are read from the same version.
+type SnapshotFileSystem interface {
+	FileSystem
+	// Snapshot returns the FileSystem of the current version.
+	Snapshot() (FileSystem, error)
+}
+
+// Dir is the FileSystem of a directory of the native file system, like http.Dir.
+type Dir string
+
+func (d Dir) Open(name string) (http.File, error) {
+	return http.Dir(d).Open(name)
+}
+
+func (d Dir) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(filepath.Join(string(d), filepath.FromSlash(path.Clean("/"+name))))
+}
+
+// FileSystemConstructor is the type of the funcs creating a beachplug server
+// which serves fs, for file server types whose source is not a directory.
+// root is the mount source, as returned by Root().
+type FileSystemConstructor func(root string, fs FileSystem, params fileserver.Params) (fileserver.Server, error)
+
+// NewFileSystemServer returns a constructor of beachplug servers serving any FileSystem,
+// using opts. They recognize the same params as the ones created by NewServer.
+func NewFileSystemServer(opts Options) FileSystemConstructor {
+	thumbs := newThumbnailer(opts.StateDir)
+	return func(root string, fs FileSystem, params fileserver.Params) (fileserver.Server, error) {
+		th, err := newTheme(opts, params)
+		if err != nil {
+			return nil, err
+		}
+		s := &server{
+			root:             root,
+			fs:               fs,
+			markdown:         true,
+			hidden:           true,
+			symlinks:         true,
+			searchMaxResults: defaultSearchMaxResults,
+			searchTimeout:    defaultSearchTimeout,
+			thumbs:           thumbs,
+			theme:            th,
+		}
+		for name, v := range map[string]*bool{
+			"gallery":  &s.gallery,
+			"markdown": &s.markdown,
+			"hidden":   &s.hidden,
+			"symlinks": &s.symlinks,
+		} {
+			if params[name] == "" {
+				continue
+			}
+			if *v, err = strconv.ParseBool(params[name]); err != nil {
+				return nil, fmt.Errorf("invalid %s param: %v", name, err)
+			}
+		}
+		if v := params["search_max_results"]; v != "" {
+			if s.searchMaxResults, err = strconv.Atoi(v); err != nil || s.searchMaxResults <= 0 {
+				return nil, fmt.Errorf("invalid search_max_results param %q", v)
+			}
+		}
+		if v := params["search_timeout"]; v != "" {
+			if s.searchTimeout, err = time.ParseDuration(v); err != nil || s.searchTimeout <= 0 {
+				return nil, fmt.Errorf("invalid search_timeout param %q", v)
+			}
+		}
+		return s, nil
 	}
 }
 
+// Params describes the params recognized by the beachplug servers.
+// File server types built on a FileSystemConstructor recognize them too.
+var Params = fileserver.ParamSchema{
+	{Name: "gallery", Type: fileserver.ParamBool, Default: "false", Description: "Render directories as a thumbnail grid by default."},
+	{Name: "markdown", Type: fileserver.ParamBool, Default: "true", Description: "Render README.md files and markdown files as HTML."},
+	{Name: "hidden", Type: fileserver.ParamBool, Default: "true", Description: "List, search and serve hidden files."},
+	{Name: "symlinks", Type: fileserver.ParamBool, Default: "true", Description: "List, search and follow symbolic links."},
+	{Name: "search_max_results", Type: fileserver.ParamInt, Default: strconv.Itoa(defaultSearchMaxResults), Description: "Maximum number of results of a search."},
+	{Name: "search_timeout", Type: fileserver.ParamDuration, Default: defaultSearchTimeout.String(), Description: "Maximum duration of a search."},
+	{Name: "theme", Type: fileserver.ParamString, Default: "default", Description: "Name of a built-in theme, default or dark, or absolute path of a theme directory."},
+	{Name: "template", Type: fileserver.ParamPath, Description: "Template replacing the list view template of the theme."},
+	{Name: "stylesheet", Type: fileserver.ParamPath, Description: "Stylesheet replacing the one of the theme."},
+	{Name: "brand_name", Type: fileserver.ParamString, Default: defaultBrand.Name, Description: "Name shown in the header of the pages. Empty hides it."},
+	{Name: "brand_url", Type: fileserver.ParamString, Default: defaultBrand.URL, Description: "Link of the name shown in the header of the pages."},
+}
+
+// Server defines the beachplug server constructor, with no state directory.
+var Server = NewServer(Options{})
+
 type server struct {
-	fs   http.FileSystem
-	root string
+	fs               FileSystem
+	root             string
+	gallery          bool
+	markdown         bool
+	hidden           bool
+	symlinks         bool
+	searchMaxResults int
+	searchTimeout    time.Duration
+	thumbs           *thumbnailer
+	theme            theme
 }
 
 func (s server) Root() string {
 	return s.root
 }
 
+// Open opens the file at name, unless it is not served according to the hidden and symlinks params.
+func (s server) Open(name string) (http.File, error) {
+	if !s.allowed(name) {
+		return nil, os.ErrNotExist
+	}
+	return s.fs.Open(name)
+}
+
 func (s server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path[0] != '/' {
 		r.URL.Path = "/" + r.URL.Path
 	}
-	f, err := s.fs.Open(r.URL.Path)
+	if sfs, ok := s.fs.(SnapshotFileSystem); ok {
+		// s is a copy: the snapshot is used by this request only.
+		fs, err := sfs.Snapshot()
+		if err != nil {
+			fileserver.Logger(r.Context()).Error("unable to read file system", "root", s.root, "err", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.fs = fs
+	}
+	f, err := s.Open(r.URL.Path)
 	if err != nil {
 		http.NotFound(w, r)
 		return
@@ -50,6 +212,14 @@ func (s server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !fi.IsDir() {
+		if _, ok := r.URL.Query()[thumbQueryKey]; ok && isImage(fi.Name()) {
+			s.thumbs.ServeThumbnail(w, r, f, fi, s.root+r.URL.Path)
+			return
+		}
+		if render, _ := strconv.ParseBool(r.URL.Query().Get(renderQueryKey)); render && s.markdown && isMarkdown(fi.Name()) {
+			s.serveMarkdown(w, r, f, fi)
+			return
+		}
 		http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
 		return
 	}
@@ -59,12 +229,25 @@ func (s server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if q := r.URL.Query().Get(searchQueryKey); q != "" {
+		s.serveSearch(w, r, q)
+		return
+	}
+
 	// Dir listing
-	ctx := tplCtx{
+	ctx := TemplateContext{
 		Root:        r.URL.Path,
-		Style:       css,
-		Files:       make(filelist, 0),
-		Directories: make(dirlist, 0),
+		Brand:       s.theme.brand,
+		Files:       make(FileList, 0),
+		Images:      make(FileList, 0),
+		Directories: make(DirList, 0),
+		Gallery:     s.gallery,
+		Markdown:    s.markdown,
+	}
+	switch view := r.URL.Query().Get(viewQueryKey); view {
+	case viewGallery, viewList:
+		ctx.Gallery = view == viewGallery
+		ctx.Query = "?" + url.Values{viewQueryKey: {view}}.Encode()
 	}
 	for {
 		fis, err := f.Readdir(100)
@@ -76,60 +259,214 @@ func (s server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		for _, fi := range fis {
+			fi, ok := s.resolve(r.URL.Path, fi)
+			if !ok {
+				continue
+			}
 			if fi.IsDir() {
 				ctx.Directories = append(ctx.Directories, fi.Name())
 			} else {
-				f := file{fi.Name(), fi.Size(), fi.ModTime().Truncate(time.Second)}
+				f := File{fi.Name(), fi.Size(), fi.ModTime().Truncate(time.Second)}
+				if isImage(f.Name) {
+					ctx.Images = append(ctx.Images, f)
+				}
 				ctx.Files = append(ctx.Files, f)
 			}
 		}
 	}
 	if r.URL.Path != "/" {
-		ctx.Directories = append(dirlist{".."}, ctx.Directories...)
+		ctx.Directories = append(DirList{".."}, ctx.Directories...)
 	}
 	ctx.NumFiles = len(ctx.Files)
+	ctx.NumImages = len(ctx.Images)
 	ctx.NumDirectories = len(ctx.Directories)
+	if s.markdown {
+		for _, f := range ctx.Files {
+			if !isReadme(f.Name) {
+				continue
+			}
+			readme, err := s.renderFile(path.Join(r.URL.Path, f.Name))
+			if err != nil {
+				fileserver.Logger(r.Context()).Error("unable to render readme", "path", path.Join(r.URL.Path, f.Name), "err", err)
+				break
+			}
+			ctx.Readme = readme
+			ctx.Style += markdownCSS
+			break
+		}
+	}
+
+	t := s.theme.list
+	if ctx.Gallery {
+		t = s.theme.gallery
+		ctx.Style += galleryCSS
+		// In gallery mode, images are shown in the grid, not in the file table.
+		others := make(FileList, 0, len(ctx.Files)-len(ctx.Images))
+		for _, f := range ctx.Files {
+			if !isImage(f.Name) {
+				others = append(others, f)
+			}
+		}
+		ctx.Files = others
+		ctx.NumFiles = len(ctx.Files)
+	}
+
+	// The theme comes last, so that it can override the styles of the views.
+	ctx.Style += s.theme.css
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	if err := tpl.Execute(w, ctx); err != nil {
-		log.Print(err)
+	if err := t.Execute(w, ctx); err != nil {
+		fileserver.Logger(r.Context()).Error("unable to render directory listing", "path", r.URL.Path, "err", err)
+	}
+}
+
+// allowed reports whether the file at name may be served,
+// according to the hidden and symlinks params.
+func (s server) allowed(name string) bool {
+	if s.hidden && s.symlinks {
+		return true
+	}
+	p := "/"
+	for _, elem := range strings.Split(path.Clean("/" + name)[1:], "/") {
+		if elem == "" {
+			continue
+		}
+		if !s.hidden && elem[0] == '.' {
+			return false
+		}
+		if !s.symlinks {
+			p = path.Join(p, elem)
+			if fi, err := s.fs.Lstat(p); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// resolve returns the info of the file described by fi, an entry of the directory dir.
+// If fi is a symbolic link, the info of its target is returned.
+// It returns false if the entry must be skipped, according to the hidden and symlinks params.
+func (s server) resolve(dir string, fi os.FileInfo) (os.FileInfo, bool) {
+	if !s.hidden && strings.HasPrefix(fi.Name(), ".") {
+		return nil, false
+	}
+	if fi.IsDir() && !s.symlinks {
+		// A directory merging several trees, e.g in a union, may be a link in one of them.
+		if lfi, err := s.fs.Lstat(path.Join(dir, fi.Name())); err != nil || lfi.Mode()&os.ModeSymlink != 0 {
+			return nil, false
+		}
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return fi, true
+	}
+	if !s.symlinks {
+		return nil, false
+	}
+	f, err := s.fs.Open(path.Join(dir, fi.Name()))
+	if err != nil {
+		// dangling link
+		return nil, false
+	}
+	defer f.Close()
+	tfi, err := f.Stat()
+	if err != nil {
+		return nil, false
 	}
+	return namedFileInfo{tfi, fi.Name()}, true
+}
+
+// namedFileInfo overrides the name of a os.FileInfo,
+// so that a symbolic link is listed with its own name and not the one of its target.
+type namedFileInfo struct {
+	os.FileInfo
+	name string
 }
 
-type tplCtx struct {
-	Root           string
-	Style          template.CSS
-	Files          filelist
-	Directories    dirlist
+func (fi namedFileInfo) Name() string {
+	return fi.name
+}
+
+const (
+	// viewQueryKey is the query parameter switching between the list and gallery views.
+	viewQueryKey = "view"
+	viewGallery  = "gallery"
+	viewList     = "list"
+)
+
+// TemplateContext is the data the list and gallery templates of a theme are executed with.
+//
+// Its fields are part of the interface of themes: they may be added to,
+// but not removed nor changed.
+//
+// Besides the built-in functions of html/template, the templates may use:
+//
+//   - urlpath NAME: NAME escaped as a URL path.
+//   - sorted LIST: LIST sorted by name, case insensitively, e.g sorted .Files.
+//   - fmttime TIME: TIME formatted as 2006-01-02 15:04:05.
+//   - humanbytes SIZE: SIZE in bytes, KiB, MiB or GiB.
+//   - ismarkdown NAME: whether NAME is the name of a markdown file.
+//   - ellipsis S MAX: S cut to MAX characters, ending with an ellipsis if it was longer.
+//
+// and the "brand" template, executed with a Brand, renders the brand link of the header.
+type TemplateContext struct {
+	// Root is the path of the listed directory, relative to the mount point, e.g /docs/.
+	Root string
+	// Style is the stylesheet of the page: the one of the theme, preceded by the
+	// styles of the markdown and gallery views when they are used.
+	Style template.CSS
+	// Brand is the name and link to show in the header.
+	Brand Brand
+	// Files are the files of the directory, images included, unless Gallery is true.
+	Files FileList
+	// Images are the images of the directory, which have a thumbnail at NAME?thumb.
+	Images FileList
+	// Directories are the names of the sub-directories, preceded by ".." if Root is not /.
+	Directories DirList
+	// NumFiles, NumImages and NumDirectories are the lengths of Files, Images and Directories.
 	NumFiles       int
+	NumImages      int
 	NumDirectories int
+	// Gallery reports whether the directory is shown as a gallery.
+	Gallery bool
+	// Query is the query string, e.g ?view=gallery, to append to the links to
+	// sub-directories to keep the current view. It is empty if the view is the default one.
+	Query string
+	// Markdown reports whether markdown files can be rendered, by appending ?render=1
+	// to their URL.
+	Markdown bool
+	// Readme is the rendered README.md of the directory, if any and if Markdown is true.
+	Readme template.HTML
 }
 
-type file struct {
+// File describes a file of a listed directory.
+type File struct {
 	Name    string
 	Size    int64
 	ModTime time.Time
 }
 
-type dirlist []string
+// DirList is a list of directory names.
+type DirList []string
 
-func (l dirlist) Less(i int, j int) bool {
+func (l DirList) Less(i int, j int) bool {
 	return strings.ToLower(l[i]) < strings.ToLower(l[j])
 }
-func (l dirlist) Swap(i int, j int) { l[i], l[j] = l[j], l[i] }
-func (l dirlist) Len() int          { return len(l) }
+func (l DirList) Swap(i int, j int) { l[i], l[j] = l[j], l[i] }
+func (l DirList) Len() int          { return len(l) }
 
-type filelist []file
+// FileList is a list of files.
+type FileList []File
 
-func (l filelist) Less(i int, j int) bool {
+func (l FileList) Less(i int, j int) bool {
 	if l[i].Name == l[j].Name {
 		return l[i].ModTime.Before(l[j].ModTime)
 	}
 	return strings.ToLower(l[i].Name) < strings.ToLower(l[j].Name)
 }
-func (l filelist) Swap(i int, j int) { l[i], l[j] = l[j], l[i] }
-func (l filelist) Len() int          { return len(l) }
+func (l FileList) Swap(i int, j int) { l[i], l[j] = l[j], l[i] }
+func (l FileList) Len() int          { return len(l) }
 
 const (
 	kib = 1024
@@ -162,6 +499,7 @@ var fm = map[string]interface{}{
 			return fmt.Sprintf("%d B", n)
 		}
 	},
+	"ismarkdown": isMarkdown,
 	"ellipsis": func(s string, max int) string {
 		if utf8.RuneCountInString(s) > max {
 			return fmt.Sprintf("%."+fmt.Sprintf("%d", max-1)+"s…", s)
@@ -170,7 +508,26 @@ var fm = map[string]interface{}{
 	},
 }
 
-var tpl = template.Must(template.New("").Funcs(fm).Parse(tplstr))
+var tpl = template.Must(newTemplate("", tplstr))
+
+// newTemplate parses text as a page template named name, with the funcs and
+// the "brand" template available.
+func newTemplate(name string, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(fm).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if t.Lookup("brand") == nil {
+		if _, err := t.New("brand").Parse(brandTplstr); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// brandTplstr renders a Brand in the header of the pages.
+// It is available to the templates of themes as the "brand" template.
+var brandTplstr = `{{ if .Name }}<a class="brand"{{ if .URL }} href="{{ .URL }}"{{ end }}>{{ .Name }}</a>{{ end }}`
 
 var tplstr = `<!DOCTYPE html>
 <html>
@@ -182,14 +539,18 @@ var tplstr = `<!DOCTYPE html>
   </head>
 <body>
   <div class="header">
-  <a href="https://github.com/vincent-petithory/kraken">kraken</a>
-  <h3>{{.Root}}</h3> 
+  {{ template "brand" .Brand }}
+  <h3>{{.Root}}</h3>
+  <form class="search" method="get"><input type="search" name="q" placeholder="Search {{.Root}}"></form>
   </div>
   <div class="contents">
+    {{ if .NumImages }}
+    <p class="views"><a href="?view=gallery">Gallery view</a></p>
+    {{ end }}
     <table>
     {{range sorted .Directories}}
       <tr>
-        <td colspan="3"><a href="{{ urlpath . }}/">{{ . }}/</a></td>
+        <td colspan="3"><a href="{{ urlpath . }}/{{ $.Query }}">{{ . }}/</a></td>
       </tr>
     {{end}}
     </table>
@@ -206,13 +567,17 @@ var tplstr = `<!DOCTYPE html>
     </tr>
     {{range sorted .Files}}
       <tr>
-        <td><a href="{{ urlpath .Name }}">{{ ellipsis .Name 50 }}</a></td>
+        <td><a href="{{ urlpath .Name }}{{ if and $.Markdown (ismarkdown .Name) }}?render=1{{ end }}">{{ ellipsis .Name 50 }}</a></td>
         <td>{{ humanbytes .Size }}</td>
         <td>{{ fmttime .ModTime }}</td>
       </tr>
     {{end}}
     </table>
     {{end}}
+    {{ if .Readme }}
+    <hr/>
+    <div class="markdown">{{ .Readme }}</div>
+    {{ end }}
   </div>
 </body>
 </html>
@@ -274,6 +639,20 @@ th, td {
   font-size: 0.7em;
 }
 
+.views {
+  margin-bottom: 1em;
+}
+
+.header .search {
+  margin-top: 0.5em;
+}
+.header .search input {
+  padding: 2px 4px;
+  border: 0;
+  width: 20em;
+  max-width: 100%;
+}
+
 .contents a, .contents a:visited {
   color: rgb(199, 65, 79);
   padding: 2px;
@@ -0,0 +1,270 @@
+package beachplug
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+// Files of a theme directory. Each of them is optional; a missing file
+// is taken from the default theme.
+const (
+	// themeListFile is the template of the list view of directories.
+	themeListFile = "list.html"
+	// themeGalleryFile is the template of the gallery view of directories.
+	themeGalleryFile = "gallery.html"
+	// themeStyleFile is the stylesheet of all the pages.
+	themeStyleFile = "style.css"
+	// themeBrandFile holds the branding, as a JSON object: {"name": "...", "url": "..."}.
+	themeBrandFile = "brand.json"
+)
+
+// Brand is the name and link shown in the header of the pages.
+// If Name is empty, nothing is shown.
+type Brand struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// theme holds the templates, stylesheet and branding of the pages of a server.
+//
+// The list and gallery templates are executed with a TemplateContext.
+type theme struct {
+	list    *template.Template
+	gallery *template.Template
+	css     template.CSS
+	brand   Brand
+}
+
+var defaultBrand = Brand{
+	Name: "kraken",
+	URL:  "https://github.com/vincent-petithory/kraken",
+}
+
+// builtinThemes are the themes which can be selected by name with the theme param.
+var builtinThemes = map[string]theme{
+	"default": {list: tpl, gallery: galleryTpl, css: css, brand: defaultBrand},
+	"dark":    {list: tpl, gallery: galleryTpl, css: darkCSS, brand: defaultBrand},
+}
+
+// newTheme returns the theme selected by params.
+//
+// The default theme is the one in opts.ThemeDir, if set, or the built-in default theme.
+// The theme param selects a built-in theme by name, or a theme directory by absolute path;
+// the template, stylesheet, brand_name and brand_url params then replace parts of it.
+func newTheme(opts Options, params fileserver.Params) (theme, error) {
+	t := builtinThemes["default"]
+	if opts.ThemeDir != "" {
+		var err error
+		if t, err = loadThemeDir(t, opts.ThemeDir); err != nil {
+			return theme{}, err
+		}
+	}
+	switch name := params["theme"]; {
+	case name == "":
+	case filepath.IsAbs(name):
+		var err error
+		if t, err = loadThemeDir(builtinThemes["default"], name); err != nil {
+			return theme{}, err
+		}
+	default:
+		bt, ok := builtinThemes[name]
+		if !ok {
+			return theme{}, fmt.Errorf("unknown theme %q", name)
+		}
+		t = bt
+	}
+
+	if name := params["template"]; name != "" {
+		list, err := parseTemplateFile(name)
+		if err != nil {
+			return theme{}, err
+		}
+		t.list = list
+	}
+	if name := params["stylesheet"]; name != "" {
+		b, err := readThemeFile(name)
+		if err != nil {
+			return theme{}, err
+		}
+		t.css = template.CSS(b)
+	}
+	if name, ok := params["brand_name"]; ok {
+		t.brand = Brand{Name: name}
+	}
+	if u, ok := params["brand_url"]; ok {
+		t.brand.URL = u
+	}
+	return t, nil
+}
+
+// loadThemeDir returns base, with the parts found in the theme directory dir replaced.
+func loadThemeDir(base theme, dir string) (theme, error) {
+	t := base
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	if fi, err := os.Stat(dir); err != nil {
+		return theme{}, err
+	} else if !fi.IsDir() {
+		return theme{}, fmt.Errorf("%s: theme is not a directory", dir)
+	}
+	if exists(themeListFile) {
+		list, err := parseTemplateFile(filepath.Join(dir, themeListFile))
+		if err != nil {
+			return theme{}, err
+		}
+		t.list = list
+	}
+	if exists(themeGalleryFile) {
+		gallery, err := parseTemplateFile(filepath.Join(dir, themeGalleryFile))
+		if err != nil {
+			return theme{}, err
+		}
+		t.gallery = gallery
+	}
+	if exists(themeStyleFile) {
+		b, err := readThemeFile(filepath.Join(dir, themeStyleFile))
+		if err != nil {
+			return theme{}, err
+		}
+		t.css = template.CSS(b)
+	}
+	if exists(themeBrandFile) {
+		b, err := readThemeFile(filepath.Join(dir, themeBrandFile))
+		if err != nil {
+			return theme{}, err
+		}
+		var brand Brand
+		if err := json.Unmarshal(b, &brand); err != nil {
+			return theme{}, fmt.Errorf("%s: %v", filepath.Join(dir, themeBrandFile), err)
+		}
+		t.brand = brand
+	}
+	return t, nil
+}
+
+func readThemeFile(name string) ([]byte, error) {
+	if !filepath.IsAbs(name) {
+		return nil, fmt.Errorf("%s: theme file path is not absolute", name)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// parseTemplateFile parses the template in the file name, with the funcs of the built-in templates.
+func parseTemplateFile(name string) (*template.Template, error) {
+	b, err := readThemeFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return newTemplate(filepath.Base(name), string(b))
+}
+
+// NewParamsChecker returns a func checking the params of beachplug servers created
+// with opts, e.g that the templates of their theme parse.
+func NewParamsChecker(opts Options) fileserver.ParamsChecker {
+	return func(params fileserver.Params) error {
+		_, err := newTheme(opts, params)
+		return err
+	}
+}
+
+const darkCSS = template.CSS(`
+* {
+  padding: 0;
+  margin: 0;
+}
+
+html {
+  color: rgb(210, 210, 210);
+  font-family: Sans;
+}
+
+body {
+  background: rgb(30, 31, 34);
+}
+
+hr {
+  display: block;
+  border: 0;
+  border-top: 3px solid rgb(88, 166, 255);
+  margin: 1em 0;
+  padding: 0;
+}
+
+th {
+  font-weight: bold;
+  text-align: left;
+}
+
+th, td {
+  padding: 2px;
+}
+
+.header {
+  background: rgb(22, 27, 34);
+  border-bottom: 1px solid rgb(48, 54, 61);
+  padding: 22px 15px;
+}
+.header a, .header a:visited {
+  float: right;
+  color: rgb(139, 148, 158);
+  font-size: 1.2em;
+  font-weight: bold;
+  transition: color 0.2s;
+  text-decoration: none;
+}
+.header a:hover {
+  color: rgb(210, 210, 210);
+  transition: color 0.2s;
+}
+
+.contents {
+  margin-top: 20px;
+  margin-left: 20%;
+  margin-right: 20%;
+  font-size: 0.7em;
+}
+
+.views {
+  margin-bottom: 1em;
+}
+
+.header .search {
+  margin-top: 0.5em;
+}
+.header .search input {
+  padding: 2px 4px;
+  border: 1px solid rgb(48, 54, 61);
+  background: rgb(13, 17, 23);
+  color: rgb(210, 210, 210);
+  width: 20em;
+  max-width: 100%;
+}
+
+.contents a, .contents a:visited {
+  color: rgb(88, 166, 255);
+  padding: 2px;
+  background: transparent;
+  transition: background 0.3s, color 0.2s;
+  text-decoration: none;
+}
+.contents a:hover {
+  background: rgb(88, 166, 255);
+  color: rgb(30, 31, 34);
+  transition: background 0.2s, color 0.2s;
+}
+
+.markdown pre, .markdown code {
+  background: rgba(255, 255, 255, 0.08);
+}
+.markdown blockquote {
+  border-left-color: rgb(88, 166, 255);
+}
+`)
@@ -0,0 +1,125 @@
+package beachplug_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+)
+
+func TestTheme(t *testing.T) {
+	root, err := ioutil.TempDir("", "beachplug-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	themeDir, err := ioutil.TempDir("", "beachplug-theme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(themeDir)
+
+	files := map[string]string{
+		filepath.Join(root, "notes.txt"):          "notes",
+		filepath.Join(themeDir, "list.html"):      `<style>{{.Style}}</style>{{ template "brand" .Brand }}{{range .Files}}[{{.Name}}]{{end}}`,
+		filepath.Join(themeDir, "style.css"):      `body { color: teal; }`,
+		filepath.Join(themeDir, "brand.json"):     `{"name": "Team files", "url": "/"}`,
+		filepath.Join(themeDir, "broken.html"):    `{{ .Files `,
+		filepath.Join(themeDir, "alt/style.css"):  `body { color: olive; }`,
+		filepath.Join(themeDir, "custom.html"):    `custom {{.NumFiles}}`,
+		filepath.Join(themeDir, "override.css"):   `body { color: navy; }`,
+		filepath.Join(themeDir, "alt/brand.json"): `{"name": ""}`,
+	}
+	for name, body := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Opts        beachplug.Options
+		Params      fileserver.Params
+		Contains    []string
+		NotContains []string
+	}{
+		{
+			Contains: []string{"rgb(255, 233, 198)", `href="https://github.com/vincent-petithory/kraken"`, "notes.txt"},
+		},
+		{
+			Params:      fileserver.Params{"theme": "dark"},
+			Contains:    []string{"rgb(30, 31, 34)", "notes.txt"},
+			NotContains: []string{"rgb(255, 233, 198)"},
+		},
+		{
+			Opts:     beachplug.Options{ThemeDir: themeDir},
+			Contains: []string{"color: teal", `<a class="brand" href="/">Team files</a>`, "[notes.txt]"},
+		},
+		{
+			// A theme by path replaces the daemon theme; missing files come from the default theme.
+			Opts:        beachplug.Options{ThemeDir: themeDir},
+			Params:      fileserver.Params{"theme": filepath.Join(themeDir, "alt")},
+			Contains:    []string{"color: olive", "notes.txt"},
+			NotContains: []string{"[notes.txt]", `class="brand"`},
+		},
+		{
+			Params:   fileserver.Params{"template": filepath.Join(themeDir, "custom.html")},
+			Contains: []string{"custom 1"},
+		},
+		{
+			Params:      fileserver.Params{"stylesheet": filepath.Join(themeDir, "override.css"), "brand_name": "Shares", "brand_url": ""},
+			Contains:    []string{"color: navy", `<a class="brand">Shares</a>`},
+			NotContains: []string{"rgb(255, 233, 198)"},
+		},
+	}
+	for i, test := range tests {
+		if err := beachplug.NewParamsChecker(test.Opts)(test.Params); err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		fs, err := beachplug.NewServer(test.Opts)(root, test.Params)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.ServeHTTP(w, r)
+		body := w.Body.String()
+		for _, s := range test.Contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("%d: expected page to contain %q, got %q", i, s, body)
+			}
+		}
+		for _, s := range test.NotContains {
+			if strings.Contains(body, s) {
+				t.Errorf("%d: expected page not to contain %q", i, s)
+			}
+		}
+	}
+
+	for _, params := range []fileserver.Params{
+		{"theme": "neon"},
+		{"theme": filepath.Join(themeDir, "missing")},
+		{"template": filepath.Join(themeDir, "broken.html")},
+		{"template": "custom.html"},
+	} {
+		if err := beachplug.NewParamsChecker(beachplug.Options{})(params); err == nil {
+			t.Errorf("expected params %v to be invalid", params)
+		}
+		if _, err := beachplug.Server(root, params); err == nil {
+			t.Errorf("expected the server not to be created with params %v", params)
+		}
+	}
+}
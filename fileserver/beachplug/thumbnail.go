@@ -0,0 +1,231 @@
+package beachplug
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // register the GIF decoder for thumbnails
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+// thumbQueryKey is the query parameter requesting the thumbnail of an image.
+const thumbQueryKey = "thumb"
+
+// thumbnailSize is the maximum width and height of a thumbnail, in pixels.
+const thumbnailSize = 240
+
+// maxImagePixels is the number of pixels of the largest image a thumbnail is generated for,
+// since the whole image is decoded in memory.
+const maxImagePixels = 50 << 20
+
+// errImageTooLarge is returned when an image has more than maxImagePixels pixels.
+var errImageTooLarge = errors.New("image too large for a thumbnail")
+
+// isImage reports whether name has the extension of an image a thumbnail can be generated for.
+func isImage(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// thumbnailer generates thumbnails of images and caches them on disk.
+type thumbnailer struct {
+	dir string
+	// sem limits the number of thumbnails generated at the same time,
+	// since decoding large images is expensive.
+	sem chan struct{}
+}
+
+// newThumbnailer returns a thumbnailer caching thumbnails under stateDir.
+// If stateDir is empty, thumbnails are not cached.
+func newThumbnailer(stateDir string) *thumbnailer {
+	t := &thumbnailer{
+		sem: make(chan struct{}, runtime.NumCPU()),
+	}
+	if stateDir != "" {
+		t.dir = filepath.Join(stateDir, "thumbnails")
+	}
+	return t
+}
+
+// ServeThumbnail replies to the request with a thumbnail of the image f.
+// key identifies the image in the cache.
+//
+// A cached thumbnail is reused as long as its mtime matches the one of the image.
+func (t *thumbnailer) ServeThumbnail(w http.ResponseWriter, r *http.Request, f io.ReadSeeker, fi os.FileInfo, key string) {
+	ext := ".png"
+	if e := strings.ToLower(path.Ext(fi.Name())); e == ".jpg" || e == ".jpeg" {
+		ext = ".jpg"
+	}
+	name := fmt.Sprintf("%x%s", sha1.Sum([]byte(key)), ext)
+
+	var cacheFile string
+	if t.dir != "" {
+		cacheFile = filepath.Join(t.dir, name)
+		if cf, err := os.Open(cacheFile); err == nil {
+			defer cf.Close()
+			if cfi, err := cf.Stat(); err == nil && cfi.ModTime().Unix() == fi.ModTime().Unix() {
+				http.ServeContent(w, r, name, cfi.ModTime(), cf)
+				return
+			}
+		}
+	}
+
+	t.sem <- struct{}{}
+	b, err := makeThumbnail(f, ext)
+	<-t.sem
+	if err == errImageTooLarge {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if cacheFile != "" {
+		if err := writeCacheFile(cacheFile, b, fi.ModTime()); err != nil {
+			fileserver.Logger(r.Context()).Error("unable to cache thumbnail", "file", cacheFile, "err", err)
+		}
+	}
+	http.ServeContent(w, r, name, fi.ModTime(), bytes.NewReader(b))
+}
+
+// writeCacheFile atomically writes b to name and sets its mtime to modTime.
+func writeCacheFile(name string, b []byte, modTime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".thumb")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), modTime, modTime); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// makeThumbnail decodes the image read from r and encodes a scaled down version of it,
+// as a JPEG if ext is ".jpg", or as a PNG otherwise.
+// Images of more than maxImagePixels pixels are refused with errImageTooLarge.
+func makeThumbnail(r io.ReadSeeker, ext string) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return nil, errImageTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	thumb := scaleDown(img, thumbnailSize)
+	var buf bytes.Buffer
+	if ext == ".jpg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleDown returns src scaled so that it fits in a max x max square,
+// preserving its aspect ratio. Each pixel of the result is the average of
+// the source pixels it covers. src is returned as is if it already fits.
+//
+// src is converted to RGBA one row at a time, so that scaling a large image
+// doesn't need a full size copy of it.
+func scaleDown(src image.Image, max int) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw <= max && sh <= max {
+		return src
+	}
+	dw, dh := max, max
+	if sw > sh {
+		dh = sh * max / sw
+	} else {
+		dw = sw * max / sh
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	row := image.NewRGBA(image.Rect(0, 0, sw, 1))
+	sums := make([]int, dw*4)
+	d := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0, sy1 := y*sh/dh, (y+1)*sh/dh
+		if sy1 == sy0 {
+			sy1++
+		}
+		for i := range sums {
+			sums[i] = 0
+		}
+		for sy := sy0; sy < sy1; sy++ {
+			draw.Draw(row, row.Bounds(), src, image.Pt(b.Min.X, b.Min.Y+sy), draw.Src)
+			for x := 0; x < dw; x++ {
+				sx0, sx1 := x*sw/dw, (x+1)*sw/dw
+				if sx1 == sx0 {
+					sx1++
+				}
+				sum := sums[x*4 : x*4+4]
+				for i := sx0 * 4; i < sx1*4; i += 4 {
+					sum[0] += int(row.Pix[i])
+					sum[1] += int(row.Pix[i+1])
+					sum[2] += int(row.Pix[i+2])
+					sum[3] += int(row.Pix[i+3])
+				}
+			}
+		}
+		for x := 0; x < dw; x++ {
+			sx0, sx1 := x*sw/dw, (x+1)*sw/dw
+			if sx1 == sx0 {
+				sx1++
+			}
+			n := (sy1 - sy0) * (sx1 - sx0)
+			j := d.PixOffset(x, y)
+			for k := 0; k < 4; k++ {
+				d.Pix[j+k] = uint8(sums[x*4+k] / n)
+			}
+		}
+	}
+	return d
+}
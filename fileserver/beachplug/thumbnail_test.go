@@ -0,0 +1,166 @@
+package beachplug_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+)
+
+func writePNG(t *testing.T, name string, w, h int) {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 0xff})
+		}
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	root, err := ioutil.TempDir("", "beachplug-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	stateDir, err := ioutil.TempDir("", "beachplug-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(stateDir)
+
+	imgPath := filepath.Join(root, "pic.png")
+	writePNG(t, imgPath, 800, 400)
+
+	fs, err := beachplug.NewServer(beachplug.Options{StateDir: stateDir})(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getThumb := func() image.Config {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "/pic.png?thumb", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		cfg, err := png.DecodeConfig(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	cfg := getThumb()
+	if cfg.Width != 240 || cfg.Height != 120 {
+		t.Errorf("expected a 240x120 thumbnail, got %dx%d", cfg.Width, cfg.Height)
+	}
+	cached, err := filepath.Glob(filepath.Join(stateDir, "thumbnails", "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != 1 {
+		t.Fatalf("expected 1 cached thumbnail, got %d", len(cached))
+	}
+
+	// Replace the image and change its mtime: the thumbnail must be regenerated.
+	writePNG(t, imgPath, 100, 400)
+	mtime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(imgPath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	cfg = getThumb()
+	if cfg.Width != 60 || cfg.Height != 240 {
+		t.Errorf("expected a 60x240 thumbnail, got %dx%d", cfg.Width, cfg.Height)
+	}
+}
+
+func TestThumbnailTooLarge(t *testing.T) {
+	root, err := ioutil.TempDir("", "beachplug-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// A small GIF announcing a 30000x30000 screen, as a crafted image could.
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White}), nil); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	binary.LittleEndian.PutUint16(b[6:8], 30000)
+	binary.LittleEndian.PutUint16(b[8:10], 30000)
+	if err := ioutil.WriteFile(filepath.Join(root, "huge.gif"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := beachplug.Server(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/huge.gif?thumb", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestGalleryView(t *testing.T) {
+	root, err := ioutil.TempDir("", "beachplug-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writePNG(t, filepath.Join(root, "pic.png"), 10, 10)
+
+	tests := []struct {
+		params  map[string]string
+		query   string
+		gallery bool
+	}{
+		{nil, "", false},
+		{nil, "?view=gallery", true},
+		{map[string]string{"gallery": "true"}, "", true},
+		{map[string]string{"gallery": "true"}, "?view=list", false},
+	}
+	for i, test := range tests {
+		fs, err := beachplug.Server(root, test.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "/"+test.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.ServeHTTP(w, r)
+		if gallery := strings.Contains(w.Body.String(), `id="lightbox"`); gallery != test.gallery {
+			t.Errorf("%d: expected gallery view to be %v, got %v", i, test.gallery, gallery)
+		}
+	}
+}
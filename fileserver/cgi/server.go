@@ -0,0 +1,240 @@
+package cgi
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/cgi"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vincent-petithory/kraken"
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+const (
+	// envParamPrefix prefixes the params setting an environment variable of the scripts,
+	// e.g env.DATABASE_URL.
+	envParamPrefix = "env."
+
+	defaultExtensions    = ".cgi"
+	defaultTimeout       = 30 * time.Second
+	defaultMaxConcurrent = 8
+)
+
+// Server is the constructor of cgi servers, which run the executables of a directory
+// as CGI scripts, like net/http/cgi. Other files are served statically.
+//
+// A request for /tools/report.cgi/2024 runs the script tools/report.cgi of the mount
+// source, with a PATH_INFO of /2024. The script runs in its directory.
+// A script which is not executable is never served, not even as a static file.
+// The response of a script is sent once it exits.
+// Scripts are run with net/http/cgi, through a copy of the current executable
+// which kills them, and the processes they started, when they time out.
+//
+// The following params are recognized:
+//
+//   - extensions: comma separated list of the extensions of the scripts. Defaults to .cgi.
+//   - env: comma separated list of the environment variables of kraken passed to the scripts.
+//     Besides the CGI variables, scripts only get PATH and the system library path by default.
+//   - env.NAME: sets the NAME environment variable of the scripts to the param value.
+//   - timeout: how long a script may run before it is killed and the request fails with
+//     503 Service Unavailable. Defaults to 30s.
+//   - max_concurrent: the maximum number of scripts running at the same time;
+//     other requests wait for one to finish, up to the timeout. Defaults to 8, 0 is unlimited.
+var Server fileserver.Constructor = func(root string, params fileserver.Params) (fileserver.Server, error) {
+	supervisor, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	s := &server{
+		root:       root,
+		supervisor: supervisor,
+		static:     http.FileServer(http.Dir(root)),
+		extensions: strings.Split(defaultExtensions, ","),
+	}
+	if v := params["extensions"]; v != "" {
+		s.extensions = nil
+		for _, ext := range strings.Split(v, ",") {
+			ext = strings.TrimSpace(ext)
+			if !strings.HasPrefix(ext, ".") || len(ext) < 2 {
+				return nil, fmt.Errorf("invalid extension %q", ext)
+			}
+			s.extensions = append(s.extensions, ext)
+		}
+	}
+	if v := params["env"]; v != "" {
+		for _, name := range strings.Split(v, ",") {
+			s.inheritEnv = append(s.inheritEnv, strings.TrimSpace(name))
+		}
+	}
+	for k, v := range params {
+		if strings.HasPrefix(k, envParamPrefix) {
+			s.env = append(s.env, k[len(envParamPrefix):]+"="+v)
+		}
+	}
+
+	timeout := defaultTimeout
+	if v := params["timeout"]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("timeout %s is not positive", d)
+		}
+		timeout = d
+	}
+	maxConcurrent := defaultMaxConcurrent
+	if v := params["max_concurrent"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("negative max_concurrent %d", n)
+		}
+		maxConcurrent = n
+	}
+	if maxConcurrent > 0 {
+		s.slots = make(chan struct{}, maxConcurrent)
+	}
+	s.scripts = http.TimeoutHandler(http.HandlerFunc(s.serveScript), timeout, "CGI script timed out")
+	return s, nil
+}
+
+// Params describes the params recognized by Server.
+var Params = fileserver.ParamSchema{
+	{Name: "extensions", Type: fileserver.ParamString, Default: defaultExtensions, Description: "Comma separated list of the extensions of the scripts."},
+	{Name: "env", Type: fileserver.ParamString, Description: "Comma separated list of the environment variables passed to the scripts."},
+	{Name: envParamPrefix, Prefix: true, Type: fileserver.ParamString, Description: "Environment variable set for the scripts, e.g env.DATABASE_URL."},
+	{Name: "timeout", Type: fileserver.ParamDuration, Default: defaultTimeout.String(), Description: "How long a script may run."},
+	{Name: "max_concurrent", Type: fileserver.ParamInt, Default: strconv.Itoa(defaultMaxConcurrent), Description: "Maximum number of scripts running at the same time; 0 is unlimited."},
+}
+
+type server struct {
+	root string
+	// supervisor is the executable running the scripts, see scriptEnv.
+	supervisor string
+	static     http.Handler
+	scripts    http.Handler
+	extensions []string
+	inheritEnv []string
+	env        []string
+	// slots limits the number of running scripts. It is nil if there's no limit.
+	slots chan struct{}
+}
+
+func (s *server) Root() string {
+	return s.root
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	script, _, ok := s.findScript(path.Clean("/" + r.URL.Path))
+	if !ok {
+		s.static.ServeHTTP(w, r)
+		return
+	}
+	fi, err := os.Stat(filepath.Join(s.root, filepath.FromSlash(script)))
+	if err != nil || fi.Mode()&0111 == 0 {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	s.scripts.ServeHTTP(w, r)
+}
+
+// serveScript runs the script of the request, once a slot is available.
+func (s *server) serveScript(w http.ResponseWriter, r *http.Request) {
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-r.Context().Done():
+			return
+		}
+	}
+	script, pathInfo, _ := s.findScript(path.Clean("/" + r.URL.Path))
+	// The request path is relative to the mount target: put it back,
+	// so that SCRIPT_NAME is the path of the script on the server.
+	_, target := kraken.WithMountTarget(r)
+	prefix := strings.TrimSuffix(*target, "/")
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = prefix + script + pathInfo
+
+	// The script is run by a copy of krakend, which kills it once the request times out.
+	var timeout time.Duration
+	if deadline, ok := r.Context().Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if timeout <= 0 {
+		return
+	}
+	scriptPath := filepath.Join(s.root, filepath.FromSlash(script))
+	logger := fileserver.Logger(r.Context()).With("script", scriptPath)
+	h := &cgi.Handler{
+		Path:       s.supervisor,
+		Root:       prefix + script,
+		Dir:        filepath.Dir(scriptPath),
+		InheritEnv: s.inheritEnv,
+		Env: append(s.env[:len(s.env):len(s.env)],
+			"SCRIPT_FILENAME="+scriptPath,
+			scriptEnv+"="+scriptPath,
+			timeoutEnv+"="+timeout.String(),
+			raceEnv,
+		),
+		Logger: slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		Stderr: stderrLogger{logger},
+	}
+	h.ServeHTTP(w, r2)
+}
+
+// stderrLogger logs the lines written to the stderr of a script.
+type stderrLogger struct {
+	logger *slog.Logger
+}
+
+func (sl stderrLogger) Write(b []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		sl.logger.Warn("CGI script error", "stderr", line)
+	}
+	return len(b), nil
+}
+
+// findScript looks for a script in the leading elements of name, a clean slash-separated path.
+// It returns the path of the script and the remaining path.
+func (s *server) findScript(name string) (script string, pathInfo string, ok bool) {
+	for i := 1; i <= len(name); i++ {
+		if i != len(name) && name[i] != '/' {
+			continue
+		}
+		if !s.isScript(name[:i]) {
+			continue
+		}
+		fi, err := os.Stat(filepath.Join(s.root, filepath.FromSlash(name[:i])))
+		if err != nil {
+			return "", "", false
+		}
+		if fi.Mode().IsRegular() {
+			return name[:i], name[i:], true
+		}
+	}
+	return "", "", false
+}
+
+func (s *server) isScript(name string) bool {
+	ext := path.Ext(name)
+	for _, e := range s.extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package cgi_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken"
+	"github.com/vincent-petithory/kraken/fileserver"
+	"github.com/vincent-petithory/kraken/fileserver/cgi"
+)
+
+func TestServer(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	root, err := ioutil.TempDir("", "cgi-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	files := []struct {
+		Name    string
+		Content string
+		Mode    os.FileMode
+	}{
+		{"hello.cgi", "#!/bin/sh\nprintf 'Content-Type: text/plain\\r\\n\\r\\n'\necho \"$REQUEST_METHOD $PATH_INFO $GREETING $KRAKEN_CGI_TEST_SECRET $HOME\"\n", 0755},
+		{"tools/report.sh", "#!/bin/sh\nprintf 'Status: 201 Created\\r\\nContent-Type: text/plain\\r\\n\\r\\n'\necho \"$SCRIPT_NAME $QUERY_STRING\"\n", 0755},
+		{"slow.cgi", "#!/bin/sh\nsleep 1\nprintf 'Content-Type: text/plain\\r\\n\\r\\n'\n", 0755},
+		{"source.cgi", "#!/bin/sh\necho secret\n", 0644},
+		{"static.txt", "static", 0644},
+	}
+	for _, f := range files {
+		p := filepath.Join(root, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(f.Content), f.Mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Setenv("KRAKEN_CGI_TEST_SECRET", "shared")
+	defer os.Unsetenv("KRAKEN_CGI_TEST_SECRET")
+
+	fs, err := cgi.Server(root, fileserver.Params{
+		"extensions":     ".cgi, .sh",
+		"env":            "KRAKEN_CGI_TEST_SECRET",
+		"env.GREETING":   "hi",
+		"timeout":        "200ms",
+		"max_concurrent": "2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		Method string
+		Path   string
+		Status int
+		Body   string
+	}{
+		{"GET", "/hello.cgi", http.StatusOK, "GET  hi shared \n"},
+		{"POST", "/hello.cgi/extra/path", http.StatusOK, "POST /extra/path hi shared \n"},
+		{"GET", "/tools/report.sh?q=1", http.StatusCreated, "/tools/report.sh q=1\n"},
+		{"GET", "/slow.cgi", http.StatusServiceUnavailable, "CGI script timed out"},
+		{"GET", "/source.cgi", http.StatusForbidden, "Forbidden\n"},
+		{"GET", "/static.txt", http.StatusOK, "static"},
+		{"GET", "/missing.cgi", http.StatusNotFound, "404 page not found\n"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(test.Method, test.Path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.ServeHTTP(w, r)
+		if w.Code != test.Status {
+			t.Errorf("%s %s: expected status %d, got %d", test.Method, test.Path, test.Status, w.Code)
+			continue
+		}
+		if body := w.Body.String(); body != test.Body {
+			t.Errorf("%s %s: expected body %q, got %q", test.Method, test.Path, test.Body, body)
+		}
+	}
+}
+
+func TestServerInvalidParams(t *testing.T) {
+	tests := []fileserver.Params{
+		{"extensions": "cgi"},
+		{"timeout": "0s"},
+		{"max_concurrent": "-1"},
+	}
+	for _, params := range tests {
+		if _, err := cgi.Server("/tmp", params); err == nil {
+			t.Errorf("%v: expected an error", params)
+		}
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	root, err := ioutil.TempDir("", "cgi-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for name, content := range map[string]string{
+		// sleep keeps the output of the script open once the script is killed.
+		"hang.cgi":  "#!/bin/sh\nsleep 10\nprintf 'Content-Type: text/plain\\r\\n\\r\\n'\n",
+		"hello.cgi": "#!/bin/sh\nprintf 'Content-Type: text/plain\\r\\n\\r\\n'\necho hello\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs, err := cgi.Server(root, fileserver.Params{
+		"timeout":        "500ms",
+		"max_concurrent": "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The script timing out is killed and its slot released,
+	// so that the next script gets it before timing out too.
+	start := time.Now()
+	for _, test := range []struct {
+		Path   string
+		Status int
+	}{
+		{"/hang.cgi", http.StatusServiceUnavailable},
+		{"/hello.cgi", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", test.Path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.ServeHTTP(w, r)
+		if w.Code != test.Status {
+			t.Errorf("%s: expected status %d, got %d", test.Path, test.Status, w.Code)
+		}
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected the hanging script to be killed, requests took %s", d)
+	}
+}
+
+func TestServerMount(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	root, err := ioutil.TempDir("", "cgi-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	script := "#!/bin/sh\necho oops >&2\nprintf 'Content-Type: text/plain\\r\\n\\r\\n'\necho \"$SCRIPT_NAME $PATH_INFO\"\n"
+	if err := ioutil.WriteFile(filepath.Join(root, "report.cgi"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fsf := make(fileserver.Factory)
+	if err := fsf.Register("cgi", cgi.Server); err != nil {
+		t.Fatal(err)
+	}
+	mountMap := kraken.NewMountMap(fsf)
+	if _, err := mountMap.Put("/tools", root, "cgi", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil)).With("port", 8080)
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/tools/report.cgi/2024", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The request was rewritten from another URI.
+	r.RequestURI = "/latest"
+	r = r.WithContext(fileserver.WithLogger(r.Context(), logger))
+	mountMap.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if body, expected := w.Body.String(), "/tools/report.cgi /2024\n"; body != expected {
+		t.Errorf("expected body %q, got %q", expected, body)
+	}
+	// The stderr of the script is logged.
+	for _, attr := range []string{"stderr=oops", "port=8080", "mount_id=" + kraken.MountID("/tools"), "script=" + filepath.Join(root, "report.cgi")} {
+		if !strings.Contains(buf.String(), attr) {
+			t.Errorf("expected the log to contain %q, got %q", attr, buf.String())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package cgi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// The scripts are run by net/http/cgi through a copy of the current executable,
+// which supervises them: net/http/cgi only kills the process it started once the
+// client is gone, so a script which hangs would hold its slot forever, and the
+// processes it started would outlive it.
+// The copy runs the script with these environment variables set, kills it with the
+// processes it started once the timeout is over, and exits.
+const (
+	// scriptEnv is the path of the script.
+	scriptEnv = "KRAKEN_CGI_SCRIPT"
+	// timeoutEnv is how long the script may run.
+	timeoutEnv = "KRAKEN_CGI_TIMEOUT"
+	// raceEnv is set so that a copy built with the race detector, e.g in tests,
+	// exits as soon as the script does.
+	raceEnv = "GORACE=atexit_sleep_ms=0"
+)
+
+func init() {
+	if script := os.Getenv(scriptEnv); script != "" {
+		os.Exit(supervise(script, os.Getenv(timeoutEnv)))
+	}
+}
+
+// supervise runs script with the stdin, stdout, stderr and environment of the process,
+// until it exits or timeout is over. It returns the exit code of the process.
+func supervise(script string, timeout string) int {
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Unsetenv(scriptEnv)
+	os.Unsetenv(timeoutEnv)
+	os.Unsetenv(strings.SplitN(raceEnv, "=", 2)[0])
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, script)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	killGroup(cmd)
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("killed after %s", d)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
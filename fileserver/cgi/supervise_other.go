@@ -0,0 +1,8 @@
+//go:build !unix
+
+package cgi
+
+import "os/exec"
+
+// killGroup does nothing: only the script is killed when cmd is canceled.
+func killGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package cgi
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killGroup makes cmd run in its own process group, which is killed when cmd is canceled,
+// so that the processes started by a script are killed with it.
+func killGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
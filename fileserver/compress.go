@@ -0,0 +1,270 @@
+package fileserver
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Params recognized by all file server types, see Factory.New.
+const (
+	// ParamCompress enables on-the-fly gzip compression of compressible responses.
+	ParamCompress = "compress"
+	// ParamPrecompressed enables serving foo.br and foo.gz sidecar files in place of foo,
+	// when the client accepts the encoding and the server would serve both files,
+	// see FileSystemServer.
+	ParamPrecompressed = "precompressed"
+)
+
+// minCompressSize is the size under which responses are not worth compressing.
+const minCompressSize = 256
+
+// compressServer wraps a Server to compress its responses.
+type compressServer struct {
+	Server
+	gzip bool
+	// files opens the files and their sidecars; nil if sidecars are not served.
+	files http.FileSystem
+}
+
+// withCompression wraps fs according to the ParamCompress and ParamPrecompressed params.
+// base is the server wrapped by fs, if any, which opens the sidecar files if it is a FileSystemServer.
+// fs is returned as is if neither param is enabled.
+func withCompression(fs Server, base Server, params Params) Server {
+	gz, _ := strconv.ParseBool(params[ParamCompress])
+	pre, _ := strconv.ParseBool(params[ParamPrecompressed])
+	if !gz && !pre {
+		return fs
+	}
+	cs := &compressServer{
+		Server: fs,
+		gzip:   gz,
+	}
+	if fss, ok := base.(FileSystemServer); ok && pre {
+		cs.files = fss
+	}
+	return cs
+}
+
+// sidecar describes a precompressed version of a file.
+type sidecar struct {
+	ext      string
+	encoding string
+}
+
+// sidecars lists the supported precompressed files, by order of preference.
+var sidecars = []sidecar{
+	{".br", "br"},
+	{".gz", "gzip"},
+}
+
+func (cs *compressServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Whether the response is compressed depends on the Accept-Encoding header,
+	// so caches must not serve it to clients accepting other encodings.
+	w.Header().Add("Vary", "Accept-Encoding")
+	// Range requests are always served from the uncompressed file,
+	// so that byte ranges apply to the content as it is stored.
+	if r.Header.Get("Range") != "" {
+		cs.Server.ServeHTTP(w, r)
+		return
+	}
+	if cs.files != nil && cs.serveSidecar(w, r) {
+		return
+	}
+	if cs.gzip && acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip") {
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.Close()
+		cs.Server.ServeHTTP(gw, r)
+		return
+	}
+	cs.Server.ServeHTTP(w, r)
+}
+
+// serveSidecar serves a precompressed sidecar file of the requested file, if there is one
+// accepted by the client and up to date.
+// Requests with a query are left to the server, since it may not serve the file
+// for them, e.g a rendered page or a thumbnail.
+// It returns false if no sidecar was served.
+func (cs *compressServer) serveSidecar(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	if r.URL.RawQuery != "" {
+		return false
+	}
+	if strings.HasSuffix(r.URL.Path, "/") {
+		return false
+	}
+	// The file is looked up too, so that its sidecars are served only if it would be.
+	name := path.Clean("/" + r.URL.Path)
+	fi, err := stat(cs.files, name)
+	if err != nil || fi.IsDir() {
+		return false
+	}
+	ae := r.Header.Get("Accept-Encoding")
+	for _, sc := range sidecars {
+		if !acceptsEncoding(ae, sc.encoding) {
+			continue
+		}
+		f, err := cs.files.Open(name + sc.ext)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		sfi, err := f.Stat()
+		// A sidecar older than its file is stale.
+		if err != nil || sfi.IsDir() || sfi.ModTime().Before(fi.ModTime()) {
+			continue
+		}
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", ctype)
+		w.Header().Set("Content-Encoding", sc.encoding)
+		http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+		return true
+	}
+	return false
+}
+
+// stat returns the info of the file at name in fs.
+func stat(fs http.FileSystem, name string) (os.FileInfo, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header value ae accepts encoding.
+func acceptsEncoding(ae string, encoding string) bool {
+	for _, part := range strings.Split(ae, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.TrimSpace(fields[0])
+		if coding != encoding && coding != "*" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		return q > 0
+	}
+	return false
+}
+
+// isCompressible reports whether the content type ctype is worth compressing.
+func isCompressible(ctype string) bool {
+	mt, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mt, "text/"),
+		strings.HasSuffix(mt, "+json"),
+		strings.HasSuffix(mt, "+xml"):
+		return true
+	}
+	switch mt {
+	case "application/json",
+		"application/javascript",
+		"application/x-javascript",
+		"application/xml",
+		"application/wasm",
+		"image/svg+xml",
+		"image/x-icon":
+		return true
+	}
+	return false
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// gzipResponseWriter compresses the response body with gzip,
+// if it is compressible and not already encoded.
+// The decision is made when the header is written.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (gw *gzipResponseWriter) WriteHeader(status int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	h := gw.Header()
+	if status == http.StatusOK &&
+		h.Get("Content-Encoding") == "" &&
+		h.Get("Content-Range") == "" &&
+		isCompressible(h.Get("Content-Type")) {
+		if cl, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err != nil || cl >= minCompressSize {
+			h.Del("Content-Length")
+			h.Set("Content-Encoding", "gzip")
+			if etag := h.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("Etag", "W/"+etag)
+			}
+			gw.gz = gzipWriterPool.Get().(*gzip.Writer)
+			gw.gz.Reset(gw.ResponseWriter)
+		}
+	}
+	gw.ResponseWriter.WriteHeader(status)
+}
+
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !gw.wroteHeader {
+		if gw.Header().Get("Content-Type") == "" {
+			gw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.gz != nil {
+		return gw.gz.Write(b)
+	}
+	return gw.ResponseWriter.Write(b)
+}
+
+// Flush flushes the compressed data written so far to the client.
+func (gw *gzipResponseWriter) Flush() {
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for use by http.ResponseController.
+func (gw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+// Close terminates the gzip stream, if any.
+func (gw *gzipResponseWriter) Close() error {
+	if gw.gz == nil {
+		return nil
+	}
+	err := gw.gz.Close()
+	gw.gz.Reset(io.Discard)
+	gzipWriterPool.Put(gw.gz)
+	gw.gz = nil
+	return err
+}
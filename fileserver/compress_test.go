@@ -0,0 +1,158 @@
+package fileserver_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+)
+
+func TestCompression(t *testing.T) {
+	root, err := ioutil.TempDir("", "fileserver-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	content := strings.Repeat("console.log('kraken');\n", 100)
+	files := map[string]string{
+		"app.js":       content,
+		"app.js.br":    "brotli",
+		"style.css":    content,
+		"style.css.gz": "gzip",
+		"stale.css":    content,
+		"stale.css.gz": "stale",
+		"image.png":    content,
+		"README.md":    "# kraken\n",
+		"README.md.gz": "readme",
+	}
+	// Sidecars are up to date if they are not older than their file.
+	now := time.Now().Truncate(time.Second)
+	for name, data := range files {
+		p := filepath.Join(root, name)
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now
+		if name == "stale.css.gz" {
+			mtime = now.Add(-time.Hour)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Sidecars must not expose files the server doesn't serve.
+	for name, data := range map[string]string{
+		".env":        "SECRET=1",
+		".env.gz":     "secret",
+		"secret.txt":  content,
+		"link.txt.gz": "linked",
+	} {
+		p := filepath.Join(root, name)
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, now, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	fsf := make(fileserver.Factory)
+	if err := fsf.RegisterType("beachplug", fileserver.Type{New: beachplug.Server, Params: beachplug.Params}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		typ      string
+		params   fileserver.Params
+		path     string
+		header   http.Header
+		status   int
+		encoding string
+		body     string
+	}{
+		// Disabled by default
+		{"", nil, "/app.js", http.Header{"Accept-Encoding": {"gzip, br"}}, 200, "", content},
+		// Sidecars
+		{"", fileserver.Params{"precompressed": "true"}, "/app.js", http.Header{"Accept-Encoding": {"gzip, br"}}, 200, "br", "brotli"},
+		{"", fileserver.Params{"precompressed": "true"}, "/app.js", http.Header{"Accept-Encoding": {"gzip"}}, 200, "", content},
+		{"", fileserver.Params{"precompressed": "true"}, "/style.css", http.Header{"Accept-Encoding": {"gzip, br"}}, 200, "gzip", "gzip"},
+		{"", fileserver.Params{"precompressed": "true"}, "/style.css", http.Header{"Accept-Encoding": {"gzip;q=0"}}, 200, "", content},
+		{"", fileserver.Params{"precompressed": "true"}, "/stale.css", http.Header{"Accept-Encoding": {"gzip"}}, 200, "", content},
+		{"beachplug", fileserver.Params{"precompressed": "true"}, "/style.css", http.Header{"Accept-Encoding": {"gzip"}}, 200, "gzip", "gzip"},
+		// Requests with a query are served by the server.
+		{"", fileserver.Params{"precompressed": "true"}, "/style.css?v=1", http.Header{"Accept-Encoding": {"gzip"}}, 200, "", content},
+		{"beachplug", fileserver.Params{"precompressed": "true"}, "/README.md", http.Header{"Accept-Encoding": {"gzip"}}, 200, "gzip", "readme"},
+		{"beachplug", fileserver.Params{"precompressed": "true"}, "/README.md?render=1", http.Header{"Accept-Encoding": {"gzip"}}, 200, "", "<h1>kraken</h1>"},
+		{"beachplug", fileserver.Params{"precompressed": "true"}, "/.env", http.Header{"Accept-Encoding": {"gzip"}}, 200, "gzip", "secret"},
+		{"beachplug", fileserver.Params{"precompressed": "true", "hidden": "false"}, "/.env", http.Header{"Accept-Encoding": {"gzip"}}, 404, "", "404 page not found\n"},
+		{"beachplug", fileserver.Params{"precompressed": "true"}, "/link.txt", http.Header{"Accept-Encoding": {"gzip"}}, 200, "gzip", "linked"},
+		{"beachplug", fileserver.Params{"precompressed": "true", "symlinks": "false"}, "/link.txt", http.Header{"Accept-Encoding": {"gzip"}}, 404, "", "404 page not found\n"},
+		// On the fly
+		{"", fileserver.Params{"compress": "true"}, "/app.js", http.Header{"Accept-Encoding": {"gzip"}}, 200, "gzip", content},
+		{"", fileserver.Params{"compress": "true"}, "/app.js", http.Header{}, 200, "", content},
+		{"", fileserver.Params{"compress": "true"}, "/image.png", http.Header{"Accept-Encoding": {"gzip"}}, 200, "", content},
+		{"", fileserver.Params{"compress": "true"}, "/app.js", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-6"}}, 206, "", content[:7]},
+	}
+	for i, test := range tests {
+		fs, err := fsf.New(root, test.typ, test.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header = test.header
+		fs.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%d: expected status %d, got %d", i, test.status, w.Code)
+			continue
+		}
+		if enc := w.Header().Get("Content-Encoding"); enc != test.encoding {
+			t.Errorf("%d: expected encoding %q, got %q", i, test.encoding, enc)
+			continue
+		}
+		// Responses vary by encoding whenever compression is enabled.
+		vary := []string{"Accept-Encoding"}
+		if test.params == nil {
+			vary = nil
+		}
+		if !reflect.DeepEqual(w.Header()["Vary"], vary) {
+			t.Errorf("%d: expected Vary %q, got %q", i, vary, w.Header()["Vary"])
+		}
+		body := w.Body.String()
+		if test.encoding == "gzip" && test.body == content {
+			gr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Errorf("%d: %v", i, err)
+				continue
+			}
+			b, err := ioutil.ReadAll(gr)
+			if err != nil {
+				t.Errorf("%d: %v", i, err)
+				continue
+			}
+			body = string(b)
+		}
+		// Rendered pages are only checked to hold the rendered file.
+		if strings.HasPrefix(test.body, "<") && strings.Contains(body, test.body) {
+			continue
+		}
+		if body != test.body {
+			t.Errorf("%d: unexpected body %.20q", i, body)
+		}
+	}
+}
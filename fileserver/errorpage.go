@@ -0,0 +1,303 @@
+package fileserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+)
+
+// ParamErrorPagePrefix prefixes the params setting a custom error page of a mount,
+// e.g error_page.404 or error_page.5xx. See NewErrorPages.
+const ParamErrorPagePrefix = "error_page."
+
+// maxErrorMessageSize is the maximum size of the original error body
+// made available to error pages.
+const maxErrorMessageSize = 1024
+
+// ErrorPages replaces the body of error responses with custom pages.
+type ErrorPages struct {
+	files map[string]string
+	pages map[string]*errorPage
+}
+
+type errorPage struct {
+	tpl interface {
+		Execute(io.Writer, interface{}) error
+	}
+	ctype string
+}
+
+// errorPageCtx is the data available to error page templates.
+type errorPageCtx struct {
+	// Status is the status code of the response, e.g 404.
+	Status int
+	// StatusText is the text of the status code, e.g Not Found.
+	StatusText string
+	// Path is the requested path.
+	Path string
+	// Message is the error message of the original response, if any.
+	Message string
+}
+
+// NewErrorPages returns error pages rendering the templates in files.
+//
+// The keys of files are either status codes, e.g 404, or classes of status codes,
+// e.g 5xx; only 4xx and 5xx statuses can have a custom page.
+// A status code takes precedence over its class.
+// The values are the absolute paths of the templates; .html files are html/template
+// templates, and others are text/template templates, where the json func encodes
+// a value as JSON. The content type of the page is guessed from the file extension.
+func NewErrorPages(files map[string]string) (*ErrorPages, error) {
+	ep := &ErrorPages{
+		files: make(map[string]string),
+		pages: make(map[string]*errorPage),
+	}
+	for key, file := range files {
+		if !validErrorPageKey(key) {
+			return nil, fmt.Errorf("invalid error page status %q", key)
+		}
+		if !filepath.IsAbs(file) {
+			return nil, fmt.Errorf("%s: error page path is not absolute", file)
+		}
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		page := &errorPage{
+			ctype: mime.TypeByExtension(filepath.Ext(file)),
+		}
+		if page.ctype == "" {
+			page.ctype = "text/plain; charset=utf-8"
+		}
+		if strings.EqualFold(filepath.Ext(file), ".html") {
+			page.tpl, err = htmltemplate.New(key).Parse(string(b))
+		} else {
+			page.tpl, err = texttemplate.New(key).Funcs(texttemplate.FuncMap{"json": toJSON}).Parse(string(b))
+		}
+		if err != nil {
+			return nil, err
+		}
+		ep.files[key] = file
+		ep.pages[key] = page
+	}
+	return ep, nil
+}
+
+// Files returns the paths of the templates of the pages, by status.
+func (ep *ErrorPages) Files() map[string]string {
+	files := make(map[string]string)
+	if ep == nil {
+		return files
+	}
+	for k, v := range ep.files {
+		files[k] = v
+	}
+	return files
+}
+
+func validErrorPageKey(key string) bool {
+	if len(key) != 3 || (key[0] != '4' && key[0] != '5') {
+		return false
+	}
+	if key[1:] == "xx" {
+		return true
+	}
+	_, err := strconv.Atoi(key[1:])
+	return err == nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// lookup returns the error page for status, or nil if there is none.
+// A nil ErrorPages has no pages.
+func (ep *ErrorPages) lookup(status int) *errorPage {
+	if ep == nil || status < 400 || status > 599 {
+		return nil
+	}
+	if page, ok := ep.pages[strconv.Itoa(status)]; ok {
+		return page
+	}
+	return ep.pages[strconv.Itoa(status/100)+"xx"]
+}
+
+type errorPagesKey struct{}
+
+// errorPagesState holds the error pages applying to a request, by order of precedence.
+type errorPagesState struct {
+	pages []*ErrorPages
+	path  string
+}
+
+func (st *errorPagesState) lookup(status int) *errorPage {
+	for _, ep := range st.pages {
+		if page := ep.lookup(status); page != nil {
+			return page
+		}
+	}
+	return nil
+}
+
+// Handler returns a handler replacing the body of the error responses of h with
+// the custom pages.
+//
+// When nested, e.g with pages for a server and pages for one of its mounts,
+// the innermost pages take precedence, and the outermost handler renders them.
+func (ep *ErrorPages) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if st, ok := r.Context().Value(errorPagesKey{}).(*errorPagesState); ok {
+			st.pages = append([]*ErrorPages{ep}, st.pages...)
+			h.ServeHTTP(w, r)
+			return
+		}
+		st := &errorPagesState{
+			pages: []*ErrorPages{ep},
+			path:  r.URL.Path,
+		}
+		ew := &errorPageWriter{ResponseWriter: w, st: st, logger: Logger(r.Context())}
+		h.ServeHTTP(ew, r.WithContext(context.WithValue(r.Context(), errorPagesKey{}, st)))
+		ew.finish()
+	})
+}
+
+// errorPageWriter holds back error responses which have a custom page,
+// and renders the page once the response is complete.
+type errorPageWriter struct {
+	http.ResponseWriter
+	st          *errorPagesState
+	wroteHeader bool
+	page        *errorPage
+	status      int
+	msg         bytes.Buffer
+	logger      *slog.Logger
+}
+
+func (ew *errorPageWriter) WriteHeader(status int) {
+	if ew.wroteHeader {
+		return
+	}
+	ew.wroteHeader = true
+	if ew.page = ew.st.lookup(status); ew.page != nil {
+		ew.status = status
+		return
+	}
+	ew.ResponseWriter.WriteHeader(status)
+}
+
+func (ew *errorPageWriter) Write(b []byte) (int, error) {
+	if !ew.wroteHeader {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if ew.page == nil {
+		return ew.ResponseWriter.Write(b)
+	}
+	if n := maxErrorMessageSize - ew.msg.Len(); n > 0 {
+		if n > len(b) {
+			n = len(b)
+		}
+		ew.msg.Write(b[:n])
+	}
+	return len(b), nil
+}
+
+// Flush flushes the response, unless it is held back.
+func (ew *errorPageWriter) Flush() {
+	if ew.page != nil {
+		return
+	}
+	if f, ok := ew.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets connections be taken over, e.g proxied websockets.
+func (ew *errorPageWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := ew.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	return hj.Hijack()
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for use by http.ResponseController.
+func (ew *errorPageWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// finish renders the error page, if the response was held back.
+func (ew *errorPageWriter) finish() {
+	if ew.page == nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := ew.page.tpl.Execute(&buf, errorPageCtx{
+		Status:     ew.status,
+		StatusText: http.StatusText(ew.status),
+		Path:       ew.st.path,
+		Message:    strings.TrimSpace(ew.msg.String()),
+	}); err != nil {
+		ew.logger.Error("unable to render error page", "status", ew.status, "err", err)
+		http.Error(ew.ResponseWriter, http.StatusText(ew.status), ew.status)
+		return
+	}
+	h := ew.Header()
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	h.Set("Content-Type", ew.page.ctype)
+	ew.ResponseWriter.WriteHeader(ew.status)
+	ew.ResponseWriter.Write(buf.Bytes())
+}
+
+// errorPageFiles returns the error pages set by the ParamErrorPagePrefix params.
+func errorPageFiles(params Params) map[string]string {
+	files := make(map[string]string)
+	for k, v := range params {
+		if strings.HasPrefix(k, ParamErrorPagePrefix) {
+			files[k[len(ParamErrorPagePrefix):]] = v
+		}
+	}
+	return files
+}
+
+// errorPagesServer wraps a Server to render the custom error pages of its mount.
+type errorPagesServer struct {
+	Server
+	h http.Handler
+}
+
+func (es *errorPagesServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	es.h.ServeHTTP(w, r)
+}
+
+// withErrorPages wraps fs according to the ParamErrorPagePrefix params.
+// fs is returned as is if there are none.
+func withErrorPages(fs Server, params Params) (Server, error) {
+	files := errorPageFiles(params)
+	if len(files) == 0 {
+		return fs, nil
+	}
+	ep, err := NewErrorPages(files)
+	if err != nil {
+		return nil, err
+	}
+	return &errorPagesServer{
+		Server: fs,
+		h:      ep.Handler(fs),
+	}, nil
+}
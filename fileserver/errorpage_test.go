@@ -0,0 +1,120 @@
+package fileserver_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+type failingServer struct {
+	http.Handler
+	root string
+}
+
+func (fs *failingServer) Root() string {
+	return fs.root
+}
+
+func TestErrorPages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileserver-errorpages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pages := map[string]string{
+		"404.html":   `<h1>{{.Status}} {{.StatusText}}</h1><p>{{.Path}}</p>`,
+		"mount.html": `<h1>mount {{.Status}}</h1>`,
+		"5xx.json":   `{"status":{{.Status}},"error":{{json .Message}}}`,
+	}
+	for name, body := range pages {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "root"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	srvPages, err := fileserver.NewErrorPages(map[string]string{
+		"404": filepath.Join(dir, "404.html"),
+		"5xx": filepath.Join(dir, "5xx.json"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsf := make(fileserver.Factory)
+	if err := fsf.Register("failing", func(root string, params fileserver.Params) (fileserver.Server, error) {
+		h := http.NewServeMux()
+		h.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `disk "full"`, http.StatusServiceUnavailable)
+		})
+		h.HandleFunc("/forbidden", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+		})
+		h.Handle("/", http.FileServer(http.Dir(root)))
+		return &failingServer{Handler: h, root: root}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := fsf.New(filepath.Join(dir, "root"), "failing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withMountPages, err := fsf.New(filepath.Join(dir, "root"), "failing", fileserver.Params{
+		"error_page.404": filepath.Join(dir, "mount.html"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Handler     http.Handler
+		Path        string
+		Status      int
+		ContentType string
+		Body        string
+	}{
+		{srvPages.Handler(plain), "/missing", http.StatusNotFound, "text/html; charset=utf-8", "<h1>404 Not Found</h1><p>/missing</p>"},
+		{srvPages.Handler(plain), "/boom", http.StatusServiceUnavailable, "application/json", `{"status":503,"error":"disk \"full\""}`},
+		{srvPages.Handler(plain), "/forbidden", http.StatusForbidden, "text/plain; charset=utf-8", "forbidden\n"},
+		{srvPages.Handler(withMountPages), "/missing", http.StatusNotFound, "text/html; charset=utf-8", "<h1>mount 404</h1>"},
+		{srvPages.Handler(withMountPages), "/boom", http.StatusServiceUnavailable, "application/json", `{"status":503,"error":"disk \"full\""}`},
+		{withMountPages, "/missing", http.StatusNotFound, "text/html; charset=utf-8", "<h1>mount 404</h1>"},
+		{withMountPages, "/boom", http.StatusServiceUnavailable, "text/plain; charset=utf-8", "disk \"full\"\n"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", test.Path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Handler.ServeHTTP(w, r)
+		if w.Code != test.Status {
+			t.Errorf("%s: expected status %d, got %d", test.Path, test.Status, w.Code)
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != test.ContentType {
+			t.Errorf("%s: expected content type %q, got %q", test.Path, test.ContentType, ct)
+		}
+		if w.Body.String() != test.Body {
+			t.Errorf("%s: expected body %q, got %q", test.Path, test.Body, w.Body.String())
+		}
+	}
+
+	for _, files := range []map[string]string{
+		{"200": filepath.Join(dir, "404.html")},
+		{"4x": filepath.Join(dir, "404.html")},
+		{"404": "404.html"},
+		{"404": filepath.Join(dir, "missing.html")},
+	} {
+		if _, err := fileserver.NewErrorPages(files); err == nil {
+			t.Errorf("expected error pages %v to be invalid", files)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 )
 
 type Server interface {
@@ -11,30 +13,141 @@ type Server interface {
 	Root() string
 }
 
+// FileSystemServer is a Server serving the files of a http.FileSystem.
+// It lets wrappers, like the one serving precompressed files, look up files
+// with the rules of the server.
+type FileSystemServer interface {
+	Server
+	// Open opens the file at name, if the server serves it,
+	// e.g it fails for hidden files if the server doesn't serve them.
+	Open(name string) (http.File, error)
+}
+
 type Params map[string]string
 
-type Factory map[string]Constructor
+// Type describes a registered file server type.
+type Type struct {
+	New Constructor
+	// CheckSource checks a mount source is valid for this type.
+	// If nil, CheckDir is used.
+	CheckSource SourceChecker
+	// CheckParams checks the params of a mount are valid for this type,
+	// e.g that the templates they refer to parse. It may be nil.
+	CheckParams ParamsChecker
+	// Params describes the params recognized by this type,
+	// in addition to CommonParams.
+	Params ParamSchema
+}
+
+type Factory map[string]Type
+
+// ErrUnknownType is returned when a file server type is not registered.
+var ErrUnknownType = errors.New("fileserver: unknown type")
+
+// lookup returns the type typ. The empty name is the default type.
+func (f Factory) lookup(typ string) (Type, error) {
+	if typ == "" || typ == "default" {
+		return defaultType, nil
+	}
+	t, ok := f[typ]
+	if !ok {
+		return Type{}, fmt.Errorf("%w %q", ErrUnknownType, typ)
+	}
+	return t, nil
+}
 
-func (f Factory) New(root string, typ string, params Params) Server {
-	for typeName, constructor := range f {
-		if typ == typeName {
-			return constructor(root, params)
-		}
+// New creates a file server of type typ, serving root.
+// The empty type is the default file server.
+// The params are checked by CheckParams first.
+//
+// Besides the params of each type, the following params are recognized for all types:
+//
+//   - compress: if true, compressible responses are gzipped on the fly.
+//   - precompressed: if true, foo.br or foo.gz are served in place of foo
+//     when they exist and the client accepts them. Only the types whose servers
+//     implement FileSystemServer serve them.
+//   - error_page.STATUS: the path of a custom error page for STATUS, e.g 404 or 5xx.
+//     See NewErrorPages.
+func (f Factory) New(root string, typ string, params Params) (Server, error) {
+	t, err := f.lookup(typ)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.CheckParams(typ, params); err != nil {
+		return nil, err
+	}
+	fs, err := t.New(root, params)
+	if err != nil {
+		return nil, err
+	}
+	base := fs
+	if fs, err = withErrorPages(fs, params); err != nil {
+		return nil, err
 	}
-	return defaultConstructor(root, params)
+	return withCompression(fs, base, params), nil
 }
 
+// ParamSchema returns the schema of the params recognized by the type typ,
+// CommonParams included.
+func (f Factory) ParamSchema(typ string) (ParamSchema, error) {
+	t, err := f.lookup(typ)
+	if err != nil {
+		return nil, err
+	}
+	schema := make(ParamSchema, 0, len(CommonParams)+len(t.Params))
+	schema = append(schema, CommonParams...)
+	return append(schema, t.Params...), nil
+}
+
+// CheckParams checks params are valid for the type typ: they are validated against
+// the schema of the type, see ParamSchema, then checked by its params checker, if any.
+func (f Factory) CheckParams(typ string, params Params) error {
+	t, err := f.lookup(typ)
+	if err != nil {
+		return err
+	}
+	schema, _ := f.ParamSchema(typ)
+	if err := schema.Validate(params); err != nil {
+		return err
+	}
+	if _, err := NewErrorPages(errorPageFiles(params)); err != nil {
+		return err
+	}
+	if t.CheckParams != nil {
+		return t.CheckParams(params)
+	}
+	return nil
+}
+
+// CheckSource checks source is a valid mount source for the type typ.
+func (f Factory) CheckSource(typ string, source string) error {
+	t, err := f.lookup(typ)
+	if err != nil {
+		return err
+	}
+	if t.CheckSource != nil {
+		return t.CheckSource(source)
+	}
+	return CheckDir(source)
+}
+
+// Register registers a file server type serving a directory.
 func (f Factory) Register(name string, constructor Constructor) error {
+	return f.RegisterType(name, Type{New: constructor})
+}
+
+// RegisterType registers a file server type.
+func (f Factory) RegisterType(name string, t Type) error {
 	if name == "" {
 		return errors.New("fileserver: name is empty")
 	}
-	if constructor == nil {
+	if t.New == nil {
 		return errors.New("fileserver: constructor is nil")
 	}
 	if _, ok := f[name]; ok || name == "default" {
 		return fmt.Errorf("fileserver: type %q is registered", name)
 	}
-	f[name] = constructor
+	f[name] = t
 	return nil
 }
 
@@ -56,11 +169,59 @@ func (fs defaultServer) Root() string {
 	return fs.root
 }
 
-var defaultConstructor Constructor = func(root string, params Params) Server {
+func (fs defaultServer) Open(name string) (http.File, error) {
+	return http.Dir(fs.root).Open(name)
+}
+
+var defaultConstructor Constructor = func(root string, params Params) (Server, error) {
 	return &defaultServer{
 		Handler: http.FileServer(http.Dir(root)),
 		root:    root,
+	}, nil
+}
+
+var defaultType = Type{New: defaultConstructor}
+
+// Constructor is the type of the funcs creating a file server serving root.
+// It returns an error if params are invalid.
+type Constructor func(root string, params Params) (Server, error)
+
+// SourceChecker is the type of the funcs checking a mount source.
+type SourceChecker func(source string) error
+
+// ParamsChecker is the type of the funcs checking the params of a mount.
+type ParamsChecker func(params Params) error
+
+// ErrInvalidSource is returned by a SourceChecker when a source has an invalid form,
+// e.g a relative path.
+var ErrInvalidSource = errors.New("fileserver: invalid source")
+
+// CheckDir checks source is the absolute path of an existing directory.
+func CheckDir(source string) error {
+	if !filepath.IsAbs(source) {
+		return ErrInvalidSource
 	}
+	fi, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s: not a directory", source)
+	}
+	return nil
 }
 
-type Constructor func(root string, params Params) Server
+// CheckFile checks source is the absolute path of an existing regular file.
+func CheckFile(source string) error {
+	if !filepath.IsAbs(source) {
+		return ErrInvalidSource
+	}
+	fi, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s: not a regular file", source)
+	}
+	return nil
+}
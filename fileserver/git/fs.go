@@ -0,0 +1,221 @@
+package git
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+)
+
+// fileSystem is a beachplug.SnapshotFileSystem serving the tree of a ref of a git repository.
+//
+// The ref is resolved again when the tree is older than refresh;
+// the tree is listed again only if the ref moved.
+type fileSystem struct {
+	repo    repo
+	ref     string
+	refresh time.Duration
+
+	mu         sync.Mutex
+	tree       *tree
+	resolvedAt time.Time
+}
+
+// currentTree returns the tree of the commit ref points to.
+// The git commands are not run with fs.mu held, so that concurrent requests don't wait for each other.
+func (fs *fileSystem) currentTree() (*tree, error) {
+	fs.mu.Lock()
+	t, resolvedAt := fs.tree, fs.resolvedAt
+	fs.mu.Unlock()
+	if t != nil && fs.refresh > 0 && time.Since(resolvedAt) < fs.refresh {
+		return t, nil
+	}
+	now := time.Now()
+	commit, modTime, err := fs.repo.resolve(fs.ref)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.commit != commit {
+		if t, err = fs.repo.readTree(commit, modTime); err != nil {
+			return nil, err
+		}
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	// Don't replace the tree of a more recent resolution.
+	if now.After(fs.resolvedAt) {
+		fs.tree, fs.resolvedAt = t, now
+	}
+	return t, nil
+}
+
+// Snapshot returns the file system of the tree of the commit ref points to.
+func (fs *fileSystem) Snapshot() (beachplug.FileSystem, error) {
+	t, err := fs.currentTree()
+	if err != nil {
+		return nil, err
+	}
+	return treeFileSystem{repo: fs.repo, tree: t}, nil
+}
+
+func (fs *fileSystem) Open(name string) (http.File, error) {
+	tfs, err := fs.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return tfs.Open(name)
+}
+
+func (fs *fileSystem) Lstat(name string) (os.FileInfo, error) {
+	tfs, err := fs.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return tfs.Lstat(name)
+}
+
+// treeFileSystem is a beachplug.FileSystem serving the tree of a commit.
+type treeFileSystem struct {
+	repo repo
+	tree *tree
+}
+
+func (fs treeFileSystem) Open(name string) (http.File, error) {
+	p := path.Clean("/" + name)
+	e, ok := fs.tree.entries[p]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return &file{repo: fs.repo, tree: fs.tree, path: p, e: e}, nil
+}
+
+// Lstat returns the info of the entry at name.
+// Trees are listed without their symbolic links, so it is never a link.
+func (fs treeFileSystem) Lstat(name string) (os.FileInfo, error) {
+	e, ok := fs.tree.entries[path.Clean("/"+name)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return entryInfo{e, fs.tree.modTime}, nil
+}
+
+// file is a http.File reading a git tree entry.
+//
+// The content of a blob is streamed from git on first read. Reads are sequential:
+// seeking elsewhere than the current read offset restarts the reading of the blob
+// and skips the data up to the new offset.
+type file struct {
+	repo repo
+	tree *tree
+	path string
+	e    *entry
+
+	pos  int64
+	r    io.ReadCloser
+	rpos int64
+
+	dirPos int
+}
+
+func (f *file) Read(b []byte) (int, error) {
+	if f.e.IsDir() {
+		return 0, &os.PathError{Op: "read", Path: f.path, Err: os.ErrInvalid}
+	}
+	if f.pos >= f.e.size {
+		return 0, io.EOF
+	}
+	if f.r != nil && f.rpos != f.pos {
+		f.r.Close()
+		f.r = nil
+	}
+	if f.r == nil {
+		r, err := f.repo.openBlob(f.e.object, f.pos)
+		if err != nil {
+			return 0, err
+		}
+		f.r, f.rpos = r, f.pos
+	}
+	n, err := f.r.Read(b)
+	f.pos += int64(n)
+	f.rpos += int64(n)
+	return n, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = f.pos + offset
+	case io.SeekEnd:
+		pos = f.e.size + offset
+	default:
+		return 0, os.ErrInvalid
+	}
+	if pos < 0 {
+		return 0, os.ErrInvalid
+	}
+	f.pos = pos
+	return pos, nil
+}
+
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.e.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: f.path, Err: os.ErrInvalid}
+	}
+	names := f.e.children[f.dirPos:]
+	if count > 0 {
+		if len(names) == 0 {
+			return nil, io.EOF
+		}
+		if len(names) > count {
+			names = names[:count]
+		}
+	}
+	fis := make([]os.FileInfo, 0, len(names))
+	for _, name := range names {
+		if e, ok := f.tree.entries[path.Join(f.path, name)]; ok {
+			fis = append(fis, entryInfo{e, f.tree.modTime})
+		}
+	}
+	f.dirPos += len(names)
+	sort.Sort(byName(fis))
+	return fis, nil
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	return entryInfo{f.e, f.tree.modTime}, nil
+}
+
+func (f *file) Close() error {
+	if f.r != nil {
+		return f.r.Close()
+	}
+	return nil
+}
+
+// entryInfo implements os.FileInfo for a git tree entry.
+// Its mod time is the time of the commit.
+type entryInfo struct {
+	e       *entry
+	modTime time.Time
+}
+
+func (fi entryInfo) Name() string       { return fi.e.name }
+func (fi entryInfo) Size() int64        { return fi.e.size }
+func (fi entryInfo) Mode() os.FileMode  { return fi.e.mode }
+func (fi entryInfo) ModTime() time.Time { return fi.modTime }
+func (fi entryInfo) IsDir() bool        { return fi.e.IsDir() }
+func (fi entryInfo) Sys() interface{}   { return nil }
+
+type byName []os.FileInfo
+
+func (l byName) Less(i int, j int) bool { return l[i].Name() < l[j].Name() }
+func (l byName) Swap(i int, j int)      { l[i], l[j] = l[j], l[i] }
+func (l byName) Len() int               { return len(l) }
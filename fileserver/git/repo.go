@@ -0,0 +1,187 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// repo runs git commands in a local repository.
+type repo struct {
+	dir string
+}
+
+// run runs git with args in the repository and returns its standard output.
+func (r repo) run(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %v: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+var errInvalidRef = errors.New("git: invalid ref")
+
+// resolve returns the commit ref points to, and its commit time.
+func (r repo) resolve(ref string) (string, time.Time, error) {
+	// Don't let a ref be taken for an option.
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return "", time.Time{}, errInvalidRef
+	}
+	out, err := r.run("log", "-1", "--format=%H %ct", ref+"^{commit}", "--")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return "", time.Time{}, fmt.Errorf("git log: unexpected output %q", out)
+	}
+	ct, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return fields[0], time.Unix(ct, 0), nil
+}
+
+// entry describes a file or a directory of a git tree.
+type entry struct {
+	name     string
+	size     int64
+	mode     os.FileMode
+	object   string
+	children []string
+}
+
+func (e *entry) IsDir() bool {
+	return e.mode.IsDir()
+}
+
+// tree holds the entries of the tree of a commit, by clean absolute path.
+type tree struct {
+	commit  string
+	modTime time.Time
+	entries map[string]*entry
+}
+
+// readTree lists the tree of commit, recursively.
+// Symbolic links and submodules are not served.
+func (r repo) readTree(commit string, modTime time.Time) (*tree, error) {
+	out, err := r.run("ls-tree", "-r", "-t", "-l", "-z", commit)
+	if err != nil {
+		return nil, err
+	}
+	t := &tree{
+		commit:  commit,
+		modTime: modTime,
+		entries: map[string]*entry{"/": {name: "/", mode: os.ModeDir | 0555}},
+	}
+	for _, line := range bytes.Split(out, []byte{0}) {
+		if len(line) == 0 {
+			continue
+		}
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		tab := bytes.IndexByte(line, '\t')
+		if tab < 0 {
+			return nil, fmt.Errorf("git ls-tree: unexpected output %q", line)
+		}
+		fields := strings.Fields(string(line[:tab]))
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("git ls-tree: unexpected output %q", line)
+		}
+		e := &entry{object: fields[2]}
+		switch fields[1] {
+		case "tree":
+			e.mode = os.ModeDir | 0555
+		case "blob":
+			if fields[0] == "120000" {
+				continue
+			}
+			e.mode = 0444
+			if fields[0] == "100755" {
+				e.mode = 0555
+			}
+			if e.size, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+		p := path.Clean("/" + string(line[tab+1:]))
+		e.name = path.Base(p)
+		t.entries[p] = e
+		// ls-tree lists trees before their entries.
+		if parent, ok := t.entries[path.Dir(p)]; ok {
+			parent.children = append(parent.children, e.name)
+		}
+	}
+	return t, nil
+}
+
+// openBlob returns a reader of the content of the blob object, from offset.
+// The content is streamed from the output of git cat-file, which is stopped on Close.
+func (r repo) openBlob(object string, offset int64) (io.ReadCloser, error) {
+	br := &blobReader{cmd: exec.Command("git", "cat-file", "blob", object)}
+	br.cmd.Dir = r.dir
+	br.cmd.Stderr = &br.stderr
+	stdout, err := br.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := br.cmd.Start(); err != nil {
+		return nil, err
+	}
+	br.stdout = stdout
+	if _, err := io.CopyN(io.Discard, br, offset); err != nil {
+		br.Close()
+		return nil, err
+	}
+	return br, nil
+}
+
+// blobReader reads the standard output of a git cat-file command.
+type blobReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	done   bool
+}
+
+func (br *blobReader) Read(b []byte) (int, error) {
+	n, err := br.stdout.Read(b)
+	if err == io.EOF && !br.done {
+		br.done = true
+		if werr := br.cmd.Wait(); werr != nil {
+			if msg := strings.TrimSpace(br.stderr.String()); msg != "" {
+				return n, fmt.Errorf("git cat-file: %v: %s", werr, msg)
+			}
+			return n, fmt.Errorf("git cat-file: %v", werr)
+		}
+	}
+	return n, err
+}
+
+// Close stops the command if the blob was not read to its end.
+func (br *blobReader) Close() error {
+	if br.done {
+		return nil
+	}
+	br.done = true
+	br.stdout.Close()
+	br.cmd.Process.Kill()
+	br.cmd.Wait()
+	return nil
+}
@@ -0,0 +1,63 @@
+package git
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+)
+
+// NewServer returns the constructor of git servers, which serve read-only the tree of
+// a ref of a local git repository, listed with fsc. Nothing is checked out: files are
+// read from the object database with the git command.
+//
+// The mount source is the path of the repository (its work tree, or a bare repository).
+// Besides the params of fsc, the following params are recognized:
+//
+//   - ref: the branch, tag or commit to serve. Defaults to HEAD.
+//     It must exist when the server is created.
+//   - refresh: how long a resolved ref is reused before being resolved again,
+//     as parsed by time.ParseDuration. Defaults to 0, resolving it on each request.
+func NewServer(fsc beachplug.FileSystemConstructor) fileserver.Constructor {
+	return func(root string, params fileserver.Params) (fileserver.Server, error) {
+		fs := &fileSystem{
+			repo: repo{dir: root},
+			ref:  "HEAD",
+		}
+		if ref, ok := params["ref"]; ok && ref != "" {
+			if _, _, err := fs.repo.resolve(ref); err != nil {
+				return nil, fmt.Errorf("ref %s: %v", ref, err)
+			}
+			fs.ref = ref
+		}
+		if v := params["refresh"]; v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, err
+			}
+			if d < 0 {
+				return nil, fmt.Errorf("negative refresh %s", d)
+			}
+			fs.refresh = d
+		}
+		return fsc(root, fs, params)
+	}
+}
+
+// Params describes the params recognized by the git servers, besides the ones of fsc.
+var Params = fileserver.ParamSchema{
+	{Name: "ref", Type: fileserver.ParamString, Default: "HEAD", Description: "Branch, tag or commit to serve."},
+	{Name: "refresh", Type: fileserver.ParamDuration, Default: "0s", Description: "How long a resolved ref is reused before being resolved again."},
+}
+
+// CheckSource checks source is the absolute path of a git repository.
+func CheckSource(source string) error {
+	if err := fileserver.CheckDir(source); err != nil {
+		return err
+	}
+	if _, err := (repo{dir: source}).run("rev-parse", "--git-dir"); err != nil {
+		return fileserver.ErrInvalidSource
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package git_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+	"github.com/vincent-petithory/kraken/fileserver/git"
+)
+
+func TestServer(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir, err := ioutil.TempDir("", "git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=kraken", "-c", "user.email=kraken@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v: %s", args[0], err, out)
+		}
+	}
+	write := func(name string, body string) {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q")
+	run("checkout", "-q", "-b", "main")
+	write("README.md", "main readme")
+	write("docs/guide.txt", "main guide")
+	data := strings.Repeat("0123456789", 100000)
+	write("data.txt", data)
+	run("add", "-A")
+	run("commit", "-q", "-m", "first")
+	run("tag", "v1")
+	run("checkout", "-q", "-b", "draft")
+	write("docs/guide.txt", "draft guide")
+	run("add", "-A")
+	run("commit", "-q", "-m", "draft")
+	// The work tree is not what is served.
+	write("docs/guide.txt", "uncommitted guide")
+
+	if err := git.CheckSource(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := git.CheckSource(filepath.Join(dir, "docs")); err != nil {
+		t.Fatal(err)
+	}
+	if err := git.CheckSource(os.TempDir()); err == nil {
+		t.Error("expected a directory outside a repository to be rejected")
+	}
+
+	newServer := git.NewServer(beachplug.NewFileSystemServer(beachplug.Options{}))
+	get := func(ref string, urlPath string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", urlPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs, err := newServer(dir, map[string]string{"ref": ref})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.ServeHTTP(w, r)
+		return w
+	}
+	tests := []struct {
+		Ref, Path, Body string
+		Code            int
+	}{
+		{"main", "/docs/guide.txt", "main guide", http.StatusOK},
+		{"v1", "/docs/guide.txt", "main guide", http.StatusOK},
+		{"draft", "/docs/guide.txt", "draft guide", http.StatusOK},
+		{"", "/docs/guide.txt", "draft guide", http.StatusOK},
+		{"draft", "/missing", "", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		w := get(test.Ref, test.Path)
+		if w.Code != test.Code {
+			t.Errorf("%s:%s: expected status %d, got %d", test.Ref, test.Path, test.Code, w.Code)
+			continue
+		}
+		if test.Body != "" && w.Body.String() != test.Body {
+			t.Errorf("%s:%s: expected body %q, got %q", test.Ref, test.Path, test.Body, w.Body.String())
+		}
+	}
+	if w := get("main", "/docs/"); !strings.Contains(w.Body.String(), "guide.txt") {
+		t.Errorf("expected guide.txt in listing, got %d %q", w.Code, w.Body.String())
+	}
+	if w := get("main", "/data.txt"); w.Body.String() != data {
+		t.Errorf("expected data.txt to be served whole, got %d and %d bytes", w.Code, w.Body.Len())
+	}
+
+	// Blobs are streamed: a range skips up to its start.
+	fs, err := newServer(dir, map[string]string{"ref": "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rng := range []struct {
+		Header string
+		Body   string
+	}{
+		{"bytes=500000-500009", data[500000:500010]},
+		{"bytes=-5", data[len(data)-5:]},
+		{"bytes=3-5", data[3:6]},
+	} {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "/data.txt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Range", rng.Header)
+		fs.ServeHTTP(w, r)
+		if w.Code != http.StatusPartialContent || w.Body.String() != rng.Body {
+			t.Errorf("%s: expected %d %q, got %d %q", rng.Header, http.StatusPartialContent, rng.Body, w.Code, w.Body.String())
+		}
+	}
+
+	// Branches are resolved again on each request.
+	run("checkout", "-q", "--", ".")
+	run("checkout", "-q", "main")
+	write("docs/guide.txt", "updated guide")
+	run("commit", "-q", "-a", "-m", "update")
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/docs/guide.txt", nil)
+	fs.ServeHTTP(w, r)
+	if w.Body.String() != "updated guide" {
+		t.Errorf("expected moved branch to serve updated guide, got %d %q", w.Code, w.Body.String())
+	}
+}
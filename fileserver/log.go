@@ -0,0 +1,25 @@
+package fileserver
+
+import (
+	"context"
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx holding logger, with which the servers
+// log the errors of the requests having ctx as context.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger held by ctx, or slog.Default() if none.
+//
+// The requests dispatched by a kraken server to its mounts hold its logger,
+// with the port of the server and the ID of the mount as attributes.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,105 @@
+package fileserver
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParamType is the type of the value of a param.
+// Param values are always strings; the type tells how they are parsed.
+type ParamType string
+
+const (
+	// ParamString is any string.
+	ParamString ParamType = "string"
+	// ParamBool is parsed by strconv.ParseBool.
+	ParamBool ParamType = "bool"
+	// ParamInt is parsed by strconv.Atoi.
+	ParamInt ParamType = "int"
+	// ParamDuration is parsed by time.ParseDuration, e.g 5s.
+	ParamDuration ParamType = "duration"
+	// ParamRegexp is parsed by regexp.Compile.
+	ParamRegexp ParamType = "regexp"
+	// ParamPath is an absolute file path.
+	ParamPath ParamType = "path"
+)
+
+// ParamSpec describes a param recognized by a file server type.
+type ParamSpec struct {
+	// Name is the name of the param. If Prefix is true, it describes all the
+	// params starting with Name, e.g header. for header.X-Token.
+	Name   string    `json:"name"`
+	Prefix bool      `json:"prefix,omitempty"`
+	Type   ParamType `json:"type"`
+	// Default is the value used when the param is not set, if any.
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description"`
+}
+
+// check checks v is a valid value for the param.
+func (spec ParamSpec) check(v string) error {
+	var err error
+	switch spec.Type {
+	case ParamBool:
+		_, err = strconv.ParseBool(v)
+	case ParamInt:
+		_, err = strconv.Atoi(v)
+	case ParamDuration:
+		_, err = time.ParseDuration(v)
+	case ParamRegexp:
+		_, err = regexp.Compile(v)
+	case ParamPath:
+		if v != "" && !filepath.IsAbs(v) {
+			err = fmt.Errorf("%s is not an absolute path", v)
+		}
+	}
+	return err
+}
+
+// ParamSchema describes the params recognized by a file server type.
+type ParamSchema []ParamSpec
+
+// Lookup returns the spec of the param name, if it is recognized.
+func (s ParamSchema) Lookup(name string) (ParamSpec, bool) {
+	for _, spec := range s {
+		if spec.Prefix {
+			if strings.HasPrefix(name, spec.Name) && len(name) > len(spec.Name) {
+				return spec, true
+			}
+		} else if name == spec.Name {
+			return spec, true
+		}
+	}
+	return ParamSpec{}, false
+}
+
+// Validate checks all the params are recognized by the schema and have a valid value.
+func (s ParamSchema) Validate(params Params) error {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spec, ok := s.Lookup(name)
+		if !ok {
+			return fmt.Errorf("unknown param %q", name)
+		}
+		if err := spec.check(params[name]); err != nil {
+			return fmt.Errorf("invalid %s param %q: %v", spec.Type, name, err)
+		}
+	}
+	return nil
+}
+
+// CommonParams are the params recognized by all the file server types, see Factory.New.
+var CommonParams = ParamSchema{
+	{Name: ParamCompress, Type: ParamBool, Default: "false", Description: "Gzip compressible responses on the fly."},
+	{Name: ParamPrecompressed, Type: ParamBool, Default: "false", Description: "Serve foo.br or foo.gz in place of foo when they exist and the client accepts them."},
+	{Name: ParamErrorPagePrefix, Prefix: true, Type: ParamPath, Description: "Template of the error page for a status or class of statuses, e.g error_page.404 or error_page.5xx."},
+}
@@ -0,0 +1,103 @@
+package fileserver_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+func TestParamSchemaValidate(t *testing.T) {
+	schema := fileserver.ParamSchema{
+		{Name: "enabled", Type: fileserver.ParamBool},
+		{Name: "count", Type: fileserver.ParamInt},
+		{Name: "timeout", Type: fileserver.ParamDuration},
+		{Name: "pattern", Type: fileserver.ParamRegexp},
+		{Name: "file", Type: fileserver.ParamPath},
+		{Name: "header.", Prefix: true, Type: fileserver.ParamString},
+	}
+	tests := []struct {
+		Params fileserver.Params
+		Valid  bool
+	}{
+		{nil, true},
+		{fileserver.Params{"enabled": "true", "count": "3", "timeout": "5s", "pattern": "^/a", "file": "/srv/f"}, true},
+		{fileserver.Params{"header.X-Token": "secret"}, true},
+		{fileserver.Params{"header.": "secret"}, false},
+		{fileserver.Params{"enabled": "yes"}, false},
+		{fileserver.Params{"count": "3.5"}, false},
+		{fileserver.Params{"timeout": "5"}, false},
+		{fileserver.Params{"pattern": "("}, false},
+		{fileserver.Params{"file": "f"}, false},
+		{fileserver.Params{"unknown": "1"}, false},
+	}
+	for _, test := range tests {
+		err := schema.Validate(test.Params)
+		if test.Valid && err != nil {
+			t.Errorf("%v: %v", test.Params, err)
+		}
+		if !test.Valid && err == nil {
+			t.Errorf("%v: expected an error", test.Params)
+		}
+	}
+}
+
+func TestFactoryNew(t *testing.T) {
+	root, err := ioutil.TempDir("", "factory-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	fsf := make(fileserver.Factory)
+	if err := fsf.RegisterType("strict", fileserver.Type{
+		New: func(root string, params fileserver.Params) (fileserver.Server, error) {
+			if params["mode"] == "broken" {
+				return nil, errors.New("broken mode")
+			}
+			return fsf.New(root, "", nil)
+		},
+		Params: fileserver.ParamSchema{{Name: "mode", Type: fileserver.ParamString}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		Type   string
+		Params fileserver.Params
+		Valid  bool
+	}{
+		{"", nil, true},
+		{"default", fileserver.Params{"compress": "true"}, true},
+		{"strict", fileserver.Params{"mode": "fast", "precompressed": "false"}, true},
+		{"strict", fileserver.Params{"mode": "broken"}, false},
+		{"strict", fileserver.Params{"compress": "maybe"}, false},
+		{"strict", fileserver.Params{"error_page.404": "404.html"}, false},
+		{"default", fileserver.Params{"mode": "fast"}, false},
+		{"missing", nil, false},
+	}
+	for _, test := range tests {
+		_, err := fsf.New(root, test.Type, test.Params)
+		if test.Valid && err != nil {
+			t.Errorf("%s %v: %v", test.Type, test.Params, err)
+		}
+		if !test.Valid && err == nil {
+			t.Errorf("%s %v: expected an error", test.Type, test.Params)
+		}
+	}
+	if _, err := fsf.New(root, "missing", nil); !errors.Is(err, fileserver.ErrUnknownType) {
+		t.Errorf("expected ErrUnknownType, got %v", err)
+	}
+
+	schema, err := fsf.ParamSchema("strict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := schema.Lookup("error_page.5xx"); !ok {
+		t.Error("expected the common params in the schema")
+	}
+	if _, ok := schema.Lookup("mode"); !ok {
+		t.Error("expected the params of the type in the schema")
+	}
+}
@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+const (
+	// headerParamPrefix prefixes the params setting a header on the upstream request,
+	// e.g header.X-Forwarded-User.
+	headerParamPrefix = "header."
+	// responseHeaderParamPrefix prefixes the params setting a header on the response,
+	// e.g response_header.Access-Control-Allow-Origin.
+	responseHeaderParamPrefix = "response_header."
+
+	defaultDialTimeout = 10 * time.Second
+	defaultTimeout     = 60 * time.Second
+)
+
+// Server is the constructor of proxy servers, which forward requests to an upstream server.
+//
+// The mount source is the URL of the upstream server. The request path, relative to the
+// mount target, is appended to the path of the URL: with a source of
+// http://localhost:8080/api mounted on /api, /api/users is forwarded to
+// http://localhost:8080/api/users.
+// Websocket connections are passed through.
+//
+// The following params are recognized:
+//
+//   - strip_prefix: a prefix removed from the request path before it is forwarded.
+//   - rewrite_pattern, rewrite_replacement: the request path is rewritten by replacing
+//     the matches of the rewrite_pattern regular expression with rewrite_replacement,
+//     as done by regexp.Regexp.ReplaceAllString.
+//   - header.NAME: sets the NAME header of the upstream request to the param value.
+//     An empty value removes the header.
+//   - response_header.NAME: sets the NAME header of the response to the param value.
+//   - preserve_host: if true, the Host header of the request is forwarded
+//     instead of the host of the upstream URL. Defaults to false.
+//   - dial_timeout: the timeout of connecting to the upstream server. Defaults to 10s.
+//   - timeout: how long to wait for the response headers of the upstream server.
+//     Defaults to 60s.
+var Server fileserver.Constructor = func(root string, params fileserver.Params) (fileserver.Server, error) {
+	target, err := url.Parse(root)
+	if err != nil {
+		return nil, err
+	}
+	s := &server{
+		root:            root,
+		target:          target,
+		stripPrefix:     params["strip_prefix"],
+		headers:         make(map[string]string),
+		responseHeaders: make(map[string]string),
+	}
+	if params["rewrite_pattern"] != "" {
+		re, err := regexp.Compile(params["rewrite_pattern"])
+		if err != nil {
+			return nil, err
+		}
+		s.rewrite = re
+		s.replacement = params["rewrite_replacement"]
+	}
+	for k, v := range params {
+		switch {
+		case strings.HasPrefix(k, headerParamPrefix):
+			s.headers[http.CanonicalHeaderKey(k[len(headerParamPrefix):])] = v
+		case strings.HasPrefix(k, responseHeaderParamPrefix):
+			s.responseHeaders[http.CanonicalHeaderKey(k[len(responseHeaderParamPrefix):])] = v
+		}
+	}
+	if v := params["preserve_host"]; v != "" {
+		if s.preserveHost, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid preserve_host param: %v", err)
+		}
+	}
+	dialTimeout, err := durationParam(params, "dial_timeout", defaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := durationParam(params, "timeout", defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	s.proxy = &httputil.ReverseProxy{
+		Director: s.direct,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   dialTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConnsPerHost:   16,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		// Stream responses, e.g server-sent events.
+		FlushInterval: -1,
+		ModifyResponse: func(res *http.Response) error {
+			for k, v := range s.responseHeaders {
+				res.Header.Set(k, v)
+			}
+			return nil
+		},
+		ErrorHandler: s.handleError,
+	}
+	return s, nil
+}
+
+// Params describes the params recognized by Server.
+var Params = fileserver.ParamSchema{
+	{Name: "strip_prefix", Type: fileserver.ParamString, Description: "Prefix removed from the request path before it is forwarded."},
+	{Name: "rewrite_pattern", Type: fileserver.ParamRegexp, Description: "Regular expression whose matches in the request path are replaced by rewrite_replacement."},
+	{Name: "rewrite_replacement", Type: fileserver.ParamString, Description: "Replacement of the matches of rewrite_pattern, with $1-style references."},
+	{Name: headerParamPrefix, Prefix: true, Type: fileserver.ParamString, Description: "Header set on the upstream request, e.g header.X-Forwarded-User. An empty value removes it."},
+	{Name: responseHeaderParamPrefix, Prefix: true, Type: fileserver.ParamString, Description: "Header set on the response, e.g response_header.Access-Control-Allow-Origin."},
+	{Name: "preserve_host", Type: fileserver.ParamBool, Default: "false", Description: "Forward the Host header of the request instead of the host of the upstream URL."},
+	{Name: "dial_timeout", Type: fileserver.ParamDuration, Default: defaultDialTimeout.String(), Description: "Timeout of connecting to the upstream server."},
+	{Name: "timeout", Type: fileserver.ParamDuration, Default: defaultTimeout.String(), Description: "How long to wait for the response headers of the upstream server."},
+}
+
+// durationParam parses the positive duration param name, which defaults to def.
+func durationParam(params fileserver.Params, name string, def time.Duration) (time.Duration, error) {
+	v := params[name]
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s param: %v", name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s param: %s is not positive", name, d)
+	}
+	return d, nil
+}
+
+type server struct {
+	root            string
+	target          *url.URL
+	stripPrefix     string
+	rewrite         *regexp.Regexp
+	replacement     string
+	headers         map[string]string
+	responseHeaders map[string]string
+	preserveHost    bool
+	proxy           *httputil.ReverseProxy
+}
+
+func (s *server) Root() string {
+	return s.root
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.proxy.ServeHTTP(w, r)
+}
+
+// direct rewrites the request r to target the upstream server.
+func (s *server) direct(r *http.Request) {
+	p := r.URL.Path
+	if s.stripPrefix != "" {
+		p = strings.TrimPrefix(p, s.stripPrefix)
+	}
+	if s.rewrite != nil {
+		p = s.rewrite.ReplaceAllString(p, s.replacement)
+	}
+	if p != "" && !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+
+	if r.Header.Get("X-Forwarded-Host") == "" {
+		r.Header.Set("X-Forwarded-Host", r.Host)
+	}
+	if r.Header.Get("X-Forwarded-Proto") == "" {
+		proto := "http"
+		if r.TLS != nil {
+			proto = "https"
+		}
+		r.Header.Set("X-Forwarded-Proto", proto)
+	}
+
+	r.URL.Scheme = s.target.Scheme
+	r.URL.Host = s.target.Host
+	r.URL.Path = singleJoiningSlash(s.target.Path, p)
+	r.URL.RawPath = ""
+	switch {
+	case s.target.RawQuery == "":
+	case r.URL.RawQuery == "":
+		r.URL.RawQuery = s.target.RawQuery
+	default:
+		r.URL.RawQuery = s.target.RawQuery + "&" + r.URL.RawQuery
+	}
+	if !s.preserveHost {
+		r.Host = s.target.Host
+	}
+	for k, v := range s.headers {
+		if v == "" {
+			r.Header.Del(k)
+			continue
+		}
+		r.Header.Set(k, v)
+	}
+}
+
+// handleError replies with 504 Gateway Timeout if the upstream server timed out,
+// or 502 Bad Gateway otherwise.
+func (s *server) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	fileserver.Logger(r.Context()).Warn("proxy error", "upstream", s.root, "path", r.URL.Path, "err", err)
+	status := http.StatusBadGateway
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		status = http.StatusGatewayTimeout
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+func singleJoiningSlash(a string, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash && b != "":
+		return a + "/" + b
+	}
+	return a + b
+}
+
+// CheckSource checks source is an absolute http or https URL.
+func CheckSource(source string) error {
+	u, err := url.Parse(source)
+	if err != nil {
+		return fileserver.ErrInvalidSource
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fileserver.ErrInvalidSource
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package proxy_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vincent-petithory/kraken/fileserver/proxy"
+)
+
+func TestServer(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/slow" {
+			time.Sleep(500 * time.Millisecond)
+		}
+		fmt.Fprintf(w, "%s %s %s %s", r.URL.Path, r.URL.RawQuery, r.Header.Get("X-Token"), r.Header.Get("X-Forwarded-Host"))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		Params map[string]string
+		Path   string
+		Code   int
+		Body   string
+		Header map[string]string
+	}{
+		{
+			Path: "/users?id=1",
+			Code: http.StatusOK,
+			Body: "/api/users id=1  kraken.test",
+		},
+		{
+			Params: map[string]string{"strip_prefix": "/v1"},
+			Path:   "/v1/users",
+			Code:   http.StatusOK,
+			Body:   "/api/users   kraken.test",
+		},
+		{
+			Params: map[string]string{"rewrite_pattern": `^/u/(\w+)$`, "rewrite_replacement": "/users/$1"},
+			Path:   "/u/bob",
+			Code:   http.StatusOK,
+			Body:   "/api/users/bob   kraken.test",
+		},
+		{
+			Params: map[string]string{"header.X-Token": "secret", "response_header.Access-Control-Allow-Origin": "*"},
+			Path:   "/",
+			Code:   http.StatusOK,
+			Body:   "/api/  secret kraken.test",
+			Header: map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			Params: map[string]string{"timeout": "50ms"},
+			Path:   "/slow",
+			Code:   http.StatusGatewayTimeout,
+		},
+	}
+	for _, test := range tests {
+		fs, err := proxy.Server(upstream.URL+"/api", test.Params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "http://kraken.test"+test.Path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.ServeHTTP(w, r)
+		if w.Code != test.Code {
+			t.Errorf("%s: expected status %d, got %d", test.Path, test.Code, w.Code)
+			continue
+		}
+		if test.Body != "" && w.Body.String() != test.Body {
+			t.Errorf("%s: expected body %q, got %q", test.Path, test.Body, w.Body.String())
+		}
+		for k, v := range test.Header {
+			if got := w.Header().Get(k); got != v {
+				t.Errorf("%s: expected header %s: %q, got %q", test.Path, k, v, got)
+			}
+		}
+	}
+
+	if err := proxy.CheckSource("/var/www"); err == nil {
+		t.Error("expected a path to be rejected as proxy source")
+	}
+	if err := proxy.CheckSource(upstream.URL); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWebsocket(t *testing.T) {
+	// The upstream server echoes what it reads once the connection is upgraded.
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "upgrade expected", http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+		rw.Flush()
+		io.Copy(conn, rw)
+	}))
+	defer upstream.Close()
+
+	fs, err := proxy.Server(upstream.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: kraken.test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status %d, got %d", http.StatusSwitchingProtocols, res.StatusCode)
+	}
+	io.WriteString(conn, "ping\n")
+	line, err := br.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(line) != "ping" {
+		t.Errorf("expected echoed ping, got %q", line)
+	}
+}
@@ -0,0 +1,168 @@
+package spa
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+const (
+	defaultIndex  = "index.html"
+	defaultMaxAge = 365 * 24 * 60 * 60
+)
+
+// Server defines the spa server constructor.
+//
+// It serves the files of a built single-page application. Requests for
+// unknown paths which don't look like an asset are answered with the index file,
+// so that the application can route them on the client side.
+// Directories are never listed.
+//
+// The following params are recognized:
+//
+//   - index: path of the index file, relative to the root. Defaults to index.html.
+//   - max_age: max age in seconds of hashed assets, which are cached as immutable.
+//     Defaults to one year.
+//   - hashed: regular expression matching the names of hashed assets.
+//     By default, a name is hashed if one of its dot or dash separated parts,
+//     except the last one, is a run of at least 8 letters and digits containing a digit,
+//     e.g main.3f2a1b9c.js or index-BwB1zc5p.css.
+var Server fileserver.Constructor = func(root string, params fileserver.Params) (fileserver.Server, error) {
+	s := &server{
+		root:   root,
+		fs:     http.Dir(root),
+		index:  "/" + defaultIndex,
+		maxAge: defaultMaxAge,
+		hashed: isHashed,
+	}
+	if index := params["index"]; index != "" {
+		s.index = path.Clean("/" + index)
+	}
+	if v := params["max_age"]; v != "" {
+		maxAge, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		if maxAge < 0 {
+			return nil, fmt.Errorf("negative max_age %d", maxAge)
+		}
+		s.maxAge = maxAge
+	}
+	if v := params["hashed"]; v != "" {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, err
+		}
+		s.hashed = re.MatchString
+	}
+	return s, nil
+}
+
+// Params describes the params recognized by Server.
+var Params = fileserver.ParamSchema{
+	{Name: "index", Type: fileserver.ParamString, Default: defaultIndex, Description: "Path of the index file, relative to the root."},
+	{Name: "max_age", Type: fileserver.ParamInt, Default: strconv.Itoa(defaultMaxAge), Description: "Max age in seconds of hashed assets, which are cached as immutable."},
+	{Name: "hashed", Type: fileserver.ParamRegexp, Description: "Regular expression matching the names of hashed assets."},
+}
+
+type server struct {
+	fs     http.FileSystem
+	root   string
+	index  string
+	maxAge int
+	hashed func(name string) bool
+}
+
+func (s server) Root() string {
+	return s.root
+}
+
+func (s server) Open(name string) (http.File, error) {
+	return s.fs.Open(name)
+}
+
+func (s server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	name := path.Clean("/" + r.URL.Path)
+	if name != s.index && s.serveFile(w, r, name) {
+		return
+	}
+	// Unknown paths with an extension are most likely missing assets:
+	// answering them with the index would only confuse the browser.
+	if name != s.index && path.Ext(name) != "" && !acceptsHTML(r) {
+		http.NotFound(w, r)
+		return
+	}
+	if !s.serveFile(w, r, s.index) {
+		http.NotFound(w, r)
+	}
+}
+
+// serveFile serves the regular file at name, with caching headers depending on its kind.
+// It returns false if there's no such regular file.
+func (s server) serveFile(w http.ResponseWriter, r *http.Request, name string) bool {
+	f, err := s.fs.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		return false
+	}
+	switch {
+	case name == s.index:
+		w.Header().Set("Cache-Control", "no-cache")
+	case s.hashed(fi.Name()):
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(s.maxAge)+", immutable")
+	}
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+	return true
+}
+
+// acceptsHTML reports whether the request is a navigation of a browser,
+// e.g for /app/v1.2 which has an extension but is not an asset.
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// isHashed reports whether name looks like the name of an asset with a content hash,
+// like main.3f2a1b9c.js or index-BwB1zc5p.css.
+func isHashed(name string) bool {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '.' || r == '-'
+	})
+	if len(parts) < 2 {
+		return false
+	}
+	// The first part is the base name, the last one the extension.
+	for _, part := range parts[1 : len(parts)-1] {
+		if len(part) < 8 {
+			continue
+		}
+		hasDigit, ok := false, true
+		for _, r := range part {
+			switch {
+			case unicode.IsDigit(r):
+				hasDigit = true
+			case r < unicode.MaxASCII && (unicode.IsLetter(r) || r == '_'):
+			default:
+				ok = false
+			}
+		}
+		if ok && hasDigit {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package spa_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+	"github.com/vincent-petithory/kraken/fileserver/spa"
+)
+
+func TestServer(t *testing.T) {
+	root, err := ioutil.TempDir("", "spa-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"index.html":                "index",
+		"favicon.ico":               "icon",
+		"assets/main.3f2a1b9c.js":   "main",
+		"assets/index-BwB1zc5p.css": "css",
+	}
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path         string
+		accept       string
+		status       int
+		body         string
+		cacheControl string
+	}{
+		{"/", "", http.StatusOK, "index", "no-cache"},
+		{"/index.html", "", http.StatusOK, "index", "no-cache"},
+		{"/app/settings", "", http.StatusOK, "index", "no-cache"},
+		{"/assets/", "", http.StatusOK, "index", "no-cache"},
+		{"/assets/main.3f2a1b9c.js", "", http.StatusOK, "main", "public, max-age=31536000, immutable"},
+		{"/assets/index-BwB1zc5p.css", "", http.StatusOK, "css", "public, max-age=31536000, immutable"},
+		{"/favicon.ico", "", http.StatusOK, "icon", ""},
+		{"/assets/missing.js", "", http.StatusNotFound, "", ""},
+		{"/docs/v1.2", "text/html,*/*", http.StatusOK, "index", "no-cache"},
+	}
+	fs, err := spa.Server(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Accept", test.accept)
+		fs.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.path, test.status, w.Code)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		if body := w.Body.String(); body != test.body {
+			t.Errorf("%s: expected body %q, got %q", test.path, test.body, body)
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != test.cacheControl {
+			t.Errorf("%s: expected Cache-Control %q, got %q", test.path, test.cacheControl, cc)
+		}
+	}
+}
+
+func TestServerInvalidParams(t *testing.T) {
+	tests := []fileserver.Params{
+		{"max_age": "-1"},
+		{"max_age": "forever"},
+		{"hashed": "("},
+	}
+	for _, params := range tests {
+		if _, err := spa.Server("/tmp", params); err == nil {
+			t.Errorf("%v: expected an error", params)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package union
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+)
+
+// fileSystem is a beachplug.FileSystem layering several file systems.
+//
+// When a path exists in several layers, the first layer wins.
+// Directories existing in several layers are merged.
+type fileSystem []beachplug.FileSystem
+
+func (fs fileSystem) Open(name string) (http.File, error) {
+	var (
+		dirs     []http.File
+		firstErr error
+	)
+	for _, layer := range fs {
+		f, err := layer.Open(name)
+		if err != nil {
+			if firstErr == nil && !os.IsNotExist(err) {
+				firstErr = err
+			}
+			continue
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			continue
+		}
+		if !fi.IsDir() {
+			// A file hides whatever the lower layers have at this path,
+			// unless an upper layer already has a directory here.
+			if len(dirs) == 0 {
+				return f, nil
+			}
+			f.Close()
+			continue
+		}
+		dirs = append(dirs, f)
+	}
+	switch len(dirs) {
+	case 0:
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, os.ErrNotExist
+	case 1:
+		return dirs[0], nil
+	}
+	return &dir{File: dirs[0], layers: dirs}, nil
+}
+
+// Lstat returns the info of the file at name in the layer Open opens it from.
+// For a directory merged from several layers, the info of a layer where it is
+// a symbolic link is returned, if any.
+func (fs fileSystem) Lstat(name string) (os.FileInfo, error) {
+	var (
+		dir      os.FileInfo
+		firstErr error
+	)
+	for _, layer := range fs {
+		fi, err := layer.Lstat(name)
+		if err != nil {
+			if firstErr == nil && !os.IsNotExist(err) {
+				firstErr = err
+			}
+			continue
+		}
+		isDir := fi.IsDir()
+		if fi.Mode()&os.ModeSymlink != 0 {
+			// Like Open, follow the link to know whether it is merged.
+			f, err := layer.Open(name)
+			if err != nil {
+				continue
+			}
+			tfi, err := f.Stat()
+			f.Close()
+			if err != nil {
+				continue
+			}
+			isDir = tfi.IsDir()
+		}
+		if !isDir {
+			if dir == nil {
+				return fi, nil
+			}
+			continue
+		}
+		if dir == nil || fi.Mode()&os.ModeSymlink != 0 && dir.Mode()&os.ModeSymlink == 0 {
+			dir = fi
+		}
+	}
+	if dir != nil {
+		return dir, nil
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, os.ErrNotExist
+}
+
+// dir is a directory existing in several layers.
+// Its Stat is the one of the first layer, and its entries are the merged entries of all layers.
+type dir struct {
+	http.File
+	layers  []http.File
+	entries []os.FileInfo
+	read    bool
+	pos     int
+}
+
+func (d *dir) readAll() error {
+	seen := make(map[string]bool)
+	for _, l := range d.layers {
+		fis, err := l.Readdir(-1)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		for _, fi := range fis {
+			if seen[fi.Name()] {
+				continue
+			}
+			seen[fi.Name()] = true
+			d.entries = append(d.entries, fi)
+		}
+	}
+	sort.Sort(byName(d.entries))
+	d.read = true
+	return nil
+}
+
+func (d *dir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.read {
+		if err := d.readAll(); err != nil {
+			return nil, err
+		}
+	}
+	fis := d.entries[d.pos:]
+	if count > 0 {
+		if len(fis) == 0 {
+			return nil, io.EOF
+		}
+		if len(fis) > count {
+			fis = fis[:count]
+		}
+	}
+	d.pos += len(fis)
+	return fis, nil
+}
+
+func (d *dir) Close() error {
+	var err error
+	for _, l := range d.layers {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+type byName []os.FileInfo
+
+func (l byName) Less(i int, j int) bool { return l[i].Name() < l[j].Name() }
+func (l byName) Swap(i int, j int)      { l[i], l[j] = l[j], l[i] }
+func (l byName) Len() int               { return len(l) }
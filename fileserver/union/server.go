@@ -0,0 +1,90 @@
+package union
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+)
+
+// Layers splits the source of a union mount into its layers.
+// Layers are separated by filepath.ListSeparator, like in $PATH.
+func Layers(source string) []string {
+	return filepath.SplitList(source)
+}
+
+// NewServer returns the constructor of union servers, which serve an ordered union
+// of directories, listed with fsc.
+//
+// The mount source is the list of directories, see Layers.
+// When a path exists in several directories, the first one wins; directory listings
+// are merged.
+//
+// Besides the params of fsc, the following params are recognized:
+//
+//   - upload_layer: index of the layer receiving uploads when the mount is writable,
+//     see UploadDir. Defaults to 0, the first layer.
+func NewServer(fsc beachplug.FileSystemConstructor) fileserver.Constructor {
+	return func(root string, params fileserver.Params) (fileserver.Server, error) {
+		layers := Layers(root)
+		fs := make(fileSystem, len(layers))
+		for i, l := range layers {
+			fs[i] = beachplug.Dir(l)
+		}
+		uploadLayer := 0
+		if v := params["upload_layer"]; v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 || n >= len(layers) {
+				return nil, fmt.Errorf("upload_layer %d out of range, the mount has %d layers", n, len(layers))
+			}
+			uploadLayer = n
+		}
+		s, err := fsc(root, fs, params)
+		if err != nil {
+			return nil, err
+		}
+		fss, ok := s.(fileserver.FileSystemServer)
+		if !ok {
+			return nil, fmt.Errorf("union: %T doesn't open files", s)
+		}
+		return &server{
+			FileSystemServer: fss,
+			uploadDir:        layers[uploadLayer],
+		}, nil
+	}
+}
+
+// Params describes the params recognized by the union servers, besides the ones of fsc.
+var Params = fileserver.ParamSchema{
+	{Name: "upload_layer", Type: fileserver.ParamInt, Default: "0", Description: "Index of the layer receiving uploads when the mount is writable."},
+}
+
+type server struct {
+	fileserver.FileSystemServer
+	uploadDir string
+}
+
+// UploadDir returns the directory of the layer receiving uploads.
+// Files written there are served unless a layer before it has them.
+func (s *server) UploadDir() string {
+	return s.uploadDir
+}
+
+// CheckSource checks each layer of source is the absolute path of a directory.
+func CheckSource(source string) error {
+	layers := Layers(source)
+	if len(layers) == 0 {
+		return fileserver.ErrInvalidSource
+	}
+	for _, l := range layers {
+		if err := fileserver.CheckDir(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
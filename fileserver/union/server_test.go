@@ -0,0 +1,198 @@
+package union_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+	"github.com/vincent-petithory/kraken/fileserver/beachplug"
+	"github.com/vincent-petithory/kraken/fileserver/union"
+)
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "union")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	layers := []string{filepath.Join(dir, "build"), filepath.Join(dir, "static")}
+	files := map[string]string{
+		"build/index.html":       "built index",
+		"build/js/app.js":        "built app",
+		"static/index.html":      "static index",
+		"static/js/vendor.js":    "vendor",
+		"static/css/style.css":   "style",
+		"static/robots.txt":      "robots",
+		"build/assets/logo.svg":  "logo",
+		"static/assets/logo.svg": "old logo",
+	}
+	for name, body := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := strings.Join(layers, string(filepath.ListSeparator))
+	if err := union.CheckSource(source); err != nil {
+		t.Fatal(err)
+	}
+	if err := union.CheckSource(source + string(filepath.ListSeparator) + filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected a source with a missing layer to be rejected")
+	}
+
+	fs, err := union.NewServer(beachplug.NewFileSystemServer(beachplug.Options{}))(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		Path     string
+		Code     int
+		Body     string
+		Contains []string
+	}{
+		{Path: "/index.html", Code: http.StatusOK, Body: "built index"},
+		{Path: "/assets/logo.svg", Code: http.StatusOK, Body: "logo"},
+		{Path: "/robots.txt", Code: http.StatusOK, Body: "robots"},
+		{Path: "/js/vendor.js", Code: http.StatusOK, Body: "vendor"},
+		{Path: "/missing", Code: http.StatusNotFound},
+		{Path: "/js/", Code: http.StatusOK, Contains: []string{"app.js", "vendor.js"}},
+		{Path: "/", Code: http.StatusOK, Contains: []string{"assets/", "css/", "js/", "robots.txt"}},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", test.Path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.ServeHTTP(w, r)
+		if w.Code != test.Code {
+			t.Errorf("%s: expected status %d, got %d", test.Path, test.Code, w.Code)
+			continue
+		}
+		if test.Body != "" && w.Body.String() != test.Body {
+			t.Errorf("%s: expected body %q, got %q", test.Path, test.Body, w.Body.String())
+		}
+		for _, s := range test.Contains {
+			if !strings.Contains(w.Body.String(), s) {
+				t.Errorf("%s: expected listing to contain %q", test.Path, s)
+			}
+		}
+		// Entries present in several layers are listed once.
+		if test.Path == "/" && strings.Count(w.Body.String(), ">index.html<") != 1 {
+			t.Errorf("%s: expected index.html to be listed once", test.Path)
+		}
+	}
+	// Uploads go to the first layer by default.
+	for _, test := range []struct {
+		Params fileserver.Params
+		Dir    string
+	}{
+		{nil, layers[0]},
+		{fileserver.Params{"upload_layer": "1"}, layers[1]},
+		{fileserver.Params{"upload_layer": "2"}, ""},
+		{fileserver.Params{"upload_layer": "-1"}, ""},
+	} {
+		fs, err := union.NewServer(beachplug.NewFileSystemServer(beachplug.Options{}))(source, test.Params)
+		if test.Dir == "" {
+			if err == nil {
+				t.Errorf("%v: expected an error", test.Params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dir := fs.(interface{ UploadDir() string }).UploadDir(); dir != test.Dir {
+			t.Errorf("%v: expected uploads to go to %s, got %s", test.Params, test.Dir, dir)
+		}
+	}
+}
+
+func TestServerSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "union")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, body := range map[string]string{
+		"build/shared/app.js": "app",
+		"static/robots.txt":   "robots",
+		"secret/secret.txt":   "secret",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"static/link.txt": "secret/secret.txt",
+		"static/linked":   "secret",
+		// Merged with the directory of the first layer.
+		"static/shared": "secret",
+	} {
+		if err := os.Symlink(filepath.Join(dir, filepath.FromSlash(target)), filepath.Join(dir, filepath.FromSlash(link))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source := strings.Join([]string{filepath.Join(dir, "build"), filepath.Join(dir, "static")}, string(filepath.ListSeparator))
+
+	tests := []struct {
+		Symlinks    string
+		Path        string
+		Code        int
+		Contains    []string
+		NotContains []string
+	}{
+		{Symlinks: "true", Path: "/link.txt", Code: http.StatusOK, Contains: []string{"secret"}},
+		{Symlinks: "true", Path: "/linked/secret.txt", Code: http.StatusOK, Contains: []string{"secret"}},
+		{Symlinks: "true", Path: "/shared/", Code: http.StatusOK, Contains: []string{"app.js", "secret.txt"}},
+		{Symlinks: "true", Path: "/", Code: http.StatusOK, Contains: []string{"link.txt", "linked/", "shared/", "robots.txt"}},
+		{Symlinks: "false", Path: "/link.txt", Code: http.StatusNotFound},
+		{Symlinks: "false", Path: "/linked/secret.txt", Code: http.StatusNotFound},
+		{Symlinks: "false", Path: "/shared/secret.txt", Code: http.StatusNotFound},
+		{Symlinks: "false", Path: "/shared/app.js", Code: http.StatusNotFound},
+		{Symlinks: "false", Path: "/robots.txt", Code: http.StatusOK, Contains: []string{"robots"}},
+		{Symlinks: "false", Path: "/", Code: http.StatusOK, Contains: []string{"robots.txt"}, NotContains: []string{"link.txt", "linked/", "shared/"}},
+		{Symlinks: "false", Path: "/?q=secret", Code: http.StatusOK, NotContains: []string{"secret.txt"}},
+	}
+	for _, test := range tests {
+		fs, err := union.NewServer(beachplug.NewFileSystemServer(beachplug.Options{}))(source, fileserver.Params{"symlinks": test.Symlinks})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", test.Path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.ServeHTTP(w, r)
+		if w.Code != test.Code {
+			t.Errorf("symlinks=%s %s: expected status %d, got %d", test.Symlinks, test.Path, test.Code, w.Code)
+			continue
+		}
+		for _, s := range test.Contains {
+			if !strings.Contains(w.Body.String(), s) {
+				t.Errorf("symlinks=%s %s: expected body to contain %q", test.Symlinks, test.Path, s)
+			}
+		}
+		for _, s := range test.NotContains {
+			if strings.Contains(w.Body.String(), s) {
+				t.Errorf("symlinks=%s %s: expected body not to contain %q", test.Symlinks, test.Path, s)
+			}
+		}
+	}
+}
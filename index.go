@@ -0,0 +1,47 @@
+package kraken
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+
+	"github.com/vincent-petithory/kraken/fileserver"
+)
+
+// serveIndex replies with a page listing the mount targets of a server.
+func serveIndex(w http.ResponseWriter, r *http.Request, targets []string) {
+	sort.Strings(targets)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTpl.Execute(w, struct {
+		Host    string
+		Targets []string
+	}{r.Host, targets}); err != nil {
+		fileserver.Logger(r.Context()).Error("unable to render the index page", "remote_addr", r.RemoteAddr, "err", err)
+	}
+}
+
+var indexTpl = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>{{.Host}}</title>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <style type="text/css">
+      body { font-family: sans-serif; margin: 2em; }
+      li { margin: 0.3em 0; }
+    </style>
+  </head>
+<body>
+  <h3>{{.Host}}</h3>
+  {{ if .Targets }}
+  <ul>
+  {{ range .Targets }}
+    <li><a href="{{ . }}/">{{ . }}</a></li>
+  {{ end }}
+  </ul>
+  {{ else }}
+  <p>Nothing is mounted on this server.</p>
+  {{ end }}
+</body>
+</html>
+`))
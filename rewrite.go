@@ -0,0 +1,204 @@
+package kraken
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Actions of a rewrite rule.
+const (
+	// RewriteActionRedirect redirects the client to the target of the rule.
+	RewriteActionRedirect = "redirect"
+	// RewriteActionRewrite serves the target of the rule in place of the requested path.
+	RewriteActionRewrite = "rewrite"
+	// RewriteActionStatus replies with the status of the rule and its target as body.
+	RewriteActionStatus = "status"
+)
+
+// RewriteRule describes how to handle requests whose path matches a regular expression.
+//
+// The target of the rule is expanded with the submatches of the pattern,
+// as done by regexp.Regexp.Expand: $1 or ${name} refers to a capture group.
+type RewriteRule struct {
+	ID      string
+	Pattern *regexp.Regexp
+	Action  string
+	Target  string
+	// Status is the redirect status for RewriteActionRedirect,
+	// or the response status for RewriteActionStatus.
+	Status int
+}
+
+// NewRewriteRule checks and compiles a rewrite rule.
+//
+// A redirect status defaults to 302 Found and must be one of 301, 302, 307 and 308.
+// The status of a RewriteActionStatus rule is mandatory.
+func NewRewriteRule(pattern string, action string, target string, status int) (*RewriteRule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	rule := &RewriteRule{
+		Pattern: re,
+		Action:  action,
+		Target:  target,
+		Status:  status,
+	}
+	switch action {
+	case RewriteActionRedirect:
+		if rule.Status == 0 {
+			rule.Status = http.StatusFound
+		}
+		switch rule.Status {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return nil, fmt.Errorf("invalid redirect status %d", rule.Status)
+		}
+		if target == "" {
+			return nil, errors.New("a redirect rule needs a target")
+		}
+	case RewriteActionRewrite:
+		if rule.Status != 0 {
+			return nil, errors.New("a rewrite rule has no status")
+		}
+		if target == "" {
+			return nil, errors.New("a rewrite rule needs a target")
+		}
+	case RewriteActionStatus:
+		if rule.Status < 200 || rule.Status > 599 {
+			return nil, fmt.Errorf("invalid status %d", rule.Status)
+		}
+	default:
+		return nil, fmt.Errorf("unknown rewrite action %q", action)
+	}
+	return rule, nil
+}
+
+// expand returns the target of the rule for the path p, and whether p matches the rule.
+func (rule *RewriteRule) expand(p string) (string, bool) {
+	m := rule.Pattern.FindStringSubmatchIndex(p)
+	if m == nil {
+		return "", false
+	}
+	return string(rule.Pattern.ExpandString(nil, rule.Target, p, m)), true
+}
+
+// RewriteRules is an ordered list of rewrite rules.
+// The first rule matching the path of a request applies.
+type RewriteRules struct {
+	mu     sync.RWMutex
+	rules  []*RewriteRule
+	lastID int
+}
+
+// Add inserts rule at the 1-based position pos, and assigns it an ID.
+// If pos is 0 or greater than the number of rules, rule is appended.
+func (rr *RewriteRules) Add(rule *RewriteRule, pos int) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.lastID++
+	rule.ID = strconv.Itoa(rr.lastID)
+	if pos <= 0 || pos > len(rr.rules) {
+		rr.rules = append(rr.rules, rule)
+		return
+	}
+	rr.rules = append(rr.rules, nil)
+	copy(rr.rules[pos:], rr.rules[pos-1:])
+	rr.rules[pos-1] = rule
+}
+
+// Rules returns the rules, in order.
+func (rr *RewriteRules) Rules() []*RewriteRule {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	rules := make([]*RewriteRule, len(rr.rules))
+	copy(rules, rr.rules)
+	return rules
+}
+
+// Get returns the rule with the given ID, or nil if it doesn't exist.
+func (rr *RewriteRules) Get(id string) *RewriteRule {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	for _, rule := range rr.rules {
+		if rule.ID == id {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Remove removes the rule with the given ID.
+// It returns the removed rule, or nil if it didn't exist.
+func (rr *RewriteRules) Remove(id string) *RewriteRule {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	for i, rule := range rr.rules {
+		if rule.ID == id {
+			rr.rules = append(rr.rules[:i], rr.rules[i+1:]...)
+			return rule
+		}
+	}
+	return nil
+}
+
+// Clear removes all the rules and returns them.
+func (rr *RewriteRules) Clear() []*RewriteRule {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rules := rr.rules
+	rr.rules = nil
+	return rules
+}
+
+// Handler returns a handler applying the rules to requests before passing them to h.
+func (rr *RewriteRules) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rr.mu.RLock()
+		var (
+			rule   *RewriteRule
+			target string
+		)
+		for _, rule = range rr.rules {
+			var ok bool
+			if target, ok = rule.expand(r.URL.Path); ok {
+				break
+			}
+			rule = nil
+		}
+		rr.mu.RUnlock()
+		if rule == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		switch rule.Action {
+		case RewriteActionRedirect:
+			// Keep the query of the request, unless the target has its own.
+			if r.URL.RawQuery != "" && !strings.Contains(target, "?") {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, rule.Status)
+		case RewriteActionRewrite:
+			if i := strings.Index(target, "?"); i >= 0 {
+				target, r.URL.RawQuery = target[:i], target[i+1:]
+			}
+			if !strings.HasPrefix(target, "/") {
+				target = "/" + target
+			}
+			r.URL.Path = target
+			r.URL.RawPath = ""
+			h.ServeHTTP(w, r)
+		case RewriteActionStatus:
+			if target == "" {
+				target = http.StatusText(rule.Status)
+			}
+			http.Error(w, target, rule.Status)
+		}
+	})
+}
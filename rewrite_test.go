@@ -0,0 +1,95 @@
+package kraken_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vincent-petithory/kraken"
+)
+
+func TestRewriteRules(t *testing.T) {
+	rules := &kraken.RewriteRules{}
+	for _, r := range []struct {
+		Pattern, Action, Target string
+		Status                  int
+	}{
+		{`^/old/(.*)$`, kraken.RewriteActionRedirect, "/new/$1", http.StatusMovedPermanently},
+		{`^/docs$`, kraken.RewriteActionRedirect, "/docs/", 0},
+		{`^/alias/(?P<name>\w+)$`, kraken.RewriteActionRewrite, "/files/${name}.txt?from=alias", 0},
+		{`^/private/`, kraken.RewriteActionStatus, "", http.StatusForbidden},
+		{`^/gone`, kraken.RewriteActionStatus, "gone for good", http.StatusGone},
+	} {
+		rule, err := kraken.NewRewriteRule(r.Pattern, r.Action, r.Target, r.Status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules.Add(rule, 0)
+	}
+	// Inserted first, it shadows the /old/ redirect for /old/keep.
+	rule, err := kraken.NewRewriteRule(`^/old/keep$`, kraken.RewriteActionRewrite, "/kept", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules.Add(rule, 1)
+
+	h := rules.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path+" "+r.URL.RawQuery)
+	}))
+	tests := []struct {
+		ReqPath  string
+		Status   int
+		Location string
+		Body     string
+	}{
+		{"/old/a/b?x=1", http.StatusMovedPermanently, "/new/a/b?x=1", ""},
+		{"/old/keep", http.StatusOK, "", "/kept "},
+		{"/docs", http.StatusFound, "/docs/", ""},
+		{"/alias/readme", http.StatusOK, "", "/files/readme.txt from=alias"},
+		{"/private/key", http.StatusForbidden, "", "Forbidden\n"},
+		{"/gone", http.StatusGone, "", "gone for good\n"},
+		{"/other", http.StatusOK, "", "/other "},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", test.ReqPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.ServeHTTP(w, r)
+		if w.Code != test.Status {
+			t.Errorf("%s: expected http status %d, got %d", test.ReqPath, test.Status, w.Code)
+			continue
+		}
+		if test.Location != "" && w.Header().Get("Location") != test.Location {
+			t.Errorf("%s: expected location %q, got %q", test.ReqPath, test.Location, w.Header().Get("Location"))
+		}
+		if test.Body != "" && w.Body.String() != test.Body {
+			t.Errorf("%s: expected body %q, got %q", test.ReqPath, test.Body, w.Body.String())
+		}
+	}
+
+	if removed := rules.Remove(rule.ID); removed != rule {
+		t.Errorf("expected rule %s to be removed", rule.ID)
+	}
+	if n := len(rules.Rules()); n != 5 {
+		t.Errorf("expected 5 rules, got %d", n)
+	}
+
+	invalid := []struct {
+		Pattern, Action, Target string
+		Status                  int
+	}{
+		{`(`, kraken.RewriteActionRewrite, "/", 0},
+		{`^/`, "proxy", "/", 0},
+		{`^/`, kraken.RewriteActionRedirect, "/", http.StatusOK},
+		{`^/`, kraken.RewriteActionRewrite, "", 0},
+		{`^/`, kraken.RewriteActionStatus, "", 0},
+	}
+	for _, r := range invalid {
+		if _, err := kraken.NewRewriteRule(r.Pattern, r.Action, r.Target, r.Status); err == nil {
+			t.Errorf("expected rule %q %s %q %d to be invalid", r.Pattern, r.Action, r.Target, r.Status)
+		}
+	}
+}
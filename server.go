@@ -1,13 +1,13 @@
 package kraken
 
 import (
+	"context"
+	"crypto/sha1"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
-	"os"
-	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,6 +20,13 @@ type MountMap struct {
 	m   map[string]fileserver.Server
 	mu  sync.Mutex
 	fsf fileserver.Factory
+
+	// ErrorPages are the custom error pages of the server.
+	// Pages set on a mount take precedence.
+	ErrorPages *fileserver.ErrorPages
+	// RootIndex enables an index page listing the mounts,
+	// served on / when nothing is mounted there.
+	RootIndex bool
 }
 
 func (mm *MountMap) Targets() []string {
@@ -60,6 +67,9 @@ func (e *MountSourcePermError) Error() string {
 }
 
 // Put registers a mount target for the given mount source.
+// The mount source is checked according to fsType: most types serve a directory,
+// but it can be e.g an archive file or an upstream URL.
+// The params are checked by the file server type, see fileserver.Factory.CheckParams.
 // It returns true if the mount target already exists.
 func (mm *MountMap) Put(mountTarget string, mountSource string, fsType string, fsParams fileserver.Params) (bool, error) {
 	// mountTarget must start with /
@@ -71,21 +81,22 @@ func (mm *MountMap) Put(mountTarget string, mountSource string, fsType string, f
 		return false, ErrInvalidMountTarget
 	}
 
-	if !path.IsAbs(mountSource) {
-		return false, ErrInvalidMountSource
-	}
-
-	fi, err := os.Stat(mountSource)
-	if err != nil {
+	if err := mm.fsf.CheckSource(fsType, mountSource); err != nil {
+		switch {
+		case err == fileserver.ErrInvalidSource:
+			return false, ErrInvalidMountSource
+		case errors.Is(err, fileserver.ErrUnknownType):
+			return false, err
+		}
 		return false, &MountSourcePermError{err}
 	}
-	if !fi.IsDir() {
-		return false, &MountSourcePermError{fmt.Errorf("%s: not a directory", mountSource)}
+	fs, err := mm.fsf.New(mountSource, fsType, fsParams)
+	if err != nil {
+		return false, err
 	}
 
 	mm.mu.Lock()
 	_, ok := mm.m[mountTarget]
-	fs := mm.fsf.New(mountSource, fsType, fsParams)
 	mm.m[mountTarget] = fs
 	mm.mu.Unlock()
 	return ok, nil
@@ -102,6 +113,10 @@ func (mm *MountMap) DeleteTarget(mountTarget string) bool {
 }
 
 func (mm *MountMap) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mm.ErrorPages.Handler(http.HandlerFunc(mm.serveHTTP)).ServeHTTP(w, r)
+}
+
+func (mm *MountMap) serveHTTP(w http.ResponseWriter, r *http.Request) {
 	var (
 		maxMountTargetLen int
 		mountTarget       string
@@ -114,6 +129,15 @@ func (mm *MountMap) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if maxMountTargetLen == 0 {
+		if mm.RootIndex && r.URL.Path == "/" {
+			targets := make([]string, 0, len(mm.m))
+			for t := range mm.m {
+				targets = append(targets, t)
+			}
+			mm.mu.Unlock()
+			serveIndex(w, r, targets)
+			return
+		}
 		http.Error(w, fmt.Sprintf("%s: mount target or file not found", r.URL.Path), http.StatusNotFound)
 		mm.mu.Unlock()
 		return
@@ -133,7 +157,32 @@ func (mm *MountMap) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("mount target %q not found", mountTarget), http.StatusNotFound)
 		return
 	}
-	fs.ServeHTTP(w, r)
+	r, target := WithMountTarget(r)
+	*target = mountTarget
+	logger := fileserver.Logger(r.Context()).With("mount_id", MountID(mountTarget))
+	fs.ServeHTTP(w, r.WithContext(fileserver.WithLogger(r.Context(), logger)))
+}
+
+type mountTargetKey struct{}
+
+// WithMountTarget returns a shallow copy of r, and a pointer where a MountMap serving it
+// stores the target of the mount it is routed to.
+// It is left empty if the request doesn't match any mount.
+// If r already has such a pointer, r and the pointer are returned as is:
+// the file servers get the target of their mount this way.
+func WithMountTarget(r *http.Request) (*http.Request, *string) {
+	if target, ok := r.Context().Value(mountTargetKey{}).(*string); ok {
+		return r, target
+	}
+	target := new(string)
+	return r.WithContext(context.WithValue(r.Context(), mountTargetKey{}, target)), target
+}
+
+// MountID returns the ID of the mount on target.
+func MountID(target string) string {
+	h := sha1.New()
+	h.Write([]byte(target))
+	return fmt.Sprintf("%x", h.Sum(nil))[0:7]
 }
 
 func NewMountMap(fsf fileserver.Factory) *MountMap {
@@ -145,38 +194,54 @@ func NewMountMap(fsf fileserver.Factory) *MountMap {
 
 type Server struct {
 	MountMap       *MountMap
+	Rewrites       *RewriteRules
+	Stats          *ServerStats
 	HandlerWrapper func(http.Handler) http.Handler
+	ConnState      func(net.Conn, http.ConnState)
 	Addr           string
 	Port           uint16
 	Started        chan struct{}
 	Running        bool
-	srv            *http.Server
-	ln             net.Listener
+	// Logger logs the errors of the server; slog.Default() if nil.
+	// They have the port of the server as attribute.
+	Logger *slog.Logger
+	srv    *http.Server
+	ln     net.Listener
+
+	mu  sync.Mutex
+	err error
+}
+
+// ErrServerClosed is the error of a server which was closed.
+var ErrServerClosed = errors.New("server closed")
+
+func (s *Server) logger() *slog.Logger {
+	l := s.Logger
+	if l == nil {
+		l = slog.Default()
+	}
+	return l.With("port", s.Port)
 }
 
 func NewServer(addr string, fsf fileserver.Factory) *Server {
 	return &Server{
 		MountMap: NewMountMap(fsf),
+		Rewrites: &RewriteRules{},
+		Stats:    NewServerStats(),
 		Addr:     addr,
 		Started:  make(chan struct{}),
 	}
 }
 
+// ListenAndServe listens on the address of the server, and serves the requests.
+// Started is closed once it listens, or if it can't.
 func (s *Server) ListenAndServe() error {
-	ln, err := net.Listen("tcp", s.Addr)
+	ln, err := s.listen()
 	if err != nil {
+		s.setErr(err)
+		close(s.Started)
 		return err
 	}
-	s.Addr = ln.Addr().String()
-	_, sport, err := net.SplitHostPort(s.Addr)
-	if err != nil {
-		return err
-	}
-	port, err := strconv.Atoi(sport)
-	if err != nil {
-		return err
-	}
-	s.Port = uint16(port)
 
 	var h http.Handler
 	if s.HandlerWrapper != nil {
@@ -184,19 +249,74 @@ func (s *Server) ListenAndServe() error {
 	} else {
 		h = s.MountMap
 	}
+	logger := s.logger()
 	s.srv = &http.Server{
-		Handler: h,
+		Handler:   h,
+		ConnState: s.ConnState,
+		ErrorLog:  slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		// The file servers log the errors of the requests with the logger of the server.
+		BaseContext: func(net.Listener) context.Context {
+			return fileserver.WithLogger(context.Background(), logger)
+		},
 	}
 	s.ln = &connsCloserListener{
 		Listener: tcpKeepAliveListener{ln.(*net.TCPListener)},
+		logger:   logger,
 	}
 
 	close(s.Started)
 	s.Running = true
-	if err := s.srv.Serve(s.ln); err != nil {
-		return err
+	err = s.srv.Serve(s.ln)
+	// Closing the listener is how a server is shut down.
+	if errors.Is(err, net.ErrClosed) {
+		s.setErr(ErrServerClosed)
+	} else {
+		s.setErr(err)
 	}
-	return nil
+	return err
+}
+
+// listen listens on the address of the server, and sets its address and port.
+func (s *Server) listen() (net.Listener, error) {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		// Keep the port of the address, to report on which one it failed.
+		if _, sport, err := net.SplitHostPort(s.Addr); err == nil {
+			if port, err := strconv.Atoi(sport); err == nil {
+				s.Port = uint16(port)
+			}
+		}
+		return nil, err
+	}
+	addr := ln.Addr().String()
+	_, sport, err := net.SplitHostPort(addr)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	port, err := strconv.Atoi(sport)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	s.Addr = addr
+	s.Port = uint16(port)
+	return ln, nil
+}
+
+func (s *Server) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Err returns why the server is not serving: the error which prevented it from starting,
+// the one which stopped it, or ErrServerClosed.
+// It is nil while the server is starting or serving.
+func (s *Server) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
 }
 
 func (s *Server) Close() error {
@@ -205,8 +325,9 @@ func (s *Server) Close() error {
 
 type connsCloserListener struct {
 	net.Listener
-	m     sync.Mutex
-	conns []net.Conn
+	m      sync.Mutex
+	conns  []net.Conn
+	logger *slog.Logger
 }
 
 func (ln *connsCloserListener) Accept() (c net.Conn, err error) {
@@ -224,8 +345,9 @@ func (ln *connsCloserListener) Close() error {
 	ln.m.Lock()
 	defer ln.m.Unlock()
 	for _, c := range ln.conns {
-		if err := c.Close(); err != nil {
-			log.Println(err)
+		// Hijacked connections may already be closed.
+		if err := c.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			ln.logger.Warn("unable to close connection", "remote_addr", c.RemoteAddr().String(), "err", err)
 		}
 	}
 	ln.conns = nil
@@ -249,10 +371,15 @@ func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
 }
 
 type ServerPool struct {
-	srvs  []*Server
-	Fsf   fileserver.Factory
-	srvCh chan *Server
-	m     sync.Mutex
+	srvs []*Server
+	// failed are the servers which could not start,
+	// until a server is added on their address.
+	failed []*Server
+	Fsf    fileserver.Factory
+	// Logger is the logger of the servers added to the pool; slog.Default() if nil.
+	Logger *slog.Logger
+	srvCh  chan *Server
+	m      sync.Mutex
 }
 
 func NewServerPool(fsf fileserver.Factory) *ServerPool {
@@ -267,8 +394,19 @@ func (sp *ServerPool) Add(addr string) (*Server, error) {
 		return nil, err
 	}
 	s := NewServer(addr, sp.Fsf)
+	s.Logger = sp.Logger
 	sp.m.Lock()
 	sp.srvs = append(sp.srvs, s)
+	failed := sp.failed[:0]
+	for _, srv := range sp.failed {
+		if srv.Addr != addr {
+			failed = append(failed, srv)
+		}
+	}
+	for i := len(failed); i < len(sp.failed); i++ {
+		sp.failed[i] = nil
+	}
+	sp.failed = failed
 	sp.m.Unlock()
 	return s, nil
 }
@@ -292,6 +430,22 @@ func (sp *ServerPool) Servers() []*Server {
 	return srvs
 }
 
+// Failed returns the servers which could not start. Their Err tells why.
+func (sp *ServerPool) Failed() []*Server {
+	sp.m.Lock()
+	srvs := make([]*Server, len(sp.failed))
+	copy(srvs, sp.failed)
+	sp.m.Unlock()
+	return srvs
+}
+
+// ClearFailed forgets the servers which could not start.
+func (sp *ServerPool) ClearFailed() {
+	sp.m.Lock()
+	sp.failed = nil
+	sp.m.Unlock()
+}
+
 func (sp *ServerPool) NServer() int {
 	return len(sp.Servers())
 }
@@ -314,6 +468,39 @@ func (sp *ServerPool) Remove(port uint16) (bool, error) {
 	return false, nil
 }
 
+// Discard removes s, which is starting or could not start, from the pool,
+// for a caller which reports the failure itself: s is not listed by Failed.
+func (sp *ServerPool) Discard(s *Server) {
+	sp.m.Lock()
+	defer sp.m.Unlock()
+	for _, srvs := range []*[]*Server{&sp.srvs, &sp.failed} {
+		for i, srv := range *srvs {
+			if srv == s {
+				copy((*srvs)[i:], (*srvs)[i+1:])
+				(*srvs)[len(*srvs)-1] = nil
+				*srvs = (*srvs)[:len(*srvs)-1]
+				break
+			}
+		}
+	}
+}
+
+// fail moves s, which could not start, to the failed servers,
+// unless it was discarded.
+func (sp *ServerPool) fail(s *Server) {
+	sp.m.Lock()
+	defer sp.m.Unlock()
+	for i, srv := range sp.srvs {
+		if srv == s {
+			copy(sp.srvs[i:], sp.srvs[i+1:])
+			sp.srvs[len(sp.srvs)-1] = nil
+			sp.srvs = sp.srvs[:len(sp.srvs)-1]
+			sp.failed = append(sp.failed, s)
+			return
+		}
+	}
+}
+
 func (sp *ServerPool) StartSrv(s *Server) bool {
 	// check the server is registered
 	if s.Running {
@@ -333,8 +520,15 @@ func (sp *ServerPool) StartSrv(s *Server) bool {
 func (sp *ServerPool) Listen() {
 	for srv := range sp.srvCh {
 		go func(s *Server) {
-			// Ignore errClosing errors. See https://code.google.com/p/go/issues/detail?id=4373
-			s.ListenAndServe()
+			err := s.ListenAndServe()
+			switch {
+			case s.ln == nil:
+				// It never served, so it can't be removed by its port.
+				sp.fail(s)
+				s.logger().Error("unable to start server", "addr", s.Addr, "err", err)
+			case !errors.Is(err, net.ErrClosed):
+				s.logger().Error("server stopped", "addr", s.Addr, "err", err)
+			}
 		}(srv)
 	}
 }
@@ -1,12 +1,18 @@
 package kraken_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/vincent-petithory/kraken"
 	"github.com/vincent-petithory/kraken/fileserver"
@@ -23,7 +29,7 @@ func (fs mockFileServer) Root() string {
 
 func TestMountMapHandler(t *testing.T) {
 	fsf := make(fileserver.Factory)
-	if err := fsf.Register("mock", func(root string, params fileserver.Params) fileserver.Server {
+	if err := fsf.Register("mock", func(root string, params fileserver.Params) (fileserver.Server, error) {
 		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			io.WriteString(w, r.URL.Path)
@@ -33,7 +39,7 @@ func TestMountMapHandler(t *testing.T) {
 			RootFn: func() string {
 				return root
 			},
-		}
+		}, nil
 	}); err != nil {
 		t.Fatal(err)
 	}
@@ -83,3 +89,183 @@ func TestMountMapHandler(t *testing.T) {
 		}
 	}
 }
+
+func TestMountMapRootIndex(t *testing.T) {
+	mountSource, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mountMap := kraken.NewMountMap(make(fileserver.Factory))
+	for _, target := range []string{"/pics", "/docs"} {
+		if _, err := mountMap.Put(target, mountSource, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mountMap.ServeHTTP(w, r)
+		return w
+	}
+	if w := get(); w.Code != http.StatusNotFound {
+		t.Errorf("expected http status %d without index, got %d", http.StatusNotFound, w.Code)
+	}
+
+	mountMap.RootIndex = true
+	w := get()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected http status %d, got %d", http.StatusOK, w.Code)
+	}
+	body := w.Body.String()
+	docs, pics := strings.Index(body, `href="/docs/"`), strings.Index(body, `href="/pics/"`)
+	if docs < 0 || pics < 0 || docs > pics {
+		t.Errorf("expected sorted links to the mounts, got %q", body)
+	}
+
+	// A mount on / takes precedence over the index.
+	if _, err := mountMap.Put("/", mountSource, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(); strings.Contains(w.Body.String(), `href="/pics/"`) {
+		t.Error("expected the mount on / to be served instead of the index")
+	}
+}
+
+func TestServerErr(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// The address is already in use.
+	srv := kraken.NewServer(ln.Addr().String(), make(fileserver.Factory))
+	if err := srv.ListenAndServe(); err == nil {
+		t.Fatal("expected an error listening on an address in use")
+	}
+	select {
+	case <-srv.Started:
+	default:
+		t.Fatal("expected Started to be closed when the server can't start")
+	}
+	if srv.Err() == nil {
+		t.Error("expected the server to have an error")
+	}
+
+	srv = kraken.NewServer("127.0.0.1:0", make(fileserver.Factory))
+	done := make(chan error)
+	go func() {
+		done <- srv.ListenAndServe()
+	}()
+	<-srv.Started
+	if err := srv.Err(); err != nil {
+		t.Errorf("expected no error while serving, got %v", err)
+	}
+	if err := srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if err := srv.Err(); !errors.Is(err, kraken.ErrServerClosed) {
+		t.Errorf("expected %v, got %v", kraken.ErrServerClosed, err)
+	}
+}
+
+func TestServerLogger(t *testing.T) {
+	fsf := make(fileserver.Factory)
+	if err := fsf.Register("logging", func(root string, params fileserver.Params) (fileserver.Server, error) {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fileserver.Logger(r.Context()).Error("unable to serve")
+			http.Error(w, "failed", http.StatusInternalServerError)
+		})
+		return mockFileServer{h, func() string { return root }}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	srv := kraken.NewServer("127.0.0.1:0", fsf)
+	srv.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+	if _, err := srv.MountMap.Put("/logs", os.TempDir(), "logging", nil); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- srv.ListenAndServe()
+	}()
+	<-srv.Started
+	defer func() {
+		srv.Close()
+		<-done
+	}()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/logs/", srv.Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// The mounts log with the logger of the server.
+	line := buf.String()
+	for _, attr := range []string{"unable to serve", fmt.Sprintf("port=%d", srv.Port), "mount_id=" + kraken.MountID("/logs")} {
+		if !strings.Contains(line, attr) {
+			t.Errorf("expected the log to contain %q, got %q", attr, line)
+		}
+	}
+}
+
+func TestServerPoolFailed(t *testing.T) {
+	sp := kraken.NewServerPool(make(fileserver.Factory))
+	go sp.Listen()
+
+	// startFailing starts a server on an address taken once it is added.
+	startFailing := func() (*kraken.Server, net.Listener) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ln.Addr().String()
+		ln.Close()
+		srv, err := sp.Add(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			t.Fatal(err)
+		}
+		if !sp.StartSrv(srv) {
+			t.Fatal("expected the server to be started")
+		}
+		<-srv.Started
+		return srv, ln
+	}
+	waitFailed := func(n int) []*kraken.Server {
+		deadline := time.Now().Add(time.Second)
+		for {
+			failed := sp.Failed()
+			if len(failed) == n || time.Now().After(deadline) {
+				return failed
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	srv, ln := startFailing()
+	defer ln.Close()
+	if failed := waitFailed(1); len(failed) != 1 || failed[0] != srv || failed[0].Err() == nil {
+		t.Fatalf("expected the server to have failed, got %v", failed)
+	}
+	if sp.NServer() != 0 {
+		t.Errorf("expected no server in the pool, got %d", sp.NServer())
+	}
+	// A failure reported by the caller is not kept, whether it is recorded yet or not.
+	sp.Discard(srv)
+	srv, ln = startFailing()
+	defer ln.Close()
+	sp.Discard(srv)
+	time.Sleep(50 * time.Millisecond)
+	if failed := sp.Failed(); len(failed) != 0 {
+		t.Errorf("expected no failed server, got %v", failed)
+	}
+}
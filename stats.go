@@ -0,0 +1,219 @@
+package kraken
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats are the traffic statistics of a server or a mount, tracked in memory.
+// Client IPs and file paths are kept until Reset is called.
+type Stats struct {
+	mu       sync.Mutex
+	since    time.Time
+	requests int64
+	bytes    int64
+	statuses map[int]int64
+	clients  map[string]bool
+	files    map[string]int64
+}
+
+// NewStats returns empty stats, starting now.
+func NewStats() *Stats {
+	s := &Stats{}
+	s.Reset()
+	return s
+}
+
+// Record records a request of the client at clientIP for path, answered with status
+// and a body of n bytes. Successful GET requests count as a download of path.
+func (s *Stats) Record(clientIP string, method string, path string, status int, n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.bytes += n
+	s.statuses[status]++
+	if clientIP != "" {
+		s.clients[clientIP] = true
+	}
+	if method == "GET" && (status == 200 || status == 206) {
+		s.files[path]++
+	}
+}
+
+// Reset clears the stats, which start again now.
+func (s *Stats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.since = time.Now()
+	s.requests = 0
+	s.bytes = 0
+	s.statuses = make(map[int]int64)
+	s.clients = make(map[string]bool)
+	s.files = make(map[string]int64)
+}
+
+// FileCount is the number of downloads of a file.
+type FileCount struct {
+	Path  string `json:"path"`
+	Count int64  `json:"count"`
+}
+
+// StatsSnapshot is a copy of Stats at some point in time.
+type StatsSnapshot struct {
+	Since         time.Time
+	Requests      int64
+	Bytes         int64
+	Statuses      map[int]int64
+	UniqueClients int
+	// TopFiles are the most downloaded files, by decreasing count.
+	TopFiles []FileCount
+}
+
+// Snapshot returns a copy of the stats, with the top n downloaded files.
+func (s *Stats) Snapshot(n int) StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := StatsSnapshot{
+		Since:         s.since,
+		Requests:      s.requests,
+		Bytes:         s.bytes,
+		Statuses:      make(map[int]int64, len(s.statuses)),
+		UniqueClients: len(s.clients),
+	}
+	for status, count := range s.statuses {
+		snap.Statuses[status] = count
+	}
+	files := make([]FileCount, 0, len(s.files))
+	for path, count := range s.files {
+		files = append(files, FileCount{path, count})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Count != files[j].Count {
+			return files[i].Count > files[j].Count
+		}
+		return files[i].Path < files[j].Path
+	})
+	if len(files) > n {
+		files = files[:n]
+	}
+	snap.TopFiles = files
+	return snap
+}
+
+// savedStats is the form in which Stats are saved.
+type savedStats struct {
+	Since    time.Time        `json:"since"`
+	Requests int64            `json:"requests"`
+	Bytes    int64            `json:"bytes"`
+	Statuses map[int]int64    `json:"statuses"`
+	Clients  []string         `json:"clients"`
+	Files    map[string]int64 `json:"files"`
+}
+
+func (s *Stats) MarshalJSON() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ss := savedStats{
+		Since:    s.since,
+		Requests: s.requests,
+		Bytes:    s.bytes,
+		Statuses: s.statuses,
+		Clients:  make([]string, 0, len(s.clients)),
+		Files:    s.files,
+	}
+	for ip := range s.clients {
+		ss.Clients = append(ss.Clients, ip)
+	}
+	sort.Strings(ss.Clients)
+	return json.Marshal(ss)
+}
+
+func (s *Stats) UnmarshalJSON(b []byte) error {
+	var ss savedStats
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+	s.Reset()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.since = ss.Since
+	s.requests = ss.Requests
+	s.bytes = ss.Bytes
+	for status, count := range ss.Statuses {
+		s.statuses[status] = count
+	}
+	for _, ip := range ss.Clients {
+		s.clients[ip] = true
+	}
+	for path, count := range ss.Files {
+		s.files[path] = count
+	}
+	return nil
+}
+
+// ServerStats are the stats of a server, and of each of its mounts.
+type ServerStats struct {
+	*Stats
+	mu     sync.Mutex
+	mounts map[string]*Stats
+}
+
+// NewServerStats returns empty server stats.
+func NewServerStats() *ServerStats {
+	return &ServerStats{
+		Stats:  NewStats(),
+		mounts: make(map[string]*Stats),
+	}
+}
+
+// Mount returns the stats of the mount target.
+func (ss *ServerStats) Mount(target string) *Stats {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	s, ok := ss.mounts[target]
+	if !ok {
+		s = NewStats()
+		ss.mounts[target] = s
+	}
+	return s
+}
+
+// RemoveMount forgets the stats of the mount target.
+func (ss *ServerStats) RemoveMount(target string) {
+	ss.mu.Lock()
+	delete(ss.mounts, target)
+	ss.mu.Unlock()
+}
+
+type savedServerStats struct {
+	Server *Stats            `json:"server"`
+	Mounts map[string]*Stats `json:"mounts"`
+}
+
+func (ss *ServerStats) MarshalJSON() ([]byte, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return json.Marshal(savedServerStats{ss.Stats, ss.mounts})
+}
+
+func (ss *ServerStats) UnmarshalJSON(b []byte) error {
+	var sss savedServerStats
+	if err := json.Unmarshal(b, &sss); err != nil {
+		return err
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.Stats = sss.Server
+	if ss.Stats == nil {
+		ss.Stats = NewStats()
+	}
+	ss.mounts = make(map[string]*Stats, len(sss.Mounts))
+	for target, s := range sss.Mounts {
+		if s != nil {
+			ss.mounts[target] = s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package kraken_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/vincent-petithory/kraken"
+)
+
+func TestStats(t *testing.T) {
+	stats := kraken.NewStats()
+	for _, r := range []struct {
+		ClientIP, Method, Path string
+		Status                 int
+		N                      int64
+	}{
+		{"10.0.0.1", "GET", "/a", 200, 10},
+		{"10.0.0.1", "GET", "/b", 206, 5},
+		{"10.0.0.2", "GET", "/b", 200, 20},
+		{"10.0.0.2", "HEAD", "/c", 200, 0},
+		{"10.0.0.3", "GET", "/d", 404, 9},
+	} {
+		stats.Record(r.ClientIP, r.Method, r.Path, r.Status, r.N)
+	}
+
+	snap := stats.Snapshot(10)
+	if snap.Requests != 5 {
+		t.Errorf("expected 5 requests, got %d", snap.Requests)
+	}
+	if snap.Bytes != 44 {
+		t.Errorf("expected 44 bytes, got %d", snap.Bytes)
+	}
+	if snap.UniqueClients != 3 {
+		t.Errorf("expected 3 unique clients, got %d", snap.UniqueClients)
+	}
+	if expected := map[int]int64{200: 3, 206: 1, 404: 1}; !reflect.DeepEqual(snap.Statuses, expected) {
+		t.Errorf("expected statuses %v, got %v", expected, snap.Statuses)
+	}
+	if expected := []kraken.FileCount{{"/b", 2}, {"/a", 1}}; !reflect.DeepEqual(snap.TopFiles, expected) {
+		t.Errorf("expected top files %v, got %v", expected, snap.TopFiles)
+	}
+	if top := stats.Snapshot(1).TopFiles; len(top) != 1 || top[0].Path != "/b" {
+		t.Errorf("expected top 1 file to be /b, got %v", top)
+	}
+
+	b, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := kraken.NewStats()
+	if err := json.Unmarshal(b, restored); err != nil {
+		t.Fatal(err)
+	}
+	restoredSnap := restored.Snapshot(10)
+	if !restoredSnap.Since.Equal(snap.Since) {
+		t.Errorf("expected restored stats since %v, got %v", snap.Since, restoredSnap.Since)
+	}
+	restoredSnap.Since = snap.Since
+	if !reflect.DeepEqual(restoredSnap, snap) {
+		t.Errorf("expected restored stats %+v, got %+v", snap, restoredSnap)
+	}
+
+	stats.Reset()
+	if snap := stats.Snapshot(10); snap.Requests != 0 || snap.UniqueClients != 0 || len(snap.TopFiles) != 0 {
+		t.Errorf("expected empty stats after reset, got %+v", snap)
+	}
+}